@@ -1,8 +1,12 @@
 package main
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"log"
 	"net/http"
+	"os"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -22,7 +26,7 @@ func main() {
 	}
 
 	// Auto migrate models
-	err = db.AutoMigrate(&models.Province{}, &models.Unit{}, &models.ExcelFile{})
+	err = db.AutoMigrate(&models.Province{}, &models.Unit{}, &models.ExcelFile{}, &models.ReportingPeriod{}, &models.Submission{}, &models.SubmissionEvent{}, &models.User{}, &models.APIKey{}, &models.AuditLog{}, &models.Calculation{}, &models.Recipe{}, &models.TaxTable{})
 	if err != nil {
 		log.Fatal("Failed to migrate database:", err)
 	}
@@ -34,14 +38,22 @@ func main() {
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:5173", "http://localhost:5174"}, // Support both Vite ports
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-API-Key"},
+		ExposeHeaders:    []string{"Content-Disposition", "X-Row-Warnings", "X-Row-Warnings-Detail", "X-Row-Warnings-Truncated"},
 		AllowCredentials: true,
 	}))
 
 	// Initialize handlers
 	excelService := services.NewExcelService()
 	provinceService := services.NewProvinceService()
-	h := handlers.NewHandler(db, excelService, provinceService)
+	authService := services.NewAuthService(jwtSecret(), tokenTTL())
+	if err := ensureAdminUser(db, authService); err != nil {
+		log.Fatal("Failed to create admin user:", err)
+	}
+	if err := ensureTaxTable(db); err != nil {
+		log.Fatal("Failed to create tax table:", err)
+	}
+	h := handlers.NewHandler(db, excelService, provinceService, authService)
 
 	// Routes
 	api := r.Group("/api")
@@ -49,24 +61,79 @@ func main() {
 		api.GET("/health", func(c *gin.Context) {
 			c.JSON(http.StatusOK, gin.H{"status": "ok"})
 		})
+		api.POST("/auth/login", h.Login)
+	}
+
+	// Everything else requires a signed-in user. Viewers and read-only API keys are read-only:
+	// they can list, view and download data but not upload, calculate, merge or run recipes.
+	protected := api.Group("")
+	protected.Use(h.RequireAuth())
+	admin := h.RequireRole(models.RoleAdmin)
+	writer := h.RequireWriter()
+	reviewer := h.RequireReviewer()
+	{
+		// Account routes
+		protected.GET("/auth/me", h.GetCurrentUser)
+		protected.PUT("/auth/password", h.ChangePassword)
+		protected.GET("/users", admin, h.GetUsers)
+		protected.POST("/users", admin, h.Audit("create_user"), h.CreateUser)
+		protected.PUT("/users/:id", admin, h.Audit("update_user"), h.UpdateUser)
+		protected.GET("/api-keys", admin, h.GetAPIKeys)
+		protected.POST("/api-keys", admin, h.Audit("create_api_key"), h.CreateAPIKey)
+		protected.DELETE("/api-keys/:id", admin, h.Audit("revoke_api_key"), h.RevokeAPIKey)
+		protected.GET("/audit-logs", admin, h.GetAuditLogs)
 		
 		// Excel processing routes
-		api.POST("/upload", h.UploadExcel)
-		api.POST("/upload-template", h.UploadTemplate)
-		api.GET("/sheets/:fileId", h.GetSheets)
-		api.GET("/data/:fileId/:sheetName", h.GetSheetData)
+		protected.POST("/upload", writer, h.Audit("upload"), h.UploadExcel)
+		protected.POST("/upload-template", admin, h.Audit("upload_template"), h.UploadTemplate)
+		protected.GET("/files", h.GetFiles)
+		protected.GET("/sheets/:fileId", h.GetSheets)
+		protected.GET("/data/:fileId/:sheetName", h.GetSheetData)
+		protected.GET("/pdf/:fileId", h.RenderSheetPDF)
 		
 		// Province and unit routes
-		api.GET("/provinces", h.GetProvinces)
-		api.GET("/units/:provinceId", h.GetUnits)
+		protected.GET("/provinces", h.GetProvinces)
+		protected.GET("/units/:provinceId", h.GetUnits)
+		
+		// Reporting period and submission routes
+		protected.GET("/periods", h.GetPeriods)
+		protected.POST("/periods", admin, h.Audit("create_period"), h.CreatePeriod)
+		protected.GET("/submissions", h.GetSubmissions)
+		protected.POST("/submissions", writer, h.Audit("submit"), h.CreateSubmission)
+		protected.PUT("/submissions/:id/status", reviewer, h.Audit("submission_status"), h.UpdateSubmissionStatus)
+		protected.POST("/submissions/:id/approve", reviewer, h.Audit("approve"), h.ApproveSubmission)
+		protected.POST("/submissions/:id/reject", reviewer, h.Audit("reject"), h.RejectSubmission)
+		protected.GET("/submissions/:id/history", h.GetSubmissionHistory)
+		protected.GET("/dashboard/submissions", h.GetSubmissionDashboard)
 		
 		// Calculation routes
-		api.POST("/calculate", h.CalculateColumns)
-		api.POST("/calculate-column", h.CalculateColumn)
-		api.POST("/calculate-rowwise", h.CalculateRowWise)
-		api.POST("/export", h.ExportExcel)
-		api.POST("/export-template", h.ExportToTemplate)
-		api.POST("/merge-download", h.MergeAndDownload)
+		protected.POST("/calculate", writer, h.Audit("calculate"), h.CalculateColumns)
+		protected.POST("/calculate-column", writer, h.Audit("calculate"), h.CalculateColumn)
+		protected.POST("/calculate-rowwise", writer, h.Audit("calculate"), h.CalculateRowWise)
+		protected.GET("/calculations", h.GetCalculations)
+		protected.GET("/calculations/:id", h.GetCalculation)
+		protected.GET("/calculations/:id/export", h.Audit("export"), h.ExportCalculation)
+		protected.POST("/export", h.Audit("export"), h.ExportExcel)
+		protected.POST("/export-workbook", h.Audit("export"), h.ExportWorkbook)
+		protected.POST("/consolidate", writer, h.Audit("consolidate"), h.ConsolidateFiles)
+		protected.POST("/export-template", writer, h.Audit("export_template"), h.ExportToTemplate)
+		protected.POST("/report-template", writer, h.Audit("report_template"), h.FillReportTemplate)
+		protected.POST("/merge-download", writer, h.Audit("merge"), h.MergeAndDownload)
+		
+		// Recipe routes
+		protected.GET("/recipes", h.GetRecipes)
+		protected.GET("/recipes/:id", h.GetRecipe)
+		protected.POST("/recipes", writer, h.Audit("create_recipe"), h.CreateRecipe)
+		protected.PUT("/recipes/:id", writer, h.Audit("update_recipe"), h.UpdateRecipe)
+		protected.DELETE("/recipes/:id", writer, h.Audit("delete_recipe"), h.DeleteRecipe)
+		protected.POST("/recipes/:id/run", writer, h.Audit("run_recipe"), h.RunRecipe)
+		
+		// Tax table routes
+		protected.GET("/tax-tables", h.GetTaxTables)
+		protected.GET("/tax-tables/:id", h.GetTaxTable)
+		protected.POST("/tax-tables", admin, h.Audit("create_tax_table"), h.CreateTaxTable)
+		protected.PUT("/tax-tables/:id", admin, h.Audit("update_tax_table"), h.UpdateTaxTable)
+		protected.DELETE("/tax-tables/:id", admin, h.Audit("delete_tax_table"), h.DeleteTaxTable)
 	}
 
 	log.Println("Server starting on :8080")
@@ -74,3 +141,102 @@ func main() {
 		log.Fatal("Failed to start server:", err)
 	}
 }
+
+// jwtSecret returns the token signing key from JWT_SECRET. Without it a random key is used,
+// which signs everyone out whenever the server restarts.
+func jwtSecret() []byte {
+	if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		return []byte(secret)
+	}
+
+	log.Println("WARNING: JWT_SECRET is not set, using a random key for this run")
+	return []byte(randomHex(32))
+}
+
+// tokenTTL returns how long access tokens stay valid, from TOKEN_TTL (e.g. "8h")
+func tokenTTL() time.Duration {
+	if value := os.Getenv("TOKEN_TTL"); value != "" {
+		ttl, err := time.ParseDuration(value)
+		if err == nil && ttl > 0 {
+			return ttl
+		}
+		log.Printf("WARNING: invalid TOKEN_TTL %q, using 12h", value)
+	}
+	return 12 * time.Hour
+}
+
+// ensureTaxTable creates the default tax table when there is none, so PIT formulas work on a
+// new database. Admins keep the rates current through the tax table routes.
+func ensureTaxTable(db *gorm.DB) error {
+	var count int64
+	if err := db.Model(&models.TaxTable{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	table := services.DefaultTaxTable()
+	if err := db.Create(&table).Error; err != nil {
+		return err
+	}
+	log.Printf("Created tax table %q", table.Version)
+	return nil
+}
+
+// ensureAdminUser makes sure there is an admin account. When there is none, the
+// ADMIN_USERNAME account is promoted, or created with ADMIN_PASSWORD or a password that is
+// generated and printed once.
+func ensureAdminUser(db *gorm.DB, auth *services.AuthService) error {
+	var count int64
+	if err := db.Model(&models.User{}).Where("role = ?", models.RoleAdmin).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	username := os.Getenv("ADMIN_USERNAME")
+	if username == "" {
+		username = "admin"
+	}
+
+	// Accounts created before roles existed have no admin yet
+	result := db.Model(&models.User{}).Where("username = ?", username).Updates(map[string]interface{}{"role": models.RoleAdmin, "province_id": nil, "unit_id": nil})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("Gave user %q the admin role", username)
+		return nil
+	}
+	password := os.Getenv("ADMIN_PASSWORD")
+	generated := password == ""
+	if generated {
+		password = randomHex(8)
+	}
+
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	if err := db.Create(&models.User{Username: username, PasswordHash: hash, FullName: "Administrator", Role: models.RoleAdmin, Active: true}).Error; err != nil {
+		return err
+	}
+
+	if generated {
+		log.Printf("Created user %q with password %q, change it after signing in", username, password)
+	} else {
+		log.Printf("Created user %q", username)
+	}
+	return nil
+}
+
+// randomHex returns n random bytes as a hex string
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		log.Fatal("Failed to generate random key:", err)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"excel-processor/internal/models"
+)
+
+// RequireRole rejects signed-in users whose role is not one of roles
+func (h *Handler) RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := currentUser(c)
+		for _, role := range roles {
+			if user != nil && user.Role == role {
+				c.Next()
+				return
+			}
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "You do not have permission to do this"})
+	}
+}
+
+// RequireWriter rejects viewers and read-only API keys, which may only list, view and download
+func (h *Handler) RequireWriter() gin.HandlerFunc {
+	return h.RequireRole(models.RoleAdmin, models.RoleProvinceOfficer, models.RoleUnitOfficer, models.RoleAPIKey)
+}
+
+// RequireReviewer lets only admins and province officers review submissions
+func (h *Handler) RequireReviewer() gin.HandlerFunc {
+	return h.RequireRole(models.RoleAdmin, models.RoleProvinceOfficer)
+}
+
+// scopedFiles returns a query over the files the signed-in user may see: admins and
+// unrestricted viewers see all files, everyone else the files of their unit or province.
+// Files without a province or unit (shared templates) are visible to all.
+func (h *Handler) scopedFiles(c *gin.Context) *gorm.DB {
+	query := h.db.Model(&models.ExcelFile{})
+	user := currentUser(c)
+	switch {
+	case user == nil:
+		return query.Where("1 = 0")
+	case user.Role == models.RoleAdmin:
+		return query
+	case user.UnitID != nil:
+		return query.Where("unit_id = ? OR (unit_id IS NULL AND province_id IS NULL)", *user.UnitID)
+	case user.ProvinceID != nil:
+		return query.Where("province_id = ? OR (unit_id IS NULL AND province_id IS NULL)", *user.ProvinceID)
+	}
+	return query
+}
+
+// scopedSubmissions returns a query over the submissions of the units the user may see
+func (h *Handler) scopedSubmissions(c *gin.Context) *gorm.DB {
+	query := h.db.Model(&models.Submission{})
+	user := currentUser(c)
+	switch {
+	case user == nil:
+		return query.Where("1 = 0")
+	case user.Role == models.RoleAdmin:
+		return query
+	case user.UnitID != nil:
+		return query.Where("unit_id = ?", *user.UnitID)
+	case user.ProvinceID != nil:
+		return query.Where("province_id = ?", *user.ProvinceID)
+	}
+	return query
+}
+
+// canAccessProvince reports whether the user may see the data of a province
+func canAccessProvince(user *models.User, provinceID uint) bool {
+	if user == nil {
+		return false
+	}
+	return user.Role == models.RoleAdmin || user.ProvinceID == nil || *user.ProvinceID == provinceID
+}
+
+// canAccessUnit reports whether the user may see or act for a unit
+func canAccessUnit(user *models.User, unit *models.Unit) bool {
+	if user == nil || unit == nil {
+		return false
+	}
+	if user.Role != models.RoleAdmin && user.UnitID != nil {
+		return *user.UnitID == unit.ID
+	}
+	return canAccessProvince(user, unit.ProvinceID)
+}
+
+// resolveUserScope checks that a role comes with the province or unit it needs and returns
+// the scope to store. A unit implies its province.
+func (h *Handler) resolveUserScope(role string, provinceID, unitID *uint) (*uint, *uint, error) {
+	if unitID != nil {
+		unit := h.province.GetUnitByID(*unitID)
+		if unit == nil {
+			return nil, nil, fmt.Errorf("unit not found")
+		}
+		if provinceID != nil && *provinceID != unit.ProvinceID {
+			return nil, nil, fmt.Errorf("unit does not belong to the given province")
+		}
+		provinceID = &unit.ProvinceID
+	}
+	if provinceID != nil && h.province.GetProvinceByID(*provinceID) == nil {
+		return nil, nil, fmt.Errorf("province not found")
+	}
+
+	switch role {
+	case models.RoleAdmin:
+		return nil, nil, nil
+	case models.RoleProvinceOfficer:
+		if provinceID == nil {
+			return nil, nil, fmt.Errorf("a province officer needs a province")
+		}
+		return provinceID, nil, nil
+	case models.RoleUnitOfficer:
+		if unitID == nil {
+			return nil, nil, fmt.Errorf("a unit officer needs a unit")
+		}
+	}
+	return provinceID, unitID, nil
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"excel-processor/internal/models"
+)
+
+// contextAs returns a request context signed in as user, or anonymous if user is nil
+func contextAs(user *models.User) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	if user != nil {
+		c.Set(currentUserKey, user)
+	}
+	return c
+}
+
+// scopeUsers are the kinds of signed-in users whose scope the tests check
+var scopeUsers = []struct {
+	name string
+	user *models.User
+}{
+	{"admin", &models.User{Role: models.RoleAdmin}},
+	{"province officer", &models.User{Role: models.RoleProvinceOfficer, ProvinceID: uintPtr(1)}},
+	{"unit officer", &models.User{Role: models.RoleUnitOfficer, ProvinceID: uintPtr(1), UnitID: uintPtr(2)}},
+	{"unrestricted viewer", &models.User{Role: models.RoleViewer}},
+	{"province viewer", &models.User{Role: models.RoleViewer, ProvinceID: uintPtr(2)}},
+	{"unit API key", &models.User{Role: models.RoleAPIKey, ProvinceID: uintPtr(2), UnitID: uintPtr(6)}},
+	{"anonymous", nil},
+}
+
+func TestScopedFiles(t *testing.T) {
+	h := newTestHandler(t)
+	seed(t, h,
+		&models.ExcelFile{ID: 1, FileName: "shared.xlsx", FilePath: "templates/1.xlsx"},
+		&models.ExcelFile{ID: 2, FileName: "unit2.xlsx", FilePath: "uploads/2.xlsx", ProvinceID: uintPtr(1), UnitID: uintPtr(2)},
+		&models.ExcelFile{ID: 3, FileName: "unit3.xlsx", FilePath: "uploads/3.xlsx", ProvinceID: uintPtr(1), UnitID: uintPtr(3)},
+		&models.ExcelFile{ID: 4, FileName: "province1.xlsx", FilePath: "uploads/4.xlsx", ProvinceID: uintPtr(1)},
+		&models.ExcelFile{ID: 5, FileName: "unit6.xlsx", FilePath: "uploads/5.xlsx", ProvinceID: uintPtr(2), UnitID: uintPtr(6)},
+	)
+
+	want := map[string][]uint{
+		"admin":               {1, 2, 3, 4, 5},
+		"province officer":    {1, 2, 3, 4},
+		"unit officer":        {1, 2},
+		"unrestricted viewer": {1, 2, 3, 4, 5},
+		"province viewer":     {1, 5},
+		"unit API key":        {1, 5},
+		"anonymous":           {},
+	}
+	for _, tt := range scopeUsers {
+		var ids []uint
+		if err := h.scopedFiles(contextAs(tt.user)).Order("id").Pluck("id", &ids).Error; err != nil {
+			t.Fatalf("%s: query failed: %v", tt.name, err)
+		}
+		if !slices.Equal(ids, want[tt.name]) {
+			t.Errorf("%s sees files %v, want %v", tt.name, ids, want[tt.name])
+		}
+	}
+}
+
+func TestScopedSubmissions(t *testing.T) {
+	h := newTestHandler(t)
+	seed(t, h,
+		&models.Submission{ID: 1, UnitID: 2, ProvinceID: 1, PeriodID: 1},
+		&models.Submission{ID: 2, UnitID: 3, ProvinceID: 1, PeriodID: 1},
+		&models.Submission{ID: 3, UnitID: 6, ProvinceID: 2, PeriodID: 1},
+	)
+
+	want := map[string][]uint{
+		"admin":               {1, 2, 3},
+		"province officer":    {1, 2},
+		"unit officer":        {1},
+		"unrestricted viewer": {1, 2, 3},
+		"province viewer":     {3},
+		"unit API key":        {3},
+		"anonymous":           {},
+	}
+	for _, tt := range scopeUsers {
+		var ids []uint
+		if err := h.scopedSubmissions(contextAs(tt.user)).Order("id").Pluck("id", &ids).Error; err != nil {
+			t.Fatalf("%s: query failed: %v", tt.name, err)
+		}
+		if !slices.Equal(ids, want[tt.name]) {
+			t.Errorf("%s sees submissions %v, want %v", tt.name, ids, want[tt.name])
+		}
+	}
+}
+
+func TestCanAccessUnit(t *testing.T) {
+	unit2 := &models.Unit{ID: 2, ProvinceID: 1}
+	unit6 := &models.Unit{ID: 6, ProvinceID: 2}
+	want := map[string][2]bool{
+		"admin":               {true, true},
+		"province officer":    {true, false},
+		"unit officer":        {true, false},
+		"unrestricted viewer": {true, true},
+		"province viewer":     {false, true},
+		"unit API key":        {false, true},
+		"anonymous":           {false, false},
+	}
+	for _, tt := range scopeUsers {
+		got := [2]bool{canAccessUnit(tt.user, unit2), canAccessUnit(tt.user, unit6)}
+		if got != want[tt.name] {
+			t.Errorf("%s: canAccessUnit(unit 2, unit 6) = %v, want %v", tt.name, got, want[tt.name])
+		}
+	}
+}
+
+func TestWriterAndReviewerRoles(t *testing.T) {
+	h := newTestHandler(t)
+	tests := []struct {
+		role       string
+		wantWrite  int
+		wantReview int
+	}{
+		{models.RoleAdmin, http.StatusOK, http.StatusOK},
+		{models.RoleProvinceOfficer, http.StatusOK, http.StatusOK},
+		{models.RoleUnitOfficer, http.StatusOK, http.StatusForbidden},
+		{models.RoleAPIKey, http.StatusOK, http.StatusForbidden},
+		{models.RoleViewer, http.StatusForbidden, http.StatusForbidden},
+	}
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	for _, tt := range tests {
+		r := testRouter(&models.User{Role: tt.role})
+		r.POST("/write", h.RequireWriter(), ok)
+		r.POST("/review", h.RequireReviewer(), ok)
+		if w := serveJSON(r, http.MethodPost, "/write", nil); w.Code != tt.wantWrite {
+			t.Errorf("%s on a writer route: status = %d, want %d", tt.role, w.Code, tt.wantWrite)
+		}
+		if w := serveJSON(r, http.MethodPost, "/review", nil); w.Code != tt.wantReview {
+			t.Errorf("%s on a reviewer route: status = %d, want %d", tt.role, w.Code, tt.wantReview)
+		}
+	}
+
+	r := testRouter(nil)
+	r.POST("/write", h.RequireWriter(), ok)
+	if w := serveJSON(r, http.MethodPost, "/write", nil); w.Code != http.StatusForbidden {
+		t.Errorf("anonymous on a writer route: status = %d, want 403", w.Code)
+	}
+}
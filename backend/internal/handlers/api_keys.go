@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"excel-processor/internal/models"
+)
+
+// apiKeyHeader is the request header that carries an API key
+const apiKeyHeader = "X-API-Key"
+
+// authenticateAPIKey looks up an API key and returns the user it acts as. A write key has the
+// api_key role, which upload routes accept but review and admin routes do not; a read-only key
+// acts as a viewer. Both keep the key's unit or province, so the usual scope checks apply to
+// scripts too.
+func (h *Handler) authenticateAPIKey(key string) (*models.User, error) {
+	var apiKey models.APIKey
+	if err := h.db.Where("key_hash = ?", h.auth.HashAPIKey(strings.TrimSpace(key))).First(&apiKey).Error; err != nil {
+		return nil, fmt.Errorf("invalid API key")
+	}
+
+	now := time.Now()
+	if apiKey.RevokedAt != nil {
+		return nil, fmt.Errorf("API key has been revoked")
+	}
+	if apiKey.ExpiresAt != nil && now.After(*apiKey.ExpiresAt) {
+		return nil, fmt.Errorf("API key has expired")
+	}
+	h.db.Model(&apiKey).UpdateColumn("last_used_at", now)
+
+	user := &models.User{
+		Username:   "apikey:" + apiKey.Name,
+		FullName:   apiKey.Name,
+		Role:       models.RoleViewer,
+		ProvinceID: apiKey.ProvinceID,
+		UnitID:     apiKey.UnitID,
+		Active:     true,
+	}
+	if !apiKey.ReadOnly {
+		user.Role = models.RoleAPIKey
+	}
+	return user, nil
+}
+
+// GetAPIKeys lists the API keys, including revoked and expired ones
+func (h *Handler) GetAPIKeys(c *gin.Context) {
+	var keys []models.APIKey
+	if err := h.db.Order("created_at DESC").Find(&keys).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load API keys"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"api_keys": keys})
+}
+
+// CreateAPIKey creates an API key and returns it. The key cannot be retrieved again later.
+func (h *Handler) CreateAPIKey(c *gin.Context) {
+	var req models.APIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.ExpiresAt != nil && req.ExpiresAt.Before(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Expiry must be in the future"})
+		return
+	}
+
+	apiKey := models.APIKey{
+		Name:      strings.TrimSpace(req.Name),
+		UnitID:    req.UnitID,
+		ReadOnly:  req.ReadOnly,
+		ExpiresAt: req.ExpiresAt,
+		CreatedBy: actorName(c),
+	}
+	if req.UnitID != nil {
+		unit := h.province.GetUnitByID(*req.UnitID)
+		if unit == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unit not found"})
+			return
+		}
+		apiKey.ProvinceID = &unit.ProvinceID
+	}
+
+	key, hash, err := h.auth.GenerateAPIKey()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate API key"})
+		return
+	}
+	apiKey.KeyHash = hash
+	apiKey.Prefix = key[:10]
+
+	if err := h.db.Create(&apiKey).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save API key"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "API key created, store it now as it will not be shown again",
+		"key":     key,
+		"api_key": apiKey,
+	})
+}
+
+// RevokeAPIKey revokes an API key; it stops working immediately
+func (h *Handler) RevokeAPIKey(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid API key ID"})
+		return
+	}
+
+	var apiKey models.APIKey
+	if err := h.db.First(&apiKey, uint(id)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	}
+	if apiKey.RevokedAt == nil {
+		now := time.Now()
+		apiKey.RevokedAt = &now
+		if err := h.db.Model(&apiKey).Update("revoked_at", now).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke API key"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked", "api_key": apiKey})
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"excel-processor/internal/models"
+)
+
+// seedAPIKey stores an API key and returns the key to send
+func seedAPIKey(t *testing.T, h *Handler, apiKey models.APIKey) string {
+	t.Helper()
+	key, hash, err := h.auth.GenerateAPIKey()
+	if err != nil {
+		t.Fatalf("GenerateAPIKey returned %v", err)
+	}
+	apiKey.KeyHash, apiKey.Prefix = hash, key[:10]
+	seed(t, h, &apiKey)
+	return key
+}
+
+// serveWithAPIKey sends a request carrying key in the API key header
+func serveWithAPIKey(r http.Handler, method, target, key string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	if key != "" {
+		req.Header.Set(apiKeyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestAPIKeyAccess(t *testing.T) {
+	h := newTestHandler(t)
+	past, future := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	writeKey := seedAPIKey(t, h, models.APIKey{Name: "hr-sync", UnitID: uintPtr(2), ProvinceID: uintPtr(1), ExpiresAt: &future})
+	readKey := seedAPIKey(t, h, models.APIKey{Name: "dashboard", ReadOnly: true})
+	revokedKey := seedAPIKey(t, h, models.APIKey{Name: "old", RevokedAt: &past})
+	expiredKey := seedAPIKey(t, h, models.APIKey{Name: "temp", ExpiresAt: &past})
+
+	r := gin.New()
+	r.Use(h.RequireAuth())
+	ok := func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"user": currentUser(c)}) }
+	r.GET("/read", ok)
+	r.POST("/write", h.RequireWriter(), ok)
+	r.POST("/review", h.RequireReviewer(), ok)
+	r.GET("/admin", h.RequireRole(models.RoleAdmin), ok)
+
+	tests := []struct {
+		name string
+		key  string
+		want map[string]int
+	}{
+		{"write key", writeKey, map[string]int{"GET /read": 200, "POST /write": 200, "POST /review": 403, "GET /admin": 403}},
+		{"read-only key", readKey, map[string]int{"GET /read": 200, "POST /write": 403, "POST /review": 403, "GET /admin": 403}},
+		{"revoked key", revokedKey, map[string]int{"GET /read": 401, "POST /write": 401}},
+		{"expired key", expiredKey, map[string]int{"GET /read": 401, "POST /write": 401}},
+		{"unknown key", "xp_" + strings.Repeat("0", 64), map[string]int{"GET /read": 401}},
+	}
+	for _, tt := range tests {
+		for route, want := range tt.want {
+			method, target, _ := strings.Cut(route, " ")
+			if w := serveWithAPIKey(r, method, target, tt.key); w.Code != want {
+				t.Errorf("%s: %s status = %d, want %d: %s", tt.name, route, w.Code, want, w.Body.String())
+			}
+		}
+	}
+
+	// Keys act within their unit and record when they were used
+	w := serveWithAPIKey(r, http.MethodGet, "/read", " "+writeKey+" ")
+	var resp struct {
+		User models.User `json:"user"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.User.Username != "apikey:hr-sync" || derefUint(resp.User.UnitID) != 2 || derefUint(resp.User.ProvinceID) != 1 {
+		t.Errorf("write key acts as %s in unit %d, province %d; want apikey:hr-sync in unit 2, province 1", resp.User.Username, derefUint(resp.User.UnitID), derefUint(resp.User.ProvinceID))
+	}
+	var used models.APIKey
+	h.db.Where("name = ?", "hr-sync").First(&used)
+	if used.LastUsedAt == nil {
+		t.Error("last use of the key was not recorded")
+	}
+}
+
+func TestCreateAndRevokeAPIKey(t *testing.T) {
+	h := newTestHandler(t)
+	r := testRouter(&models.User{Username: "admin", Role: models.RoleAdmin})
+	r.POST("/api-keys", h.CreateAPIKey)
+	r.DELETE("/api-keys/:id", h.RevokeAPIKey)
+	r.GET("/check", h.RequireAuth(), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	past := time.Now().Add(-time.Minute)
+	for name, req := range map[string]models.APIKeyRequest{
+		"unknown unit": {Name: "sync", UnitID: uintPtr(999)},
+		"past expiry":  {Name: "sync", ExpiresAt: &past},
+		"no name":      {},
+	} {
+		if w := serveJSON(r, http.MethodPost, "/api-keys", req); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", name, w.Code)
+		}
+	}
+
+	w := serveJSON(r, http.MethodPost, "/api-keys", models.APIKeyRequest{Name: " hr-sync ", UnitID: uintPtr(7)})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: status = %d, want 201: %s", w.Code, w.Body.String())
+	}
+	var created struct {
+		Key    string        `json:"key"`
+		APIKey models.APIKey `json:"api_key"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &created)
+	if created.APIKey.Name != "hr-sync" || derefUint(created.APIKey.ProvinceID) != 2 || created.APIKey.CreatedBy != "admin" {
+		t.Errorf("created key %q in province %d by %q, want hr-sync in province 2 by admin", created.APIKey.Name, derefUint(created.APIKey.ProvinceID), created.APIKey.CreatedBy)
+	}
+	if !strings.HasPrefix(created.Key, created.APIKey.Prefix) || strings.Contains(w.Body.String(), h.auth.HashAPIKey(created.Key)) {
+		t.Error("the response must carry the key once and never its hash")
+	}
+
+	if w := serveWithAPIKey(r, http.MethodGet, "/check", created.Key); w.Code != http.StatusOK {
+		t.Fatalf("new key: status = %d, want 200", w.Code)
+	}
+	target := fmt.Sprintf("/api-keys/%d", created.APIKey.ID)
+	if w := serveJSON(r, http.MethodDelete, target, nil); w.Code != http.StatusOK {
+		t.Fatalf("revoke: status = %d, want 200", w.Code)
+	}
+	if w := serveWithAPIKey(r, http.MethodGet, "/check", created.Key); w.Code != http.StatusUnauthorized {
+		t.Errorf("revoked key: status = %d, want 401", w.Code)
+	}
+	if w := serveJSON(r, http.MethodDelete, "/api-keys/999", nil); w.Code != http.StatusNotFound {
+		t.Errorf("revoking an unknown key: status = %d, want 404", w.Code)
+	}
+}
@@ -0,0 +1,277 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+
+	"excel-processor/internal/models"
+)
+
+const (
+	// auditFilesKey is the gin context key of the file IDs a request used
+	auditFilesKey = "auditFiles"
+	// maxAuditParams caps the stored request parameters; merge requests can carry a lot of data
+	maxAuditParams = 64 << 10
+	// maxAuditErrorBody is how much of an error response is kept to extract its message
+	maxAuditErrorBody = 4 << 10
+	// redactedValue replaces secrets in the stored parameters
+	redactedValue = "[redacted]"
+)
+
+// secretParams are parameter names whose values are never stored in the audit log
+var secretParams = map[string]bool{
+	"password":         true,
+	"current_password": true,
+	"new_password":     true,
+	"api_key":          true,
+	"token":            true,
+	"secret":           true,
+}
+
+// auditWriter hashes the response as it is sent and keeps the start of error responses
+type auditWriter struct {
+	gin.ResponseWriter
+	hash hash.Hash
+	head bytes.Buffer
+}
+
+func (w *auditWriter) Write(b []byte) (int, error) {
+	w.hash.Write(b)
+	if w.Status() >= http.StatusBadRequest && w.head.Len() < maxAuditErrorBody {
+		w.head.Write(b[:min(len(b), maxAuditErrorBody-w.head.Len())])
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *auditWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// auditFiles records the IDs of files the request read or created in its audit entry
+func auditFiles(c *gin.Context, ids ...uint) {
+	value, _ := c.Get(auditFilesKey)
+	list, _ := value.([]uint)
+	for _, id := range ids {
+		if id != 0 {
+			list = append(list, id)
+		}
+	}
+	c.Set(auditFilesKey, list)
+}
+
+// Audit records the request in the audit log under action, together with who made it, its
+// parameters, the files it used and a hash of what was sent back
+func (h *Handler) Audit(action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var body []byte
+		if !strings.HasPrefix(c.ContentType(), "multipart/") && c.Request.Body != nil {
+			body, _ = io.ReadAll(c.Request.Body)
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		writer := &auditWriter{ResponseWriter: c.Writer, hash: sha256.New()}
+		c.Writer = writer
+		c.Next()
+
+		entry := models.AuditLog{
+			Action:   action,
+			Actor:    actorName(c),
+			Method:   c.Request.Method,
+			Path:     c.Request.URL.Path,
+			FileIDs:  []uint{},
+			Params:   auditParams(c, body),
+			Status:   writer.Status(),
+			ClientIP: c.ClientIP(),
+		}
+		if user := currentUser(c); user != nil && user.ID != 0 {
+			entry.UserID = &user.ID
+		}
+		if value, exists := c.Get(auditFilesKey); exists {
+			entry.FileIDs = value.([]uint)
+		}
+		entry.InputHash = uploadHash(c)
+
+		if entry.Status < http.StatusBadRequest {
+			entry.OutputHash = hex.EncodeToString(writer.hash.Sum(nil))
+			if _, params, err := mime.ParseMediaType(writer.Header().Get("Content-Disposition")); err == nil {
+				entry.OutputName = params["filename"]
+			}
+		} else {
+			var response struct {
+				Error string `json:"error"`
+			}
+			if json.Unmarshal(writer.head.Bytes(), &response) == nil {
+				entry.Error = response.Error
+			}
+		}
+
+		// The response has already been sent, so a failure can only be logged
+		if err := h.db.Create(&entry).Error; err != nil {
+			log.Printf("Failed to write audit log for %s %s: %v", entry.Method, entry.Path, err)
+		}
+	}
+}
+
+// auditParams returns the query, form fields, uploaded file names and JSON body as one
+// JSON object, with passwords and other secrets redacted
+func auditParams(c *gin.Context, body []byte) string {
+	params := make(map[string]interface{})
+	if query := c.Request.URL.Query(); len(query) > 0 {
+		params["query"] = redactValues(query)
+	}
+	if form := c.Request.MultipartForm; form != nil {
+		if len(form.Value) > 0 {
+			params["form"] = redactValues(form.Value)
+		}
+		names := make([]string, 0)
+		for _, headers := range form.File {
+			for _, header := range headers {
+				names = append(names, header.Filename)
+			}
+		}
+		if len(names) > 0 {
+			params["files"] = names
+		}
+	}
+	if len(body) > 0 {
+		// Numbers are kept as written, so large IDs and amounts are not rounded
+		var decoded interface{}
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+		if json.Valid(body) && decoder.Decode(&decoded) == nil {
+			redactJSON(decoded)
+			body, _ = json.Marshal(decoded)
+		} else if form, err := url.ParseQuery(string(body)); err == nil && c.ContentType() == "application/x-www-form-urlencoded" {
+			body = []byte(url.Values(redactValues(form)).Encode())
+		}
+
+		if len(body) > maxAuditParams {
+			// Cut on a character boundary so Vietnamese text is not split in the middle
+			end := maxAuditParams
+			for end > 0 && !utf8.RuneStart(body[end]) {
+				end--
+			}
+			params["body_truncated"] = strings.ToValidUTF8(string(body[:end]), "")
+		} else if json.Valid(body) {
+			params["body"] = json.RawMessage(body)
+		} else {
+			params["body"] = strings.ToValidUTF8(string(body), "")
+		}
+	}
+	if len(params) == 0 {
+		return ""
+	}
+
+	data, err := json.Marshal(params)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// redactValues copies query or form values, replacing those of secret parameters
+func redactValues(values map[string][]string) map[string][]string {
+	redacted := make(map[string][]string, len(values))
+	for name, list := range values {
+		if secretParams[strings.ToLower(name)] {
+			list = []string{redactedValue}
+		}
+		redacted[name] = list
+	}
+	return redacted
+}
+
+// redactJSON replaces the values of secret keys anywhere in a decoded JSON value
+func redactJSON(value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if secretParams[strings.ToLower(key)] {
+				v[key] = redactedValue
+				continue
+			}
+			redactJSON(item)
+		}
+	case []interface{}:
+		for _, item := range v {
+			redactJSON(item)
+		}
+	}
+}
+
+// uploadHash returns the SHA-256 of the file uploaded in the "file" form field, if any
+func uploadHash(c *gin.Context) string {
+	if c.Request.MultipartForm == nil || len(c.Request.MultipartForm.File["file"]) == 0 {
+		return ""
+	}
+	file, err := c.Request.MultipartForm.File["file"][0].Open()
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	sum := sha256.New()
+	if _, err := io.Copy(sum, file); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+// GetAuditLogs returns audit entries, newest first, filtered by action, actor, file, hash
+// and date range
+func (h *Handler) GetAuditLogs(c *gin.Context) {
+	var req models.AuditQuery
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Limit == 0 {
+		req.Limit = 100
+	}
+
+	query := h.db.Model(&models.AuditLog{})
+	if req.Action != "" {
+		query = query.Where("action = ?", req.Action)
+	}
+	if req.Actor != "" {
+		query = query.Where("actor = ?", req.Actor)
+	}
+	if req.FileID != 0 {
+		query = query.Where("EXISTS (SELECT 1 FROM json_each(audit_logs.file_ids) WHERE json_each.value = ?)", req.FileID)
+	}
+	if req.Hash != "" {
+		hash := strings.ToLower(strings.TrimSpace(req.Hash))
+		query = query.Where("input_hash = ? OR output_hash = ?", hash, hash)
+	}
+	if req.From != nil {
+		query = query.Where("created_at >= ?", *req.From)
+	}
+	if req.To != nil {
+		query = query.Where("created_at < ?", req.To.AddDate(0, 0, 1))
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load audit log"})
+		return
+	}
+	var entries []models.AuditLog
+	if err := query.Order("id DESC").Limit(req.Limit).Offset(req.Offset).Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load audit log"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"total": total, "entries": entries})
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+func auditTestContext(method, target, contentType string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(method, target, nil)
+	if contentType != "" {
+		c.Request.Header.Set("Content-Type", contentType)
+	}
+	return c
+}
+
+func TestAuditParamsTruncatesOnCharacterBoundary(t *testing.T) {
+	// "x" shifts the two-byte "ư" so the byte limit falls inside a character
+	body := []byte("x" + strings.Repeat("ư", maxAuditParams))
+	c := auditTestContext("POST", "/api/upload", "text/plain")
+
+	var params map[string]string
+	if err := json.Unmarshal([]byte(auditParams(c, body)), &params); err != nil {
+		t.Fatalf("auditParams returned invalid JSON: %v", err)
+	}
+	truncated := params["body_truncated"]
+	if !utf8.ValidString(truncated) || strings.ContainsRune(truncated, utf8.RuneError) {
+		t.Errorf("body_truncated holds a broken character at %q", truncated[len(truncated)-8:])
+	}
+	if len(truncated) > maxAuditParams || len(truncated) < maxAuditParams-utf8.UTFMax {
+		t.Errorf("body_truncated is %d bytes, want just under %d", len(truncated), maxAuditParams)
+	}
+}
+
+func TestAuditParamsRedactsSecrets(t *testing.T) {
+	tests := []struct {
+		name        string
+		target      string
+		contentType string
+		body        string
+		secret      string
+		want        []string
+	}{
+		{"json body", "/api/users", "application/json", `{"username":"an","password":"hunter22","profile":{"api_key":"k-123"}}`, "hunter22", []string{`"password":"[redacted]"`, `"api_key":"[redacted]"`, `"username":"an"`}},
+		{"nested json", "/api/users", "application/json", `{"items":[{"token":"t-456"}],"amount":12345678901234567890}`, "t-456", []string{`"token":"[redacted]"`, "12345678901234567890"}},
+		{"form body", "/api/auth/login", "application/x-www-form-urlencoded", "username=an&password=hunter22", "hunter22", []string{"password=%5Bredacted%5D", "username=an"}},
+		{"query", "/api/files?token=t-789&page=2", "", "", "t-789", []string{`"token":["[redacted]"]`, `"page":["2"]`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := auditTestContext("POST", tt.target, tt.contentType)
+			got := auditParams(c, []byte(tt.body))
+			if strings.Contains(got, tt.secret) {
+				t.Errorf("auditParams kept the secret: %s", got)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("auditParams = %s, want it to contain %s", got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"excel-processor/internal/models"
+)
+
+// currentUserKey is the gin context key of the signed-in user
+const currentUserKey = "currentUser"
+
+// RequireAuth rejects requests without a valid bearer token or API key and stores the
+// signed-in user in the context for the handlers
+func (h *Handler) RequireAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if key := c.GetHeader(apiKeyHeader); key != "" {
+			user, err := h.authenticateAPIKey(key)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+				return
+			}
+			c.Set(currentUserKey, user)
+			c.Next()
+			return
+		}
+
+		header := c.GetHeader("Authorization")
+		token, found := strings.CutPrefix(header, "Bearer ")
+		if !found || strings.TrimSpace(token) == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+			return
+		}
+
+		userID, _, err := h.auth.ParseToken(strings.TrimSpace(token))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+			return
+		}
+
+		// Load the user on every request so disabled accounts lose access immediately
+		var user models.User
+		if err := h.db.First(&user, userID).Error; err != nil || !user.Active {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Account not found or disabled"})
+			return
+		}
+
+		c.Set(currentUserKey, &user)
+		c.Next()
+	}
+}
+
+// currentUser returns the user set by RequireAuth
+func currentUser(c *gin.Context) *models.User {
+	if value, exists := c.Get(currentUserKey); exists {
+		if user, ok := value.(*models.User); ok {
+			return user
+		}
+	}
+	return nil
+}
+
+// actorName returns the username recorded as the actor of changes made by the request
+func actorName(c *gin.Context) string {
+	if user := currentUser(c); user != nil {
+		return user.Username
+	}
+	return ""
+}
+
+// Login checks a username and password and returns an access token
+func (h *Handler) Login(c *gin.Context) {
+	var req models.LoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := h.db.Where("username = ?", strings.TrimSpace(req.Username)).First(&user).Error; err != nil ||
+		!h.auth.CheckPassword(user.PasswordHash, req.Password) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid username or password"})
+		return
+	}
+	if !user.Active {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account is disabled"})
+		return
+	}
+
+	token, expiresAt, err := h.auth.IssueToken(user.ID, user.Username)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue token"})
+		return
+	}
+
+	now := time.Now()
+	user.LastLoginAt = &now
+	h.db.Model(&user).Update("last_login_at", now)
+
+	c.JSON(http.StatusOK, models.LoginResponse{Token: token, ExpiresAt: expiresAt, User: user})
+}
+
+// GetCurrentUser returns the signed-in user
+func (h *Handler) GetCurrentUser(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"user": currentUser(c)})
+}
+
+// ChangePassword changes the signed-in user's password after checking the current one
+func (h *Handler) ChangePassword(c *gin.Context) {
+	var req models.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user := currentUser(c)
+	if !h.auth.CheckPassword(user.PasswordHash, req.CurrentPassword) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Current password is incorrect"})
+		return
+	}
+
+	hash, err := h.auth.HashPassword(req.NewPassword)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.db.Model(user).Update("password_hash", hash).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully"})
+}
+
+// GetUsers lists the user accounts
+func (h *Handler) GetUsers(c *gin.Context) {
+	var users []models.User
+	if err := h.db.Order("username").Find(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load users"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"users": users})
+}
+
+// CreateUser creates a user account
+func (h *Handler) CreateUser(c *gin.Context) {
+	var req models.UserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	provinceID, unitID, err := h.resolveUserScope(req.Role, req.ProvinceID, req.UnitID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hash, err := h.auth.HashPassword(req.Password)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	username := strings.TrimSpace(req.Username)
+	var count int64
+	h.db.Model(&models.User{}).Where("username = ?", username).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Username already exists"})
+		return
+	}
+
+	user := models.User{
+		Username:     username,
+		PasswordHash: hash,
+		FullName:     req.FullName,
+		Role:         req.Role,
+		ProvinceID:   provinceID,
+		UnitID:       unitID,
+		Active:       true,
+	}
+	if err := h.db.Create(&user).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "User created successfully", "user": user})
+}
+
+// UpdateUser changes the role, scope, name or status of a user account, or resets its password
+func (h *Handler) UpdateUser(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req models.UserUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, uint(id)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	// Admins cannot lock themselves out
+	if user.ID == currentUser(c).ID && ((req.Role != "" && req.Role != models.RoleAdmin) || (req.Active != nil && !*req.Active)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot remove your own admin access"})
+		return
+	}
+
+	if req.Role != "" {
+		provinceID, unitID, err := h.resolveUserScope(req.Role, req.ProvinceID, req.UnitID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		user.Role, user.ProvinceID, user.UnitID = req.Role, provinceID, unitID
+	}
+	if req.FullName != nil {
+		user.FullName = *req.FullName
+	}
+	if req.Active != nil {
+		user.Active = *req.Active
+	}
+	if req.Password != "" {
+		hash, err := h.auth.HashPassword(req.Password)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		user.PasswordHash = hash
+	}
+
+	if err := h.db.Save(&user).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User updated successfully", "user": user})
+}
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"excel-processor/internal/models"
+)
+
+// seedUser stores an account with the given password and returns it
+func seedUser(t *testing.T, h *Handler, user models.User, password string) models.User {
+	t.Helper()
+	hash, err := h.auth.HashPassword(password)
+	if err != nil {
+		t.Fatalf("HashPassword returned %v", err)
+	}
+	user.PasswordHash = hash
+	seed(t, h, &user)
+	return user
+}
+
+func TestLogin(t *testing.T) {
+	h := newTestHandler(t)
+	seedUser(t, h, models.User{Username: "an", Role: models.RoleUnitOfficer, UnitID: uintPtr(2), Active: true}, "mật khẩu đúng")
+	disabled := seedUser(t, h, models.User{Username: "binh", Role: models.RoleViewer, Active: true}, "mật khẩu đúng")
+	h.db.Model(&disabled).Update("active", false)
+	r := testRouter(nil)
+	r.POST("/auth/login", h.Login)
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+		want     int
+	}{
+		{"right password", " an ", "mật khẩu đúng", http.StatusOK},
+		{"wrong password", "an", "mật khẩu sai", http.StatusUnauthorized},
+		{"unknown user", "chi", "mật khẩu đúng", http.StatusUnauthorized},
+		{"disabled account", "binh", "mật khẩu đúng", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		w := serveJSON(r, http.MethodPost, "/auth/login", models.LoginRequest{Username: tt.username, Password: tt.password})
+		if w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d: %s", tt.name, w.Code, tt.want, w.Body.String())
+			continue
+		}
+		if w.Code != http.StatusOK {
+			continue
+		}
+		var resp models.LoginResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("login response is not JSON: %v", err)
+		}
+		if userID, _, err := h.auth.ParseToken(resp.Token); err != nil || userID != resp.User.ID {
+			t.Errorf("token is for user %d (%v), want %d", userID, err, resp.User.ID)
+		}
+		if resp.User.LastLoginAt == nil {
+			t.Error("last login time was not recorded")
+		}
+	}
+}
+
+func TestRequireAuth(t *testing.T) {
+	h := newTestHandler(t)
+	active := seedUser(t, h, models.User{Username: "an", Role: models.RoleUnitOfficer, UnitID: uintPtr(2), Active: true}, "mật khẩu đúng")
+	disabled := seedUser(t, h, models.User{Username: "binh", Role: models.RoleViewer, Active: true}, "mật khẩu đúng")
+	activeToken, _, _ := h.auth.IssueToken(active.ID, active.Username)
+	disabledToken, _, _ := h.auth.IssueToken(disabled.ID, disabled.Username)
+	missingToken, _, _ := h.auth.IssueToken(999, "ghost")
+	// Disabled after the token was issued
+	h.db.Model(&disabled).Update("active", false)
+
+	r := gin.New()
+	r.GET("/me", h.RequireAuth(), h.GetCurrentUser)
+
+	tests := []struct {
+		name          string
+		authorization string
+		want          int
+	}{
+		{"valid token", "Bearer " + activeToken, http.StatusOK},
+		{"no header", "", http.StatusUnauthorized},
+		{"not a bearer token", "Basic " + activeToken, http.StatusUnauthorized},
+		{"tampered token", "Bearer " + activeToken + "x", http.StatusUnauthorized},
+		{"disabled account", "Bearer " + disabledToken, http.StatusUnauthorized},
+		{"deleted account", "Bearer " + missingToken, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		if tt.authorization != "" {
+			req.Header.Set("Authorization", tt.authorization)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d: %s", tt.name, w.Code, tt.want, w.Body.String())
+		}
+		if w.Code == http.StatusOK {
+			var resp struct {
+				User models.User `json:"user"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &resp)
+			if resp.User.ID != active.ID {
+				t.Errorf("%s: signed in as user %d, want %d", tt.name, resp.User.ID, active.ID)
+			}
+		}
+	}
+}
+
+func TestResolveUserScope(t *testing.T) {
+	h := newTestHandler(t)
+	tests := []struct {
+		name         string
+		role         string
+		provinceID   *uint
+		unitID       *uint
+		wantProvince uint
+		wantUnit     uint
+		wantErr      bool
+	}{
+		{"admin scope is dropped", models.RoleAdmin, uintPtr(1), nil, 0, 0, false},
+		{"province officer", models.RoleProvinceOfficer, uintPtr(2), nil, 2, 0, false},
+		{"province officer without a province", models.RoleProvinceOfficer, nil, nil, 0, 0, true},
+		{"unit implies its province", models.RoleUnitOfficer, nil, uintPtr(7), 2, 7, false},
+		{"unit officer without a unit", models.RoleUnitOfficer, uintPtr(1), nil, 0, 0, true},
+		{"unit of another province", models.RoleUnitOfficer, uintPtr(1), uintPtr(7), 0, 0, true},
+		{"unknown unit", models.RoleUnitOfficer, nil, uintPtr(999), 0, 0, true},
+		{"unknown province", models.RoleViewer, uintPtr(999), nil, 0, 0, true},
+		{"unrestricted viewer", models.RoleViewer, nil, nil, 0, 0, false},
+	}
+	for _, tt := range tests {
+		provinceID, unitID, err := h.resolveUserScope(tt.role, tt.provinceID, tt.unitID)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if got := derefUint(provinceID); got != tt.wantProvince {
+			t.Errorf("%s: province = %d, want %d", tt.name, got, tt.wantProvince)
+		}
+		if got := derefUint(unitID); got != tt.wantUnit {
+			t.Errorf("%s: unit = %d, want %d", tt.name, got, tt.wantUnit)
+		}
+	}
+}
+
+func TestUpdateUserKeepsOwnAdminAccess(t *testing.T) {
+	h := newTestHandler(t)
+	admin := seedUser(t, h, models.User{Username: "admin", Role: models.RoleAdmin, Active: true}, "mật khẩu đúng")
+	other := seedUser(t, h, models.User{Username: "an", Role: models.RoleAdmin, Active: true}, "mật khẩu đúng")
+	r := testRouter(&admin)
+	r.PUT("/users/:id", h.UpdateUser)
+
+	inactive := false
+	tests := []struct {
+		name string
+		id   uint
+		body models.UserUpdateRequest
+		want int
+	}{
+		{"demote yourself", admin.ID, models.UserUpdateRequest{Role: models.RoleViewer}, http.StatusBadRequest},
+		{"disable yourself", admin.ID, models.UserUpdateRequest{Active: &inactive}, http.StatusBadRequest},
+		{"demote another admin", other.ID, models.UserUpdateRequest{Role: models.RoleProvinceOfficer, ProvinceID: uintPtr(1)}, http.StatusOK},
+		{"disable another admin", other.ID, models.UserUpdateRequest{Active: &inactive}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		if w := serveJSON(r, http.MethodPut, fmt.Sprintf("/users/%d", tt.id), tt.body); w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d: %s", tt.name, w.Code, tt.want, w.Body.String())
+		}
+	}
+
+	var updated models.User
+	h.db.First(&updated, other.ID)
+	if updated.Role != models.RoleProvinceOfficer || derefUint(updated.ProvinceID) != 1 || updated.Active {
+		t.Errorf("user an = %s in province %d, active %v; want a disabled province officer of 1", updated.Role, derefUint(updated.ProvinceID), updated.Active)
+	}
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"excel-processor/internal/models"
+	"excel-processor/internal/services"
+)
+
+// columnResultColumns are the exported columns of a single-column calculation
+var columnResultColumns = []string{"column", "operation", "result", "count", "total_rows", "start_row", "data_length", "filtered_rows"}
+
+// saveCalculation stores a calculation result with the request that produced it and
+// returns the ID of the stored calculation
+func (h *Handler) saveCalculation(c *gin.Context, kind string, file models.ExcelFile, sheetName string, req, result interface{}, rowCount int) (uint, error) {
+	request, err := json.Marshal(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode request: %w", err)
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode result: %w", err)
+	}
+
+	calculation := models.Calculation{
+		Kind:      kind,
+		FileID:    file.ID,
+		SheetName: sheetName,
+		Request:   request,
+		Result:    data,
+		RowCount:  rowCount,
+		CreatedBy: actorName(c),
+	}
+	if err := h.db.Create(&calculation).Error; err != nil {
+		return 0, fmt.Errorf("failed to save calculation: %w", err)
+	}
+	return calculation.ID, nil
+}
+
+// scopedCalculations returns a query over the calculations made on files the user may see
+func (h *Handler) scopedCalculations(c *gin.Context) *gorm.DB {
+	return h.db.Model(&models.Calculation{}).Where("file_id IN (?)", h.scopedFiles(c).Select("id"))
+}
+
+// findCalculation loads the calculation in the :id parameter, answering with an error if
+// it does not exist or the user may not see it
+func (h *Handler) findCalculation(c *gin.Context) (*models.Calculation, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid calculation ID"})
+		return nil, false
+	}
+
+	var calculation models.Calculation
+	if err := h.scopedCalculations(c).First(&calculation, uint(id)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Calculation not found"})
+		return nil, false
+	}
+	return &calculation, true
+}
+
+// rowCalculationResult decodes a stored row-wise calculation
+func rowCalculationResult(calculation *models.Calculation) (*models.RowCalculationResult, error) {
+	if calculation.Kind != models.CalculationRowWise {
+		return nil, fmt.Errorf("calculation %d is not a row-wise calculation", calculation.ID)
+	}
+	var result models.RowCalculationResult
+	if err := json.Unmarshal(calculation.Result, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode calculation %d: %w", calculation.ID, err)
+	}
+	result.CalculationID = calculation.ID
+	return &result, nil
+}
+
+// calculationTable lays out a stored calculation as the table used for file exports
+func calculationTable(calculation *models.Calculation) (services.ExportTable, error) {
+	if calculation.Kind == models.CalculationRowWise {
+		result, err := rowCalculationResult(calculation)
+		if err != nil {
+			return services.ExportTable{}, err
+		}
+		return services.RowCalculationTable(result), nil
+	}
+
+	var req models.CalculationRequest
+	var result models.CalculationResult
+	if err := json.Unmarshal(calculation.Request, &req); err != nil {
+		return services.ExportTable{}, fmt.Errorf("failed to decode calculation request: %w", err)
+	}
+	if err := json.Unmarshal(calculation.Result, &result); err != nil {
+		return services.ExportTable{}, fmt.Errorf("failed to decode calculation result: %w", err)
+	}
+
+	columns := columnResultColumns
+	if calculation.Kind == models.CalculationColumns {
+		columns = append([]string{req.MainColumn}, req.TargetColumns...)
+	}
+	return services.TableFromMaps(columns, result.Results), nil
+}
+
+// GetCalculations lists stored calculations, newest first, without their results
+func (h *Handler) GetCalculations(c *gin.Context) {
+	query := h.scopedCalculations(c).Omit("result").Order("id DESC")
+	for _, filter := range []string{"file_id", "kind", "created_by"} {
+		if value := c.Query(filter); value != "" {
+			query = query.Where(filter+" = ?", value)
+		}
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 || limit > 1000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 1000"})
+		return
+	}
+
+	var calculations []models.Calculation
+	if err := query.Limit(limit).Find(&calculations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load calculations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"calculations": calculations})
+}
+
+// GetCalculation returns a stored calculation with its request and result
+func (h *Handler) GetCalculation(c *gin.Context) {
+	calculation, ok := h.findCalculation(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"calculation": calculation})
+}
+
+// ExportCalculation exports a stored calculation result as a file (xlsx unless the
+// "format" query parameter asks for another format)
+func (h *Handler) ExportCalculation(c *gin.Context) {
+	calculation, ok := h.findCalculation(c)
+	if !ok {
+		return
+	}
+	auditFiles(c, calculation.FileID)
+
+	format := strings.ToLower(c.DefaultQuery("format", "xlsx"))
+	if !services.IsExportFormat(format) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported export format: " + format})
+		return
+	}
+
+	table, err := calculationTable(calculation)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Export failed: " + err.Error()})
+		return
+	}
+
+	baseName := fmt.Sprintf("calculation_%d", calculation.ID)
+	filePath, err := h.excel.WriteExportTable(table, format, baseName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Export failed: " + err.Error()})
+		return
+	}
+	h.sendFile(c, filePath, baseName, format)
+}
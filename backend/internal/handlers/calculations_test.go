@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"excel-processor/internal/models"
+)
+
+func TestStoredCalculations(t *testing.T) {
+	t.Chdir(t.TempDir())
+	h := newTestHandler(t)
+	unitFile := models.ExcelFile{ID: 1, FileName: "unit2.xlsx", FilePath: "uploads/1.xlsx", ProvinceID: uintPtr(1), UnitID: uintPtr(2)}
+	otherFile := models.ExcelFile{ID: 2, FileName: "unit6.xlsx", FilePath: "uploads/2.xlsx", ProvinceID: uintPtr(2), UnitID: uintPtr(6)}
+	seed(t, h, &unitFile, &otherFile)
+
+	officer := &models.User{Username: "an", Role: models.RoleUnitOfficer, ProvinceID: uintPtr(1), UnitID: uintPtr(2)}
+	result := models.RowCalculationResult{
+		SourceColumns: []string{"A", "B"},
+		TargetColumn:  "C",
+		Formula:       "A * B",
+		Results: []map[string]interface{}{
+			{"row_number": 2, "A": 3, "B": 1.5, "C": 4.5},
+			{"row_number": 3, "A": 2, "B": 1000, "C": 2000},
+		},
+	}
+	ownID, err := h.saveCalculation(contextAs(officer), models.CalculationRowWise, unitFile, "Sheet1", models.RowCalculationRequest{FileID: 1, Formula: "A * B"}, result, 2)
+	if err != nil {
+		t.Fatalf("saveCalculation returned %v", err)
+	}
+	otherID, err := h.saveCalculation(contextAs(&models.User{Username: "admin", Role: models.RoleAdmin}), models.CalculationRowWise, otherFile, "Sheet1", models.RowCalculationRequest{FileID: 2}, result, 2)
+	if err != nil {
+		t.Fatalf("saveCalculation returned %v", err)
+	}
+
+	r := testRouter(officer)
+	r.GET("/calculations", h.GetCalculations)
+	r.GET("/calculations/:id", h.GetCalculation)
+	r.GET("/calculations/:id/export", h.ExportCalculation)
+
+	// Listings hold the calculations on visible files only, without results
+	w := serveJSON(r, http.MethodGet, "/calculations", nil)
+	var list struct {
+		Calculations []models.Calculation `json:"calculations"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &list)
+	if len(list.Calculations) != 1 || list.Calculations[0].ID != ownID {
+		t.Fatalf("listing = %+v, want only calculation %d", list.Calculations, ownID)
+	}
+	if got := list.Calculations[0]; len(got.Result) != 0 || got.CreatedBy != "an" || got.RowCount != 2 {
+		t.Errorf("listed calculation has result %q, created by %q, %d rows", got.Result, got.CreatedBy, got.RowCount)
+	}
+
+	w = serveJSON(r, http.MethodGet, fmt.Sprintf("/calculations/%d", ownID), nil)
+	var stored struct {
+		Calculation models.Calculation `json:"calculation"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &stored)
+	var request models.RowCalculationRequest
+	json.Unmarshal(stored.Calculation.Request, &request)
+	if w.Code != http.StatusOK || request.Formula != "A * B" || !strings.Contains(string(stored.Calculation.Result), `"target_column":"C"`) {
+		t.Errorf("GetCalculation = %d with request %s and result %s", w.Code, stored.Calculation.Request, stored.Calculation.Result)
+	}
+
+	w = serveJSON(r, http.MethodGet, fmt.Sprintf("/calculations/%d/export?format=csv", ownID), nil)
+	want := "\xEF\xBB\xBFrow_number;A;B;C\r\n2;3;1,5;4,5\r\n3;2;1000;2000\r\n"
+	if w.Code != http.StatusOK || w.Body.String() != want {
+		t.Errorf("export = %d %q, want %q", w.Code, w.Body.String(), want)
+	}
+
+	for target, wantStatus := range map[string]int{
+		fmt.Sprintf("/calculations/%d", otherID):                 http.StatusNotFound,
+		fmt.Sprintf("/calculations/%d/export", otherID):          http.StatusNotFound,
+		fmt.Sprintf("/calculations/%d/export?format=doc", ownID): http.StatusBadRequest,
+		"/calculations/abc":     http.StatusBadRequest,
+		"/calculations?limit=0": http.StatusBadRequest,
+	} {
+		if w := serveJSON(r, http.MethodGet, target, nil); w.Code != wantStatus {
+			t.Errorf("GET %s: status = %d, want %d", target, w.Code, wantStatus)
+		}
+	}
+}
@@ -2,13 +2,16 @@ package handlers
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -22,13 +25,15 @@ type Handler struct {
 	db       *gorm.DB
 	excel    *services.ExcelService
 	province *services.ProvinceService
+	auth     *services.AuthService
 }
 
-func NewHandler(db *gorm.DB, excel *services.ExcelService, province *services.ProvinceService) *Handler {
+func NewHandler(db *gorm.DB, excel *services.ExcelService, province *services.ProvinceService, auth *services.AuthService) *Handler {
 	return &Handler{
 		db:       db,
 		excel:    excel,
 		province: province,
+		auth:     auth,
 	}
 }
 
@@ -42,12 +47,91 @@ func (h *Handler) UploadExcel(c *gin.Context) {
 	defer file.Close()
 
 	// Validate file extension
-	ext := filepath.Ext(header.Filename)
-	if ext != ".xlsx" && ext != ".xls" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Only .xlsx and .xls files are allowed"})
+	ext := strings.ToLower(filepath.Ext(header.Filename))
+	if ext != ".xlsx" && ext != ".xls" && ext != ".ods" && ext != ".csv" && ext != ".tsv" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only .xlsx, .xls, .ods, .csv and .tsv files are allowed"})
 		return
 	}
 
+	// Optional province/unit the file belongs to
+	provinceID, err := formUint(c, "province_id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid province ID"})
+		return
+	}
+	unitID, err := formUint(c, "unit_id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid unit ID"})
+		return
+	}
+
+	// Officers' files always belong to their own unit or province
+	user := currentUser(c)
+	if user.Role != models.RoleAdmin && unitID == nil {
+		unitID = user.UnitID
+		if provinceID == nil {
+			provinceID = user.ProvinceID
+		}
+	}
+
+	if unitID != nil {
+		unit := h.province.GetUnitByID(*unitID)
+		if unit == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unit not found"})
+			return
+		}
+		if provinceID != nil && *provinceID != unit.ProvinceID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unit does not belong to the given province"})
+			return
+		}
+		if !canAccessUnit(user, unit) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You cannot upload files for this unit"})
+			return
+		}
+		provinceID = &unit.ProvinceID
+	}
+	if provinceID != nil && !canAccessProvince(user, *provinceID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You cannot upload files for this province"})
+		return
+	}
+
+	// Optional reporting period (and template) the file is submitted for
+	periodID, err := formUint(c, "period_id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid period ID"})
+		return
+	}
+	if periodID != nil {
+		var period models.ReportingPeriod
+		if err := h.db.First(&period, *periodID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Period not found"})
+			return
+		}
+	}
+	templateID, err := formUint(c, "template_id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template ID"})
+		return
+	}
+	if templateID != nil {
+		var template models.ExcelFile
+		if err := h.scopedFiles(c).First(&template, *templateID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Template not found"})
+			return
+		}
+	}
+	if unitID != nil && periodID != nil {
+		existing, err := h.findSubmission(h.db, *unitID, *periodID, templateID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check submission"})
+			return
+		}
+		if existing != nil && !services.CanTransition(existing.Status, models.SubmissionSubmitted) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Submission is already approved"})
+			return
+		}
+	}
+
 	// Create uploads directory if not exists
 	uploadsDir := "uploads"
 	if err := os.MkdirAll(uploadsDir, 0755); err != nil {
@@ -71,24 +155,55 @@ func (h *Handler) UploadExcel(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
 		return
 	}
+	dst.Close()
+
+	// Convert legacy .xls files and reject unreadable files before registering them
+	storedPath, err := h.excel.PrepareUpload(filePath)
+	if err != nil {
+		os.Remove(filePath)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot read uploaded file: " + err.Error()})
+		return
+	}
+	// A converted upload is stored as .xlsx, so its size differs from the upload's
+	fileSize := header.Size
+	if info, err := os.Stat(storedPath); err == nil {
+		fileSize = info.Size()
+	}
 
 	// Save to database
 	excelFile := models.ExcelFile{
-		FileName: header.Filename,
-		FilePath: filePath,
-		FileSize: header.Size,
+		FileName:   header.Filename,
+		FilePath:   storedPath,
+		FileSize:   fileSize,
+		ProvinceID: provinceID,
+		UnitID:     unitID,
+		PeriodID:   periodID,
+		UploadedBy: actorName(c),
 	}
 
 	if err := h.db.Create(&excelFile).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file info"})
 		return
 	}
+	auditFiles(c, excelFile.ID)
 
-	c.JSON(http.StatusOK, gin.H{
+	response := gin.H{
 		"message": "File uploaded successfully",
 		"file_id": excelFile.ID,
 		"filename": excelFile.FileName,
-	})
+	}
+
+	// A unit's upload for a period counts as its submission
+	if unitID != nil && periodID != nil {
+		submission, err := h.saveSubmission(h.db, h.province.GetUnitByID(*unitID), *periodID, templateID, &excelFile.ID, "", actorName(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save submission"})
+			return
+		}
+		response["submission_id"] = submission.ID
+	}
+
+	c.JSON(http.StatusOK, response)
 }
 
 // UploadTemplate handles template file upload
@@ -101,9 +216,9 @@ func (h *Handler) UploadTemplate(c *gin.Context) {
 	defer file.Close()
 
 	// Validate file extension
-	ext := filepath.Ext(header.Filename)
-	if ext != ".xlsx" && ext != ".xls" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Only .xlsx and .xls files are allowed"})
+	ext := strings.ToLower(filepath.Ext(header.Filename))
+	if ext != ".xlsx" && ext != ".xls" && ext != ".ods" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only .xlsx, .xls and .ods files are allowed"})
 		return
 	}
 
@@ -130,18 +245,34 @@ func (h *Handler) UploadTemplate(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save template file"})
 		return
 	}
+	dst.Close()
+
+	// Convert legacy .xls files and reject unreadable template files before registering them
+	storedPath, err := h.excel.PrepareUpload(filePath)
+	if err != nil {
+		os.Remove(filePath)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot read uploaded file: " + err.Error()})
+		return
+	}
+	// A converted upload is stored as .xlsx, so its size differs from the upload's
+	fileSize := header.Size
+	if info, err := os.Stat(storedPath); err == nil {
+		fileSize = info.Size()
+	}
 
 	// Save to database with template flag (we can add a template field later)
 	templateFile := models.ExcelFile{
-		FileName: header.Filename,
-		FilePath: filePath,
-		FileSize: header.Size,
+		FileName:   header.Filename,
+		FilePath:   storedPath,
+		FileSize:   fileSize,
+		UploadedBy: actorName(c),
 	}
 
 	if err := h.db.Create(&templateFile).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save template info"})
 		return
 	}
+	auditFiles(c, templateFile.ID)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Template uploaded successfully",
@@ -150,6 +281,25 @@ func (h *Handler) UploadTemplate(c *gin.Context) {
 	})
 }
 
+// GetFiles lists the uploaded files the user may see, newest first, optionally filtered by
+// province, unit and period
+func (h *Handler) GetFiles(c *gin.Context) {
+	query := h.scopedFiles(c).Order("created_at DESC")
+	for _, filter := range []string{"province_id", "unit_id", "period_id"} {
+		if value := c.Query(filter); value != "" {
+			query = query.Where(filter+" = ?", value)
+		}
+	}
+
+	var files []models.ExcelFile
+	if err := query.Find(&files).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load files"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"files": files})
+}
+
 // GetSheets returns all sheets in an Excel file
 func (h *Handler) GetSheets(c *gin.Context) {
 	fileIDStr := c.Param("fileId")
@@ -160,7 +310,7 @@ func (h *Handler) GetSheets(c *gin.Context) {
 	}
 
 	var excelFile models.ExcelFile
-	if err := h.db.First(&excelFile, uint(fileID)).Error; err != nil {
+	if err := h.scopedFiles(c).First(&excelFile, uint(fileID)).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
 		return
 	}
@@ -186,7 +336,7 @@ func (h *Handler) GetSheetData(c *gin.Context) {
 	}
 
 	var excelFile models.ExcelFile
-	if err := h.db.First(&excelFile, uint(fileID)).Error; err != nil {
+	if err := h.scopedFiles(c).First(&excelFile, uint(fileID)).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
 		return
 	}
@@ -200,9 +350,45 @@ func (h *Handler) GetSheetData(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"data": data})
 }
 
-// GetProvinces returns all provinces
+// RenderSheetPDF renders a sheet of an uploaded file to PDF for printing
+func (h *Handler) RenderSheetPDF(c *gin.Context) {
+	fileID, err := strconv.ParseUint(c.Param("fileId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file ID"})
+		return
+	}
+
+	var opts models.PDFOptions
+	if err := c.ShouldBindQuery(&opts); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var excelFile models.ExcelFile
+	if err := h.scopedFiles(c).First(&excelFile, uint(fileID)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+
+	pdfPath, err := h.excel.RenderPDF(excelFile.FilePath, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "PDF rendering failed: " + err.Error()})
+		return
+	}
+
+	baseName := strings.TrimSuffix(excelFile.FileName, filepath.Ext(excelFile.FileName))
+	h.sendFile(c, pdfPath, baseName, "pdf")
+}
+
+// GetProvinces returns the provinces the user may see
 func (h *Handler) GetProvinces(c *gin.Context) {
-	provinces := h.province.GetAllProvinces()
+	user := currentUser(c)
+	provinces := make([]models.Province, 0)
+	for _, province := range h.province.GetAllProvinces() {
+		if canAccessProvince(user, province.ID) {
+			provinces = append(provinces, province)
+		}
+	}
 	c.JSON(http.StatusOK, gin.H{"provinces": provinces})
 }
 
@@ -215,10 +401,22 @@ func (h *Handler) GetUnits(c *gin.Context) {
 		return
 	}
 
-	units := h.province.GetUnitsByProvince(uint(provinceID))
+	units := h.visibleUnits(c, uint(provinceID))
 	c.JSON(http.StatusOK, gin.H{"units": units})
 }
 
+// visibleUnits returns the units of a province that the user may see
+func (h *Handler) visibleUnits(c *gin.Context, provinceID uint) []models.Unit {
+	user := currentUser(c)
+	units := make([]models.Unit, 0)
+	for _, unit := range h.province.GetUnitsByProvince(provinceID) {
+		if canAccessUnit(user, &unit) {
+			units = append(units, unit)
+		}
+	}
+	return units
+}
+
 // CalculateColumns performs calculations on selected columns
 func (h *Handler) CalculateColumns(c *gin.Context) {
 	var req models.CalculationRequest
@@ -226,20 +424,36 @@ func (h *Handler) CalculateColumns(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := services.ValidateRowFilter(req.Filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := services.ValidateRounding(req.Rounding); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	var excelFile models.ExcelFile
-	if err := h.db.First(&excelFile, req.FileID).Error; err != nil {
+	if err := h.scopedFiles(c).First(&excelFile, req.FileID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
 		return
 	}
+	auditFiles(c, excelFile.ID)
 
 	result, err := h.excel.CalculateColumns(excelFile.FilePath, req)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Calculation failed"})
 		return
 	}
+	id, err := h.saveCalculation(c, models.CalculationColumns, excelFile, req.SheetName, req, result, len(result.Results))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save calculation"})
+		return
+	}
+	result.CalculationID = id
 
-	c.JSON(http.StatusOK, result)
+	columns := append([]string{req.MainColumn}, req.TargetColumns...)
+	h.respondCalculation(c, result, services.TableFromMaps(columns, result.Results), "calculation")
 }
 
 // CalculateColumn performs calculation on a specific column
@@ -249,21 +463,36 @@ func (h *Handler) CalculateColumn(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := services.ValidateRowFilter(req.Filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := services.ValidateRounding(req.Rounding); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	// Get file from database
 	var excelFile models.ExcelFile
-	if err := h.db.First(&excelFile, req.FileID).Error; err != nil {
+	if err := h.scopedFiles(c).First(&excelFile, req.FileID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
 		return
 	}
+	auditFiles(c, excelFile.ID)
 
 	result, err := h.excel.CalculateColumnWithFile(excelFile, req)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Calculation failed: " + err.Error()})
 		return
 	}
+	id, err := h.saveCalculation(c, models.CalculationColumn, excelFile, req.SheetName, req, result, len(result.Results))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save calculation"})
+		return
+	}
+	result.CalculationID = id
 
-	c.JSON(http.StatusOK, result)
+	h.respondCalculation(c, result, services.TableFromMaps(columnResultColumns, result.Results), "calculation")
 }
 
 // CalculateRowWise performs row-wise calculations (e.g., I11 + K11 = L11)
@@ -273,21 +502,63 @@ func (h *Handler) CalculateRowWise(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := services.ValidateRowFilter(req.Filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := services.ValidateRounding(req.Rounding); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Formulas calling PIT functions use a tax table; the stored request records its version
+	var taxTable *models.TaxTable
+	if req.Operation == "formula" {
+		formula, err := services.ParseFormula(req.Formula)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if formula.UsesTaxTable() {
+			var ok bool
+			if taxTable, ok = h.taxTableForCalculation(c, req.TaxTable); !ok {
+				return
+			}
+			req.TaxTable = taxTable.Version
+		}
+	}
 
 	// Get file from database
 	var excelFile models.ExcelFile
-	if err := h.db.First(&excelFile, req.FileID).Error; err != nil {
+	if err := h.scopedFiles(c).First(&excelFile, req.FileID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
 		return
 	}
+	auditFiles(c, excelFile.ID)
 
-	result, err := h.excel.CalculateRowWise(excelFile.FilePath, req)
+	result, err := h.excel.CalculateRowWise(excelFile.FilePath, req, taxTable)
+	var valueErr *services.RowValueError
+	if errors.As(err, &valueErr) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Row-wise calculation failed: " + err.Error(), "warning": valueErr.Warning})
+		return
+	}
+	var formulaErr *services.FormulaError
+	if errors.As(err, &formulaErr) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Row-wise calculation failed: " + err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Row-wise calculation failed: " + err.Error()})
 		return
 	}
+	id, err := h.saveCalculation(c, models.CalculationRowWise, excelFile, req.SheetName, req, result, result.TotalRows)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save calculation"})
+		return
+	}
+	result.CalculationID = id
 
-	c.JSON(http.StatusOK, result)
+	h.respondCalculation(c, result, services.RowCalculationTable(result), "rowwise_calculation")
 }
 
 // ExportToTemplate exports row calculation results to template
@@ -298,21 +569,118 @@ func (h *Handler) ExportToTemplate(c *gin.Context) {
 		return
 	}
 
-	filePath, err := h.excel.ExportRowCalculationToTemplate(&req.CalculationResult, req.TemplatePath)
+	// A stored calculation takes the place of a result posted by the client
+	if req.CalculationID != 0 {
+		var calculation models.Calculation
+		if err := h.scopedCalculations(c).First(&calculation, req.CalculationID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Calculation not found"})
+			return
+		}
+		result, err := rowCalculationResult(&calculation)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		req.CalculationResult = *result
+		req.FileID = calculation.FileID
+	} else if req.CalculationResult.TargetColumn == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "calculation_id or calculation_result is required"})
+		return
+	} else if req.FileID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file_id is required with calculation_result"})
+		return
+	}
+
+	// Data taken from a submitted file may only be exported once the submission is approved
+	if _, ok := h.approvedSource(c, req.FileID); !ok {
+		return
+	}
+	auditFiles(c, req.FileID)
+
+	// Resolve template: uploaded template ID takes precedence over a server path
+	templatePath := req.TemplatePath
+	if req.TemplateID != 0 {
+		var templateFile models.ExcelFile
+		if err := h.scopedFiles(c).First(&templateFile, req.TemplateID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Template file not found"})
+			return
+		}
+		auditFiles(c, templateFile.ID)
+		templatePath = templateFile.FilePath
+	}
+	if templatePath == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "template_id or template_path is required"})
+		return
+	}
+	// A server path bypasses file scoping, so only admins may use one
+	if req.TemplateID == 0 && currentUser(c).Role != models.RoleAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Use an uploaded template (template_id)"})
+		return
+	}
+
+	filePath, err := h.excel.ExportRowCalculationToTemplate(templatePath, req)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Template export failed: " + err.Error()})
 		return
 	}
 
-	c.Header("Content-Disposition", "attachment; filename=calculated_template.xlsx")
-	c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
-	c.File(filePath)
+	h.sendExport(c, filePath, "calculated_template")
+}
 
-	// Clean up temporary file
-	go func() {
-		time.Sleep(time.Minute)
-		os.Remove(filePath)
-	}()
+// FillReportTemplate fills a placeholder-based report template from a source sheet
+func (h *Handler) FillReportTemplate(c *gin.Context) {
+	var req models.PlaceholderReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sourceFile, ok := h.approvedSource(c, req.SourceFileID)
+	if !ok {
+		return
+	}
+
+	var templateFile models.ExcelFile
+	if err := h.scopedFiles(c).First(&templateFile, req.TemplateID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template file not found"})
+		return
+	}
+	auditFiles(c, sourceFile.ID, templateFile.ID)
+
+	// Province and unit come from the upload unless overridden by the request
+	provinceID, unitID := sourceFile.ProvinceID, sourceFile.UnitID
+	if req.ProvinceID != nil {
+		provinceID = req.ProvinceID
+	}
+	if req.UnitID != nil {
+		unitID = req.UnitID
+	}
+
+	var unit *models.Unit
+	if unitID != nil {
+		if unit = h.province.GetUnitByID(*unitID); unit == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unit not found"})
+			return
+		}
+		if provinceID == nil {
+			provinceID = &unit.ProvinceID
+		}
+	}
+	var province *models.Province
+	if provinceID != nil {
+		if province = h.province.GetProvinceByID(*provinceID); province == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Province not found"})
+			return
+		}
+	}
+
+	filePath, err := h.excel.FillPlaceholderTemplate(sourceFile.FilePath, templateFile.FilePath, req, province, unit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Report generation failed: " + err.Error()})
+		return
+	}
+
+	h.sendExport(c, filePath, fmt.Sprintf("report_%d", time.Now().Unix()))
 }
 
 // ExportExcel exports data to Excel format
@@ -323,21 +691,179 @@ func (h *Handler) ExportExcel(c *gin.Context) {
 		return
 	}
 
+	// Tabular formats are written straight from the data, workbooks via ExportToExcel
+	format := strings.ToLower(c.DefaultQuery("format", "xlsx"))
+	if format == "csv" || format == "json" || format == "ndjson" {
+		filePath, err := h.excel.WriteExportTable(h.excel.ExportTableFromRequest(req), format, "export")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Export failed"})
+			return
+		}
+		h.sendFile(c, filePath, "export", format)
+		return
+	}
+
 	filePath, err := h.excel.ExportToExcel(req)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Export failed"})
 		return
 	}
 
-	c.Header("Content-Disposition", "attachment; filename=export.xlsx")
-	c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
-	c.File(filePath)
+	h.sendExport(c, filePath, "export")
+}
 
-	// Clean up temporary file
-	go func() {
-		time.Sleep(time.Minute)
-		os.Remove(filePath)
-	}()
+// ExportWorkbook exports several sheets into a single workbook download
+func (h *Handler) ExportWorkbook(c *gin.Context) {
+	var req models.WorkbookExportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The other formats hold a single sheet and would silently drop the rest
+	format := strings.ToLower(c.DefaultQuery("format", "xlsx"))
+	if format != "xlsx" && format != "ods" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Multi-sheet export supports xlsx and ods only"})
+		return
+	}
+
+	filePath, err := h.excel.ExportWorkbook(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Export failed: " + err.Error()})
+		return
+	}
+
+	h.sendExport(c, filePath, "workbook")
+}
+
+// ConsolidateFiles consolidates the same sheet from many unit submissions into one workbook
+func (h *Handler) ConsolidateFiles(c *gin.Context) {
+	var req models.ConsolidationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	format := strings.ToLower(c.DefaultQuery("format", "xlsx"))
+	if format != "xlsx" && format != "ods" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Consolidation supports xlsx and ods only"})
+		return
+	}
+
+	var files []models.ExcelFile
+	switch {
+	case len(req.FileIDs) > 0:
+		if err := h.scopedFiles(c).Find(&files, req.FileIDs).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load files"})
+			return
+		}
+		found := make(map[uint]bool)
+		for _, file := range files {
+			found[file.ID] = true
+		}
+		for _, id := range req.FileIDs {
+			if !found[id] {
+				c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("File %d not found", id)})
+				return
+			}
+		}
+		for _, file := range files {
+			if err := h.ensureApproved(file); err != nil {
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+				return
+			}
+		}
+	case req.ProvinceID != nil || req.PeriodID != nil:
+		// Province-level consolidation only takes approved submissions
+		query := h.scopedFiles(c).Where("id IN (?)", h.approvedFileIDs())
+		if req.ProvinceID != nil {
+			query = query.Where("province_id = ?", *req.ProvinceID)
+		}
+		if req.PeriodID != nil {
+			query = query.Where("period_id = ?", *req.PeriodID)
+		}
+		if err := query.Find(&files).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load files"})
+			return
+		}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Either file_ids, province_id or period_id is required"})
+		return
+	}
+	if len(files) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No files to consolidate"})
+		return
+	}
+
+	sources := make([]services.ConsolidationSource, 0, len(files))
+	for _, file := range files {
+		auditFiles(c, file.ID)
+		source := services.ConsolidationSource{File: file}
+		if file.UnitID != nil {
+			if unit := h.province.GetUnitByID(*file.UnitID); unit != nil {
+				source.UnitCode, source.UnitName = unit.Code, unit.Name
+			}
+		}
+		sources = append(sources, source)
+	}
+	// Group the output by unit, oldest upload first within a unit
+	sort.SliceStable(sources, func(i, j int) bool {
+		if sources[i].UnitCode != sources[j].UnitCode {
+			return sources[i].UnitCode < sources[j].UnitCode
+		}
+		return sources[i].File.ID < sources[j].File.ID
+	})
+
+	filePath, err := h.excel.ConsolidateFiles(sources, req)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Consolidation failed: " + err.Error()})
+		return
+	}
+
+	h.sendExport(c, filePath, "consolidated")
+}
+
+// mergeSourceFileIDs returns the data files a merge request takes its values from: the files
+// of the saved calculations named by calculationId, at the top level or on each mergeData
+// entry, and sourceFileId for data that was not saved as a calculation
+func (h *Handler) mergeSourceFileIDs(c *gin.Context, mergeRequest map[string]interface{}) ([]uint, bool) {
+	calculationIDs := make(map[uint]bool)
+	if id, ok := mergeRequest["calculationId"].(float64); ok {
+		calculationIDs[uint(id)] = true
+	}
+	if entries, ok := mergeRequest["mergeData"].([]interface{}); ok {
+		for _, entry := range entries {
+			if data, ok := entry.(map[string]interface{}); ok {
+				if id, ok := data["calculationId"].(float64); ok {
+					calculationIDs[uint(id)] = true
+				}
+			}
+		}
+	}
+
+	fileIDs := make(map[uint]bool)
+	if id, ok := mergeRequest["sourceFileId"].(float64); ok {
+		fileIDs[uint(id)] = true
+	}
+	for id := range calculationIDs {
+		var calculation models.Calculation
+		if err := h.scopedCalculations(c).Select("id", "file_id").First(&calculation, id).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Calculation %d not found", id)})
+			return nil, false
+		}
+		fileIDs[calculation.FileID] = true
+	}
+	if len(fileIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "calculationId or sourceFileId is required to check that the merged data is approved"})
+		return nil, false
+	}
+
+	ids := make([]uint, 0, len(fileIDs))
+	for id := range fileIDs {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, true
 }
 
 // MergeAndDownload merges calculated data into template and provides download
@@ -371,13 +897,26 @@ func (h *Handler) MergeAndDownload(c *gin.Context) {
 	log.Printf("🎯 Template ID: %v", templateID)
 
 	var templateFile models.ExcelFile
-	if err := h.db.First(&templateFile, uint(templateID)).Error; err != nil {
+	if err := h.scopedFiles(c).First(&templateFile, uint(templateID)).Error; err != nil {
 		log.Printf("❌ Template file not found for ID %v: %v", templateID, err)
 		c.JSON(http.StatusNotFound, gin.H{"error": "Template file not found"})
 		return
 	}
 
 	log.Printf("✅ Found template file: %s", templateFile.FilePath)
+	auditFiles(c, templateFile.ID)
+
+	// Data taken from a submitted file may only be merged once the submission is approved
+	sourceIDs, ok := h.mergeSourceFileIDs(c, mergeRequest)
+	if !ok {
+		return
+	}
+	for _, id := range sourceIDs {
+		if _, ok := h.approvedSource(c, id); !ok {
+			return
+		}
+	}
+	auditFiles(c, sourceIDs...)
 
 	// Check if this is multi-column merge or single column
 	_, hasMultipleColumns := mergeRequest["mergeData"]
@@ -401,15 +940,89 @@ func (h *Handler) MergeAndDownload(c *gin.Context) {
 
 	log.Printf("✅ Merge completed, output file: %s", outputPath)
 
-	// Set headers for file download
-	filename := fmt.Sprintf("merged_result_%d.xlsx", time.Now().Unix())
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
-	c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
-	c.File(outputPath)
+	h.sendExport(c, outputPath, fmt.Sprintf("merged_result_%d", time.Now().Unix()))
+}
+
+// formUint parses an optional unsigned integer form field, returning nil if it is empty
+func formUint(c *gin.Context, key string) (*uint, error) {
+	value := c.PostForm(key)
+	if value == "" {
+		return nil, nil
+	}
+	n, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return nil, err
+	}
+	id := uint(n)
+	return &id, nil
+}
+
+// sendExport converts an exported workbook to the format requested by the "format" query
+// parameter (xlsx by default) and sends it as a download
+func (h *Handler) sendExport(c *gin.Context, filePath, baseName string) {
+	format := strings.ToLower(c.DefaultQuery("format", "xlsx"))
+	if !services.IsExportFormat(format) {
+		os.Remove(filePath)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported export format: " + format})
+		return
+	}
+
+	var (
+		outputPath string
+		err        error
+	)
+	if format == "pdf" {
+		// Page setup comes from the query, e.g. ?format=pdf&orientation=landscape&header_rows=3
+		var opts models.PDFOptions
+		if err := c.ShouldBindQuery(&opts); err != nil {
+			os.Remove(filePath)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		outputPath, err = h.excel.RenderPDF(filePath, opts)
+		os.Remove(filePath)
+	} else {
+		outputPath, err = h.excel.ConvertExport(filePath, format)
+	}
+	if err != nil {
+		os.Remove(filePath)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Export failed: " + err.Error()})
+		return
+	}
+
+	h.sendFile(c, outputPath, baseName, format)
+}
+
+// sendFile sends an export file as a download and removes it afterwards
+func (h *Handler) sendFile(c *gin.Context, filePath, baseName, format string) {
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.%s", baseName, format))
+	c.Header("Content-Type", services.ExportContentType(format))
+	c.File(filePath)
 
-	// Clean up temporary file after download
+	// Clean up temporary file
 	go func() {
 		time.Sleep(time.Minute)
-		os.Remove(outputPath)
+		os.Remove(filePath)
 	}()
 }
+
+// respondCalculation answers with the JSON result, or with the result table as a file
+// when the "format" query parameter asks for one (csv, json, ndjson, xlsx, ods, pdf)
+func (h *Handler) respondCalculation(c *gin.Context, result interface{}, table services.ExportTable, baseName string) {
+	format := strings.ToLower(c.Query("format"))
+	if format == "" {
+		c.JSON(http.StatusOK, result)
+		return
+	}
+	if !services.IsExportFormat(format) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported export format: " + format})
+		return
+	}
+
+	filePath, err := h.excel.WriteExportTable(table, format, baseName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Export failed: " + err.Error()})
+		return
+	}
+	h.sendFile(c, filePath, baseName, format)
+}
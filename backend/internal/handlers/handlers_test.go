@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"excel-processor/internal/models"
+	"excel-processor/internal/services"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
+
+// newTestHandler returns a handler backed by a fresh, migrated database
+func newTestHandler(t *testing.T) *Handler {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	if err := db.AutoMigrate(&models.Province{}, &models.Unit{}, &models.ExcelFile{}, &models.ReportingPeriod{}, &models.Submission{}, &models.SubmissionEvent{}, &models.User{}, &models.APIKey{}, &models.AuditLog{}, &models.Calculation{}, &models.Recipe{}, &models.TaxTable{}); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	return NewHandler(db, services.NewExcelService(), services.NewProvinceService(), services.NewAuthService([]byte("test-secret"), time.Hour))
+}
+
+// testRouter returns a router whose requests are made as user, or anonymously if user is nil
+func testRouter(user *models.User) *gin.Engine {
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		if user != nil {
+			c.Set(currentUserKey, user)
+		}
+	})
+	return r
+}
+
+// serveJSON sends a request with body encoded as JSON (none if body is nil) and returns the response
+func serveJSON(r http.Handler, method, target string, body interface{}) *httptest.ResponseRecorder {
+	var payload []byte
+	if body != nil {
+		payload, _ = json.Marshal(body)
+	}
+	req := httptest.NewRequest(method, target, bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestExportExcelFormats(t *testing.T) {
+	t.Chdir(t.TempDir())
+	h := newTestHandler(t)
+	r := testRouter(&models.User{Username: "admin", Role: models.RoleAdmin})
+	r.POST("/export", h.ExportExcel)
+
+	body := models.ExportRequest{
+		FileID:    1,
+		SheetName: "Sheet1",
+		Data: []map[string]interface{}{
+			{"name": "Nguyễn Văn An", "amount": 1500.5, "paid_on": "2024-07-15"},
+			{"name": "Trần Thị \"Bình\"; Huế", "amount": 2000, "paid_on": nil},
+		},
+		Columns: []models.ExportColumn{
+			{Key: "name", Header: "Họ tên"},
+			{Key: "amount", Header: "Số tiền", Type: "number"},
+			{Key: "paid_on", Header: "Ngày", Type: "date"},
+		},
+	}
+
+	tests := []struct {
+		format      string
+		contentType string
+		want        string
+	}{
+		{"csv", "text/csv; charset=utf-8", "\xEF\xBB\xBFHọ tên;Số tiền;Ngày\r\n" +
+			"Nguyễn Văn An;1500,5;15/07/2024\r\n" +
+			"\"Trần Thị \"\"Bình\"\"; Huế\";2000;\r\n"},
+		{"ndjson", "application/x-ndjson", `{"Họ tên":"Nguyễn Văn An","Số tiền":1500.5,"Ngày":"2024-07-15T00:00:00Z"}` + "\n" +
+			`{"Họ tên":"Trần Thị \"Bình\"; Huế","Số tiền":2000,"Ngày":null}` + "\n"},
+		{"json", "application/json", "[\n  " + `{"Họ tên":"Nguyễn Văn An","Số tiền":1500.5,"Ngày":"2024-07-15T00:00:00Z"}` + ",\n  " +
+			`{"Họ tên":"Trần Thị \"Bình\"; Huế","Số tiền":2000,"Ngày":null}` + "\n]\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			w := serveJSON(r, http.MethodPost, "/export?format="+tt.format, body)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200: %s", w.Code, w.Body.String())
+			}
+			if got := w.Header().Get("Content-Type"); got != tt.contentType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.contentType)
+			}
+			if got := w.Header().Get("Content-Disposition"); !strings.HasSuffix(got, "export."+tt.format) {
+				t.Errorf("Content-Disposition = %q, want an export.%s attachment", got, tt.format)
+			}
+			if got := w.Body.String(); got != tt.want {
+				t.Errorf("body = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if w := serveJSON(r, http.MethodPost, "/export?format=docx", body); w.Code != http.StatusBadRequest {
+		t.Errorf("format=docx: status = %d, want 400", w.Code)
+	}
+}
+
+// seed inserts records into the test database
+func seed(t *testing.T, h *Handler, records ...interface{}) {
+	t.Helper()
+	for _, record := range records {
+		if err := h.db.Create(record).Error; err != nil {
+			t.Fatalf("failed to insert %T: %v", record, err)
+		}
+	}
+}
+
+func uintPtr(v uint) *uint {
+	return &v
+}
+
+// derefUint returns the value of an optional ID, 0 if it is not set
+func derefUint(v *uint) uint {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
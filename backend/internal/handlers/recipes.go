@@ -0,0 +1,303 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+
+	"excel-processor/internal/models"
+	"excel-processor/internal/services"
+)
+
+const (
+	// rowWarningsHeader carries how many rows of a recipe run were settled by a value policy
+	rowWarningsHeader = "X-Row-Warnings"
+	// rowWarningsDetailHeader carries the first of them as a JSON array, at most
+	// maxWarningsHeaderBytes long so proxies with an 8 KB header limit pass the response
+	rowWarningsDetailHeader = "X-Row-Warnings-Detail"
+	maxWarningsHeaderBytes  = 4096
+	// rowWarningsTruncatedHeader is "true" when the detail leaves warnings out
+	rowWarningsTruncatedHeader = "X-Row-Warnings-Truncated"
+)
+
+// asciiJSON escapes the non-ASCII characters of encoded JSON, e.g. Vietnamese cell text, so it
+// can be sent in a header
+func asciiJSON(data []byte) string {
+	var b strings.Builder
+	for _, r := range string(data) {
+		if r < utf8.RuneSelf {
+			b.WriteRune(r)
+			continue
+		}
+		for _, unit := range utf16.Encode([]rune{r}) {
+			fmt.Fprintf(&b, "\\u%04x", unit)
+		}
+	}
+	return b.String()
+}
+
+// warningsDetail encodes as many warnings as fit in maxWarningsHeaderBytes as a JSON array
+// for a header, and reports whether any were left out
+func warningsDetail(warnings []models.RowWarning) (string, bool) {
+	var b strings.Builder
+	b.WriteByte('[')
+	for i, warning := range warnings {
+		data, err := json.Marshal(warning)
+		if err != nil {
+			return "", false
+		}
+		item := asciiJSON(data)
+		if b.Len()+len(item)+2 > maxWarningsHeaderBytes {
+			b.WriteByte(']')
+			return b.String(), true
+		}
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(item)
+	}
+	b.WriteByte(']')
+	return b.String(), false
+}
+
+// findRecipe loads the recipe in the :id parameter, answering with an error if it does not exist
+func (h *Handler) findRecipe(c *gin.Context) (*models.Recipe, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid recipe ID"})
+		return nil, false
+	}
+
+	var recipe models.Recipe
+	if err := h.db.First(&recipe, uint(id)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recipe not found"})
+		return nil, false
+	}
+	return &recipe, true
+}
+
+// canEditRecipe reports whether the user may change or delete a recipe: admins and whoever
+// created it
+func canEditRecipe(c *gin.Context, recipe *models.Recipe) bool {
+	user := currentUser(c)
+	return user != nil && (user.Role == models.RoleAdmin || user.Username == recipe.CreatedBy)
+}
+
+// bindRecipe reads and checks a recipe request, including that its template can be used
+func (h *Handler) bindRecipe(c *gin.Context) (*models.RecipeRequest, bool) {
+	var req models.RecipeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if err := services.ValidateRecipe(req.Steps); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	if step := services.RecipeTemplateStep(models.Recipe{Steps: req.Steps}); step != nil {
+		var templateFile models.ExcelFile
+		if err := h.scopedFiles(c).First(&templateFile, step.TemplateID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Template file not found"})
+			return nil, false
+		}
+	}
+	return &req, true
+}
+
+// GetRecipes lists the saved recipes
+func (h *Handler) GetRecipes(c *gin.Context) {
+	var recipes []models.Recipe
+	if err := h.db.Order("name").Find(&recipes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load recipes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recipes": recipes})
+}
+
+// GetRecipe returns a saved recipe with its steps
+func (h *Handler) GetRecipe(c *gin.Context) {
+	recipe, ok := h.findRecipe(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recipe": recipe})
+}
+
+// CreateRecipe saves a named recipe
+func (h *Handler) CreateRecipe(c *gin.Context) {
+	req, ok := h.bindRecipe(c)
+	if !ok {
+		return
+	}
+
+	var count int64
+	h.db.Model(&models.Recipe{}).Where("name = ?", req.Name).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "A recipe with this name already exists"})
+		return
+	}
+
+	recipe := models.Recipe{
+		Name:        req.Name,
+		Description: req.Description,
+		SheetName:   req.SheetName,
+		StartRow:    req.StartRow,
+		EndRow:      req.EndRow,
+		Steps:       req.Steps,
+		CreatedBy:   actorName(c),
+	}
+	if err := h.db.Create(&recipe).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save recipe"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Recipe created successfully", "recipe": recipe})
+}
+
+// UpdateRecipe replaces the name, range and steps of a recipe
+func (h *Handler) UpdateRecipe(c *gin.Context) {
+	recipe, ok := h.findRecipe(c)
+	if !ok {
+		return
+	}
+	if !canEditRecipe(c, recipe) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the creator or an admin can change this recipe"})
+		return
+	}
+
+	req, ok := h.bindRecipe(c)
+	if !ok {
+		return
+	}
+
+	var count int64
+	h.db.Model(&models.Recipe{}).Where("name = ? AND id <> ?", req.Name, recipe.ID).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "A recipe with this name already exists"})
+		return
+	}
+
+	recipe.Name = req.Name
+	recipe.Description = req.Description
+	recipe.SheetName = req.SheetName
+	recipe.StartRow = req.StartRow
+	recipe.EndRow = req.EndRow
+	recipe.Steps = req.Steps
+	if err := h.db.Save(recipe).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update recipe"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Recipe updated successfully", "recipe": recipe})
+}
+
+// DeleteRecipe deletes a recipe
+func (h *Handler) DeleteRecipe(c *gin.Context) {
+	recipe, ok := h.findRecipe(c)
+	if !ok {
+		return
+	}
+	if !canEditRecipe(c, recipe) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the creator or an admin can delete this recipe"})
+		return
+	}
+
+	if err := h.db.Delete(recipe).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete recipe"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Recipe deleted successfully"})
+}
+
+// RunRecipe applies a recipe to an uploaded file and sends the result as a download (xlsx
+// unless the "format" query parameter asks for another format)
+func (h *Handler) RunRecipe(c *gin.Context) {
+	recipe, ok := h.findRecipe(c)
+	if !ok {
+		return
+	}
+
+	format := strings.ToLower(c.DefaultQuery("format", "xlsx"))
+	if !services.IsExportFormat(format) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported export format: " + format})
+		return
+	}
+
+	var req models.RecipeRunRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var file models.ExcelFile
+	if err := h.scopedFiles(c).First(&file, req.FileID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	auditFiles(c, file.ID)
+
+	templatePath := ""
+	step := services.RecipeTemplateStep(*recipe)
+	if step != nil {
+		// Data taken from a submitted file may only go into a template once it is approved
+		if err := h.ensureApproved(file); err != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+
+		var templateFile models.ExcelFile
+		if err := h.scopedFiles(c).First(&templateFile, step.TemplateID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Template file not found"})
+			return
+		}
+		auditFiles(c, templateFile.ID)
+		templatePath = templateFile.FilePath
+	}
+
+	// A filled template is converted afterwards like other template exports
+	outputFormat := format
+	if step != nil {
+		outputFormat = "xlsx"
+	}
+	filePath, warnings, err := h.excel.RunRecipe(file.FilePath, *recipe, req, templatePath, outputFormat)
+	var valueErr *services.RowValueError
+	if errors.As(err, &valueErr) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Recipe failed: " + err.Error(), "warning": valueErr.Warning})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Recipe failed: " + err.Error()})
+		return
+	}
+
+	// The output is a file, so the rows settled by value policies travel in headers
+	c.Header(rowWarningsHeader, strconv.Itoa(len(warnings)))
+	if len(warnings) > 0 {
+		detail, truncated := warningsDetail(warnings)
+		if detail != "" {
+			c.Header(rowWarningsDetailHeader, detail)
+		}
+		if truncated {
+			c.Header(rowWarningsTruncatedHeader, "true")
+		}
+	}
+
+	baseName := "recipe_" + strconv.FormatUint(uint64(recipe.ID), 10)
+	if step != nil {
+		h.sendExport(c, filePath, baseName)
+		return
+	}
+	h.sendFile(c, filePath, baseName, format)
+}
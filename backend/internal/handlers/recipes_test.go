@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+
+	"excel-processor/internal/models"
+)
+
+func TestWarningsDetail(t *testing.T) {
+	detail, truncated := warningsDetail([]models.RowWarning{
+		{RowNumber: 2, Column: "B", Value: "không", Issue: models.IssueInvalidValue, Action: models.ValuePolicyZero},
+	})
+	// Vietnamese text is escaped so the detail can travel in a header
+	want := `[{"row_number":2,"column":"B","value":"kh\u00f4ng","issue":"invalid_value","action":"zero"}]`
+	if detail != want || truncated {
+		t.Errorf("warningsDetail = %s, %v; want %s, false", detail, truncated, want)
+	}
+
+	warnings := make([]models.RowWarning, 500)
+	for i := range warnings {
+		warnings[i] = models.RowWarning{Step: 1, RowNumber: i + 2, Column: "B", Value: "#N/A", Issue: models.IssueInvalidValue, Action: models.ValuePolicyZero}
+	}
+	detail, truncated = warningsDetail(warnings)
+	if !truncated || len(detail) > maxWarningsHeaderBytes {
+		t.Fatalf("warningsDetail of 500 warnings is %d bytes, truncated %v; want at most %d, truncated", len(detail), truncated, maxWarningsHeaderBytes)
+	}
+	var decoded []models.RowWarning
+	if err := json.Unmarshal([]byte(detail), &decoded); err != nil {
+		t.Fatalf("truncated detail is not JSON: %v", err)
+	}
+	if len(decoded) == 0 || decoded[0] != warnings[0] {
+		t.Errorf("truncated detail starts with %+v, want %+v", decoded, warnings[0])
+	}
+}
+
+func TestRunRecipeValuePolicies(t *testing.T) {
+	t.Chdir(t.TempDir())
+	f := excelize.NewFile()
+	rows := [][]interface{}{{"Lương", "Ngày công"}, {1000, 0}, {2000, "#N/A"}, {3000, 10}}
+	for i, row := range rows {
+		f.SetSheetRow("Sheet1", fmt.Sprintf("A%d", i+1), &row)
+	}
+	if err := f.SaveAs("luong.xlsx"); err != nil {
+		t.Fatalf("SaveAs returned %v", err)
+	}
+	f.Close()
+
+	h := newTestHandler(t)
+	divide := models.RecipeStep{Type: models.RecipeStepRowWise, SourceColumns: []string{"A", "B"}, TargetColumn: "C", Operation: "divide"}
+	strict := divide
+	strict.OnDivideByZero = models.ValuePolicyError
+	seed(t, h,
+		&models.ExcelFile{ID: 1, FileName: "luong.xlsx", FilePath: "luong.xlsx"},
+		&models.Recipe{ID: 1, Name: "Lương theo ngày", Steps: []models.RecipeStep{divide}},
+		&models.Recipe{ID: 2, Name: "Lương theo ngày (chặt)", Steps: []models.RecipeStep{strict}},
+	)
+	r := testRouter(&models.User{Username: "admin", Role: models.RoleAdmin})
+	r.POST("/recipes/:id/run", h.RunRecipe)
+
+	// Zero and invalid divisors default to being left out, so the salary stays as it is
+	w := serveJSON(r, http.MethodPost, "/recipes/1/run?format=csv", models.RecipeRunRequest{FileID: 1})
+	if w.Code != http.StatusOK {
+		t.Fatalf("run: status = %d, want 200: %s", w.Code, w.Body.String())
+	}
+	want := "\xEF\xBB\xBFrow_number;A;B;C\r\n2;1000;0;1000\r\n3;2000;#N/A;2000\r\n4;3000;10;300\r\n"
+	if w.Body.String() != want {
+		t.Errorf("output = %q, want %q", w.Body.String(), want)
+	}
+	if got := w.Header().Get(rowWarningsHeader); got != "3" {
+		t.Errorf("%s = %q, want 3", rowWarningsHeader, got)
+	}
+	var detail []models.RowWarning
+	if err := json.Unmarshal([]byte(w.Header().Get(rowWarningsDetailHeader)), &detail); err != nil || len(detail) != 3 {
+		t.Fatalf("%s = %q, want 3 warnings", rowWarningsDetailHeader, w.Header().Get(rowWarningsDetailHeader))
+	}
+	if detail[1].Step != 1 || detail[1].RowNumber != 3 || detail[1].Issue != models.IssueInvalidValue || detail[1].Action != models.ValuePolicyZero {
+		t.Errorf("second warning = %+v, want an invalid value on row 3 of step 1 taken as zero", detail[1])
+	}
+	if w.Header().Get(rowWarningsTruncatedHeader) != "" {
+		t.Errorf("%s is set for 3 warnings", rowWarningsTruncatedHeader)
+	}
+
+	w = serveJSON(r, http.MethodPost, "/recipes/2/run?format=csv", models.RecipeRunRequest{FileID: 1})
+	if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "row 2: division by zero") {
+		t.Errorf("error policy: status = %d %s, want 422 naming row 2", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,432 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"excel-processor/internal/models"
+	"excel-processor/internal/services"
+)
+
+// CreatePeriod creates a reporting period
+func (h *Handler) CreatePeriod(c *gin.Context) {
+	var req models.PeriodRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	period, err := services.NewReportingPeriod(req.Type, req.Year, req.Number)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	period.DueDate = req.DueDate
+
+	var existing models.ReportingPeriod
+	err = h.db.Where("type = ? AND year = ? AND number = ?", period.Type, period.Year, period.Number).First(&existing).Error
+	if err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Period already exists", "period": existing})
+		return
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check existing periods"})
+		return
+	}
+
+	if err := h.db.Create(&period).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save period"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Period created successfully", "period": period})
+}
+
+// GetPeriods returns reporting periods, newest first, optionally filtered by year and type
+func (h *Handler) GetPeriods(c *gin.Context) {
+	query := h.db.Order("start_date DESC, type")
+	if year := c.Query("year"); year != "" {
+		query = query.Where("year = ?", year)
+	}
+	if periodType := c.Query("type"); periodType != "" {
+		query = query.Where("type = ?", periodType)
+	}
+
+	var periods []models.ReportingPeriod
+	if err := query.Find(&periods).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load periods"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"periods": periods})
+}
+
+// CreateSubmission registers a unit's submission for a period, optionally with its file
+func (h *Handler) CreateSubmission(c *gin.Context) {
+	var req models.SubmissionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	unit := h.province.GetUnitByID(req.UnitID)
+	if unit == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unit not found"})
+		return
+	}
+	if !canAccessUnit(currentUser(c), unit) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You cannot submit for this unit"})
+		return
+	}
+
+	var period models.ReportingPeriod
+	if err := h.db.First(&period, req.PeriodID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Period not found"})
+		return
+	}
+
+	if req.TemplateID != nil {
+		var template models.ExcelFile
+		if err := h.scopedFiles(c).First(&template, *req.TemplateID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Template not found"})
+			return
+		}
+	}
+
+	var file models.ExcelFile
+	if req.FileID != nil {
+		if err := h.scopedFiles(c).First(&file, *req.FileID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "File not found"})
+			return
+		}
+		auditFiles(c, file.ID)
+		if file.UnitID != nil && *file.UnitID != unit.ID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "File belongs to another unit"})
+			return
+		}
+		// Shared files such as admin templates are visible to every unit and must not be claimed
+		if file.UnitID == nil && (file.UploadedBy == "" || file.UploadedBy != actorName(c)) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only files you uploaded can be submitted"})
+			return
+		}
+	}
+
+	var submission *models.Submission
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		submission, err = h.saveSubmission(tx, unit, period.ID, req.TemplateID, req.FileID, req.Note, actorName(c))
+		if err != nil || req.FileID == nil {
+			return err
+		}
+
+		// Tag the file with the unit and period it was submitted for
+		file.UnitID = &unit.ID
+		file.ProvinceID = &unit.ProvinceID
+		file.PeriodID = &period.ID
+		return tx.Save(&file).Error
+	})
+	if errors.Is(err, errSubmissionLocked) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Submission is already approved"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save submission"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Submission saved successfully", "submission": submission})
+}
+
+// GetSubmissions lists submissions filtered by province, unit, period and status
+func (h *Handler) GetSubmissions(c *gin.Context) {
+	query := h.scopedSubmissions(c).Preload("Period").Order("updated_at DESC")
+	for _, filter := range []string{"province_id", "unit_id", "period_id", "template_id", "status"} {
+		if value := c.Query(filter); value != "" {
+			query = query.Where(filter+" = ?", value)
+		}
+	}
+
+	var submissions []models.Submission
+	if err := query.Find(&submissions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load submissions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"submissions": submissions})
+}
+
+// UpdateSubmissionStatus moves a submission to another status, e.g. to "validated" after checks
+func (h *Handler) UpdateSubmissionStatus(c *gin.Context) {
+	var req models.SubmissionStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.reviewSubmission(c, req.Status, actorName(c), req.Note)
+}
+
+// ApproveSubmission approves the file of a submission
+func (h *Handler) ApproveSubmission(c *gin.Context) {
+	// The comment is optional, so an empty body is fine
+	var req models.ReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.reviewSubmission(c, models.SubmissionApproved, actorName(c), req.Comment)
+}
+
+// RejectSubmission rejects the file of a submission, reopening it for re-upload
+func (h *Handler) RejectSubmission(c *gin.Context) {
+	var req models.ReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if strings.TrimSpace(req.Comment) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A comment is required when rejecting"})
+		return
+	}
+
+	h.reviewSubmission(c, models.SubmissionRejected, actorName(c), req.Comment)
+}
+
+// GetSubmissionHistory returns the status transitions of a submission, oldest first
+func (h *Handler) GetSubmissionHistory(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid submission ID"})
+		return
+	}
+
+	var submission models.Submission
+	if err := h.scopedSubmissions(c).Preload("Events", func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at, id")
+	}).First(&submission, uint(id)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Submission not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"submission_id": submission.ID, "status": submission.Status, "events": submission.Events})
+}
+
+// GetSubmissionDashboard shows which units of a province have not yet submitted for a period
+func (h *Handler) GetSubmissionDashboard(c *gin.Context) {
+	provinceID, err := strconv.ParseUint(c.Query("province_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid province ID"})
+		return
+	}
+	periodID, err := strconv.ParseUint(c.Query("period_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid period ID"})
+		return
+	}
+
+	province := h.province.GetProvinceByID(uint(provinceID))
+	if province == nil || !canAccessProvince(currentUser(c), province.ID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Province not found"})
+		return
+	}
+	var period models.ReportingPeriod
+	if err := h.db.First(&period, uint(periodID)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Period not found"})
+		return
+	}
+
+	query := h.scopedSubmissions(c).Where("province_id = ? AND period_id = ?", province.ID, period.ID)
+	if templateID := c.Query("template_id"); templateID != "" {
+		query = query.Where("template_id = ?", templateID)
+	}
+	var submissions []models.Submission
+	if err := query.Find(&submissions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load submissions"})
+		return
+	}
+
+	units := h.visibleUnits(c, province.ID)
+	c.JSON(http.StatusOK, services.BuildSubmissionDashboard(*province, period, units, submissions))
+}
+
+// reviewSubmission moves the submission in the :id parameter to a new status and records
+// the transition
+func (h *Handler) reviewSubmission(c *gin.Context, status, actor, comment string) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid submission ID"})
+		return
+	}
+
+	var submission models.Submission
+	if err := h.scopedSubmissions(c).First(&submission, uint(id)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Submission not found"})
+		return
+	}
+	if status != models.SubmissionPending && submission.FileID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Submission has no file yet"})
+		return
+	}
+	if submission.FileID != nil {
+		auditFiles(c, *submission.FileID)
+	}
+	if !services.CanTransition(submission.Status, status) {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Cannot change a %s submission to %s", submission.Status, status)})
+		return
+	}
+
+	if comment != "" {
+		submission.Note = comment
+	}
+	if err := h.transitionSubmission(h.db, &submission, status, actor, comment); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update submission"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Submission updated successfully", "submission": submission})
+}
+
+// transitionSubmission saves a submission with its new status and records the transition
+func (h *Handler) transitionSubmission(db *gorm.DB, submission *models.Submission, status, actor, comment string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		event := models.SubmissionEvent{
+			FromStatus: submission.Status,
+			ToStatus:   status,
+			Actor:      actor,
+			Comment:    comment,
+			FileID:     submission.FileID,
+		}
+		if submission.ID == 0 {
+			event.FromStatus = ""
+		}
+
+		submission.Status = status
+		if err := tx.Save(submission).Error; err != nil {
+			return err
+		}
+
+		event.SubmissionID = submission.ID
+		return tx.Create(&event).Error
+	})
+}
+
+// findSubmission returns the submission of a unit for a period and template, or nil if the
+// unit has none yet
+func (h *Handler) findSubmission(db *gorm.DB, unitID, periodID uint, templateID *uint) (*models.Submission, error) {
+	query := db.Where("unit_id = ? AND period_id = ?", unitID, periodID)
+	if templateID != nil {
+		query = query.Where("template_id = ?", *templateID)
+	} else {
+		query = query.Where("template_id IS NULL")
+	}
+
+	var submissions []models.Submission
+	if err := query.Limit(1).Find(&submissions).Error; err != nil {
+		return nil, err
+	}
+	if len(submissions) == 0 {
+		return nil, nil
+	}
+	return &submissions[0], nil
+}
+
+// saveSubmission creates or updates the submission of a unit for a period and template.
+// Attaching a file marks the submission as submitted; a re-upload replaces the previous file
+// unless the submission was already approved.
+func (h *Handler) saveSubmission(db *gorm.DB, unit *models.Unit, periodID uint, templateID, fileID *uint, note, actor string) (*models.Submission, error) {
+	submission, err := h.findSubmission(db, unit.ID, periodID, templateID)
+	if err != nil {
+		return nil, err
+	}
+	if submission == nil {
+		submission = &models.Submission{
+			UnitID:     unit.ID,
+			ProvinceID: unit.ProvinceID,
+			PeriodID:   periodID,
+			TemplateID: templateID,
+			Status:     models.SubmissionPending,
+		}
+	}
+
+	if note != "" {
+		submission.Note = note
+	}
+	if fileID == nil {
+		if submission.ID != 0 {
+			return submission, db.Save(submission).Error
+		}
+		return submission, h.transitionSubmission(db, submission, models.SubmissionPending, actor, note)
+	}
+
+	if !services.CanTransition(submission.Status, models.SubmissionSubmitted) {
+		return nil, errSubmissionLocked
+	}
+	now := time.Now()
+	submission.FileID = fileID
+	submission.SubmittedAt = &now
+	comment := note
+	if comment == "" {
+		comment = fmt.Sprintf("File %d uploaded", *fileID)
+	}
+	if err := h.transitionSubmission(db, submission, models.SubmissionSubmitted, actor, comment); err != nil {
+		return nil, err
+	}
+	return submission, nil
+}
+
+// errSubmissionLocked is returned when a file is submitted for an already approved submission
+var errSubmissionLocked = errors.New("submission is already approved and cannot be replaced")
+
+// ensureApproved rejects unit files that are not the file of an approved submission, whether
+// or not they were uploaded for a reporting period. Files that belong to no unit, such as
+// templates, pass.
+func (h *Handler) ensureApproved(file models.ExcelFile) error {
+	var count int64
+	if err := h.db.Model(&models.Submission{}).
+		Where("file_id = ? AND status = ?", file.ID, models.SubmissionApproved).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	if file.UnitID == nil {
+		if err := h.db.Model(&models.Submission{}).Where("file_id = ?", file.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return nil
+		}
+	}
+	return fmt.Errorf("file %d (%s) is not an approved submission", file.ID, file.FileName)
+}
+
+// approvedSource loads a source file in the caller's scope whose data is about to go into a
+// report or template. It answers the request itself when the file is missing or not approved.
+func (h *Handler) approvedSource(c *gin.Context, fileID uint) (models.ExcelFile, bool) {
+	var file models.ExcelFile
+	if err := h.scopedFiles(c).First(&file, fileID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Source file not found"})
+		return file, false
+	}
+	if err := h.ensureApproved(file); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return file, false
+	}
+	return file, true
+}
+
+// approvedFileIDs selects the files of approved submissions
+func (h *Handler) approvedFileIDs() *gorm.DB {
+	return h.db.Model(&models.Submission{}).Select("file_id").Where("status = ?", models.SubmissionApproved)
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"excel-processor/internal/models"
+)
+
+func TestEnsureApproved(t *testing.T) {
+	h := newTestHandler(t)
+	seed(t, h,
+		&models.ExcelFile{ID: 1, FileName: "approved.xlsx", FilePath: "uploads/1.xlsx", ProvinceID: uintPtr(1), UnitID: uintPtr(2)},
+		&models.ExcelFile{ID: 2, FileName: "submitted.xlsx", FilePath: "uploads/2.xlsx", ProvinceID: uintPtr(1), UnitID: uintPtr(2)},
+		&models.ExcelFile{ID: 3, FileName: "loose.xlsx", FilePath: "uploads/3.xlsx", ProvinceID: uintPtr(1), UnitID: uintPtr(3)},
+		&models.ExcelFile{ID: 4, FileName: "template.xlsx", FilePath: "templates/4.xlsx"},
+		&models.ExcelFile{ID: 5, FileName: "shared.xlsx", FilePath: "uploads/5.xlsx"},
+		&models.Submission{UnitID: 2, ProvinceID: 1, PeriodID: 1, FileID: uintPtr(1), Status: models.SubmissionApproved},
+		&models.Submission{UnitID: 2, ProvinceID: 1, PeriodID: 2, FileID: uintPtr(2), Status: models.SubmissionSubmitted},
+		&models.Submission{UnitID: 4, ProvinceID: 1, PeriodID: 1, FileID: uintPtr(5), Status: models.SubmissionValidated},
+	)
+
+	tests := []struct {
+		fileID uint
+		want   bool
+	}{
+		{1, true},  // file of an approved submission
+		{2, false}, // submitted but not approved
+		{3, false}, // unit file that was never submitted
+		{4, true},  // template without a unit
+		{5, false}, // shared file under review for a unit
+	}
+	for _, tt := range tests {
+		var file models.ExcelFile
+		h.db.First(&file, tt.fileID)
+		if err := h.ensureApproved(file); (err == nil) != tt.want {
+			t.Errorf("ensureApproved(%s) = %v, want approved %v", file.FileName, err, tt.want)
+		}
+	}
+}
+
+func TestReviewSubmission(t *testing.T) {
+	h := newTestHandler(t)
+	seed(t, h,
+		&models.ExcelFile{ID: 1, FileName: "report.xlsx", FilePath: "uploads/1.xlsx", ProvinceID: uintPtr(1), UnitID: uintPtr(2)},
+		&models.ExcelFile{ID: 2, FileName: "other.xlsx", FilePath: "uploads/2.xlsx", ProvinceID: uintPtr(2), UnitID: uintPtr(6)},
+		&models.Submission{ID: 1, UnitID: 2, ProvinceID: 1, PeriodID: 1, FileID: uintPtr(1), Status: models.SubmissionSubmitted},
+		&models.Submission{ID: 2, UnitID: 6, ProvinceID: 2, PeriodID: 1, FileID: uintPtr(2), Status: models.SubmissionSubmitted},
+		&models.Submission{ID: 3, UnitID: 3, ProvinceID: 1, PeriodID: 1, Status: models.SubmissionPending},
+	)
+	r := testRouter(&models.User{Username: "reviewer", Role: models.RoleProvinceOfficer, ProvinceID: uintPtr(1)})
+	r.POST("/submissions/:id/approve", h.ApproveSubmission)
+	r.POST("/submissions/:id/reject", h.RejectSubmission)
+	r.PUT("/submissions/:id/status", h.UpdateSubmissionStatus)
+	r.GET("/submissions/:id/history", h.GetSubmissionHistory)
+
+	steps := []struct {
+		name   string
+		method string
+		target string
+		body   interface{}
+		want   int
+	}{
+		{"reject without a comment", http.MethodPost, "/submissions/1/reject", models.ReviewRequest{}, http.StatusBadRequest},
+		{"validate", http.MethodPut, "/submissions/1/status", models.SubmissionStatusRequest{Status: models.SubmissionValidated}, http.StatusOK},
+		{"approve without a body", http.MethodPost, "/submissions/1/approve", nil, http.StatusOK},
+		{"approve twice", http.MethodPost, "/submissions/1/approve", nil, http.StatusConflict},
+		{"reject an approved submission", http.MethodPost, "/submissions/1/reject", models.ReviewRequest{Comment: "Sai số liệu"}, http.StatusConflict},
+		{"reopen an approved submission", http.MethodPut, "/submissions/1/status", models.SubmissionStatusRequest{Status: models.SubmissionSubmitted}, http.StatusConflict},
+		{"another province", http.MethodPost, "/submissions/2/approve", nil, http.StatusNotFound},
+		{"no file yet", http.MethodPost, "/submissions/3/approve", nil, http.StatusBadRequest},
+	}
+	for _, step := range steps {
+		if w := serveJSON(r, step.method, step.target, step.body); w.Code != step.want {
+			t.Errorf("%s: status = %d, want %d: %s", step.name, w.Code, step.want, w.Body.String())
+		}
+	}
+
+	w := serveJSON(r, http.MethodGet, "/submissions/1/history", nil)
+	var history struct {
+		Status string                   `json:"status"`
+		Events []models.SubmissionEvent `json:"events"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &history); err != nil {
+		t.Fatalf("history is not JSON: %v", err)
+	}
+	if history.Status != models.SubmissionApproved || len(history.Events) != 2 {
+		t.Fatalf("history = %s with %d events, want approved with 2", history.Status, len(history.Events))
+	}
+	approval := history.Events[1]
+	if approval.FromStatus != models.SubmissionValidated || approval.ToStatus != models.SubmissionApproved || approval.Actor != "reviewer" {
+		t.Errorf("last event = %s -> %s by %s, want validated -> approved by reviewer", approval.FromStatus, approval.ToStatus, approval.Actor)
+	}
+	if approval.FileID == nil || *approval.FileID != 1 {
+		t.Errorf("last event file = %v, want 1", approval.FileID)
+	}
+}
+
+func TestCreateSubmission(t *testing.T) {
+	h := newTestHandler(t)
+	seed(t, h,
+		&models.ReportingPeriod{ID: 1, Type: models.PeriodMonth, Year: 2025, Number: 1},
+		&models.ReportingPeriod{ID: 2, Type: models.PeriodMonth, Year: 2025, Number: 2},
+		&models.ReportingPeriod{ID: 3, Type: models.PeriodMonth, Year: 2025, Number: 3},
+		&models.ExcelFile{ID: 1, FileName: "unit2.xlsx", FilePath: "uploads/1.xlsx", ProvinceID: uintPtr(1), UnitID: uintPtr(2), UploadedBy: "an"},
+		&models.ExcelFile{ID: 2, FileName: "mine.xlsx", FilePath: "uploads/2.xlsx", UploadedBy: "an"},
+		&models.ExcelFile{ID: 3, FileName: "template.xlsx", FilePath: "templates/3.xlsx", UploadedBy: "admin"},
+		&models.ExcelFile{ID: 4, FileName: "unit3.xlsx", FilePath: "uploads/4.xlsx", ProvinceID: uintPtr(1), UnitID: uintPtr(3)},
+	)
+	r := testRouter(&models.User{Username: "an", Role: models.RoleUnitOfficer, ProvinceID: uintPtr(1), UnitID: uintPtr(2)})
+	r.POST("/submissions", h.CreateSubmission)
+
+	steps := []struct {
+		name string
+		body models.SubmissionRequest
+		want int
+	}{
+		{"own unit file", models.SubmissionRequest{UnitID: 2, PeriodID: 1, FileID: uintPtr(1)}, http.StatusOK},
+		{"shared file the officer uploaded", models.SubmissionRequest{UnitID: 2, PeriodID: 2, FileID: uintPtr(2)}, http.StatusOK},
+		{"shared file someone else uploaded", models.SubmissionRequest{UnitID: 2, PeriodID: 3, FileID: uintPtr(3)}, http.StatusForbidden},
+		{"file of another unit", models.SubmissionRequest{UnitID: 2, PeriodID: 3, FileID: uintPtr(4)}, http.StatusBadRequest},
+		{"another unit", models.SubmissionRequest{UnitID: 3, PeriodID: 3}, http.StatusForbidden},
+		{"unknown period", models.SubmissionRequest{UnitID: 2, PeriodID: 9}, http.StatusBadRequest},
+	}
+	for _, step := range steps {
+		if w := serveJSON(r, http.MethodPost, "/submissions", step.body); w.Code != step.want {
+			t.Errorf("%s: status = %d, want %d: %s", step.name, w.Code, step.want, w.Body.String())
+		}
+	}
+
+	// The claimed shared file now belongs to the unit and period
+	var file models.ExcelFile
+	h.db.First(&file, 2)
+	if derefUint(file.UnitID) != 2 || derefUint(file.ProvinceID) != 1 || derefUint(file.PeriodID) != 2 {
+		t.Errorf("file 2 is tagged unit %d, province %d, period %d; want 2, 1, 2", derefUint(file.UnitID), derefUint(file.ProvinceID), derefUint(file.PeriodID))
+	}
+	// Rejected requests leave nothing behind
+	var count int64
+	h.db.Model(&models.Submission{}).Where("period_id = ?", 3).Count(&count)
+	if count != 0 {
+		t.Errorf("%d submissions saved for period 3, want none", count)
+	}
+	var template models.ExcelFile
+	h.db.First(&template, 3)
+	if template.UnitID != nil || template.PeriodID != nil {
+		t.Error("the shared template was tagged with a unit")
+	}
+
+	// An approved submission cannot be replaced
+	h.db.Model(&models.Submission{}).Where("period_id = ?", 1).Update("status", models.SubmissionApproved)
+	w := serveJSON(r, http.MethodPost, "/submissions", models.SubmissionRequest{UnitID: 2, PeriodID: 1, FileID: uintPtr(1)})
+	if w.Code != http.StatusConflict {
+		t.Errorf("resubmitting an approved submission: status = %d, want 409", w.Code)
+	}
+}
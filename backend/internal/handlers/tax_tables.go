@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"excel-processor/internal/models"
+	"excel-processor/internal/services"
+)
+
+// findTaxTable loads the tax table in the :id parameter, answering with an error if it does
+// not exist
+func (h *Handler) findTaxTable(c *gin.Context) (*models.TaxTable, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tax table ID"})
+		return nil, false
+	}
+
+	var table models.TaxTable
+	if err := h.db.First(&table, uint(id)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tax table not found"})
+		return nil, false
+	}
+	return &table, true
+}
+
+// taxTableForCalculation loads the tax table a calculation asked for by version, or else the
+// latest one in effect, answering with an error if there is none
+func (h *Handler) taxTableForCalculation(c *gin.Context, version string) (*models.TaxTable, bool) {
+	var table models.TaxTable
+	query := h.db.Where("effective_from <= ?", time.Now()).Order("effective_from DESC")
+	if version != "" {
+		query = h.db.Where("version = ?", version)
+	}
+	if err := query.First(&table).Error; err != nil {
+		if version != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Tax table not found: " + version})
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No tax table is in effect"})
+		}
+		return nil, false
+	}
+	return &table, true
+}
+
+// taxTableInUse answers with an error and reports true when saved calculations were made with
+// a tax table version. Those versions stay as they are so the history keeps its meaning.
+func (h *Handler) taxTableInUse(c *gin.Context, table *models.TaxTable) bool {
+	var count int64
+	if err := h.db.Model(&models.Calculation{}).Where("json_extract(request, '$.tax_table') = ?", table.Version).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check tax table usage"})
+		return true
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Tax table %s is used by %d saved calculations; add a new version instead", table.Version, count)})
+		return true
+	}
+	return false
+}
+
+// bindTaxTable reads and checks a tax table request
+func bindTaxTable(c *gin.Context) (*models.TaxTableRequest, bool) {
+	var req models.TaxTableRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	req.Version = strings.TrimSpace(req.Version)
+	if err := services.ValidateTaxTable(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return &req, true
+}
+
+// GetTaxTables lists the tax table versions, newest first, with the functions formulas can call
+func (h *Handler) GetTaxTables(c *gin.Context) {
+	var tables []models.TaxTable
+	if err := h.db.Order("effective_from DESC").Find(&tables).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load tax tables"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tax_tables": tables, "functions": services.FormulaFunctionNames()})
+}
+
+// GetTaxTable returns a tax table
+func (h *Handler) GetTaxTable(c *gin.Context) {
+	table, ok := h.findTaxTable(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tax_table": table})
+}
+
+// CreateTaxTable adds a tax table version
+func (h *Handler) CreateTaxTable(c *gin.Context) {
+	req, ok := bindTaxTable(c)
+	if !ok {
+		return
+	}
+
+	var count int64
+	h.db.Model(&models.TaxTable{}).Where("version = ?", req.Version).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "A tax table with this version already exists"})
+		return
+	}
+
+	table := models.TaxTable{
+		Version:            req.Version,
+		EffectiveFrom:      req.EffectiveFrom,
+		Description:        req.Description,
+		Brackets:           req.Brackets,
+		SelfDeduction:      req.SelfDeduction,
+		DependantDeduction: req.DependantDeduction,
+		Insurance:          req.Insurance,
+		CreatedBy:          actorName(c),
+	}
+	if err := h.db.Create(&table).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save tax table"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Tax table created successfully", "tax_table": table})
+}
+
+// UpdateTaxTable replaces the rates of a tax table version that no calculation has used yet
+func (h *Handler) UpdateTaxTable(c *gin.Context) {
+	table, ok := h.findTaxTable(c)
+	if !ok || h.taxTableInUse(c, table) {
+		return
+	}
+
+	req, ok := bindTaxTable(c)
+	if !ok {
+		return
+	}
+
+	var count int64
+	h.db.Model(&models.TaxTable{}).Where("version = ? AND id <> ?", req.Version, table.ID).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "A tax table with this version already exists"})
+		return
+	}
+
+	table.Version = req.Version
+	table.EffectiveFrom = req.EffectiveFrom
+	table.Description = req.Description
+	table.Brackets = req.Brackets
+	table.SelfDeduction = req.SelfDeduction
+	table.DependantDeduction = req.DependantDeduction
+	table.Insurance = req.Insurance
+	if err := h.db.Save(table).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update tax table"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Tax table updated successfully", "tax_table": table})
+}
+
+// DeleteTaxTable deletes a tax table version that no calculation has used
+func (h *Handler) DeleteTaxTable(c *gin.Context) {
+	table, ok := h.findTaxTable(c)
+	if !ok || h.taxTableInUse(c, table) {
+		return
+	}
+
+	if err := h.db.Delete(table).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete tax table"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Tax table deleted successfully"})
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"excel-processor/internal/models"
+	"excel-processor/internal/services"
+)
+
+// taxTableRequest returns the default rates as a request for the given version
+func taxTableRequest(version string) models.TaxTableRequest {
+	table := services.DefaultTaxTable()
+	return models.TaxTableRequest{
+		Version:            version,
+		EffectiveFrom:      time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		Brackets:           table.Brackets,
+		SelfDeduction:      table.SelfDeduction,
+		DependantDeduction: table.DependantDeduction,
+		Insurance:          table.Insurance,
+	}
+}
+
+func TestTaxTableVersions(t *testing.T) {
+	h := newTestHandler(t)
+	used := services.DefaultTaxTable()
+	used.ID = 1
+	unused := services.DefaultTaxTable()
+	unused.ID, unused.Version = 2, "2025-01"
+	seed(t, h, &used, &unused,
+		&models.Calculation{Kind: models.CalculationRowWise, FileID: 1, Request: json.RawMessage(`{"file_id":1,"tax_table":"2024-07"}`)},
+	)
+	r := testRouter(&models.User{Username: "admin", Role: models.RoleAdmin})
+	r.POST("/tax-tables", h.CreateTaxTable)
+	r.PUT("/tax-tables/:id", h.UpdateTaxTable)
+	r.DELETE("/tax-tables/:id", h.DeleteTaxTable)
+
+	unordered := taxTableRequest("2026-01")
+	unordered.Brackets = []models.TaxBracket{{UpTo: 10e6, Rate: 0.1}, {UpTo: 5e6, Rate: 0.2}, {Rate: 0.35}}
+	badRate := taxTableRequest("2026-01")
+	badRate.Insurance.Employee.Social = 8
+
+	steps := []struct {
+		name   string
+		method string
+		target string
+		body   interface{}
+		want   int
+	}{
+		{"brackets out of order", http.MethodPost, "/tax-tables", unordered, http.StatusBadRequest},
+		{"rate above 100%", http.MethodPost, "/tax-tables", badRate, http.StatusBadRequest},
+		{"existing version", http.MethodPost, "/tax-tables", taxTableRequest("2024-07"), http.StatusConflict},
+		{"new version", http.MethodPost, "/tax-tables", taxTableRequest(" 2026-01 "), http.StatusCreated},
+		{"edit a version in use", http.MethodPut, "/tax-tables/1", taxTableRequest("2024-07"), http.StatusConflict},
+		{"delete a version in use", http.MethodDelete, "/tax-tables/1", nil, http.StatusConflict},
+		{"rename to an existing version", http.MethodPut, "/tax-tables/2", taxTableRequest("2026-01"), http.StatusConflict},
+		{"bad brackets on edit", http.MethodPut, "/tax-tables/2", unordered, http.StatusBadRequest},
+		{"edit an unused version", http.MethodPut, "/tax-tables/2", taxTableRequest("2025-01"), http.StatusOK},
+		{"delete an unused version", http.MethodDelete, "/tax-tables/2", nil, http.StatusOK},
+		{"unknown version", http.MethodPut, "/tax-tables/99", taxTableRequest("2025-01"), http.StatusNotFound},
+	}
+	for _, step := range steps {
+		if w := serveJSON(r, step.method, step.target, step.body); w.Code != step.want {
+			t.Errorf("%s: status = %d, want %d: %s", step.name, w.Code, step.want, w.Body.String())
+		}
+	}
+
+	var versions []string
+	h.db.Model(&models.TaxTable{}).Order("version").Pluck("version", &versions)
+	if len(versions) != 2 || versions[0] != "2024-07" || versions[1] != "2026-01" {
+		t.Errorf("versions = %q, want 2024-07 and 2026-01", versions)
+	}
+}
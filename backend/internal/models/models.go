@@ -1,7 +1,11 @@
 package models
 
 import (
+	"encoding/json"
+	"errors"
 	"time"
+
+	"gorm.io/gorm"
 )
 
 // Province represents a province/city
@@ -33,38 +37,369 @@ type ExcelFile struct {
 	FileSize   int64     `json:"file_size"`
 	ProvinceID *uint     `json:"province_id,omitempty"`
 	UnitID     *uint     `json:"unit_id,omitempty"`
+	PeriodID   *uint     `json:"period_id,omitempty" gorm:"index"` // Reporting period the file was submitted for
+	UploadedBy string    `json:"uploaded_by,omitempty"`            // Username of the uploader
 	Province   *Province `json:"province,omitempty" gorm:"foreignKey:ProvinceID"`
 	Unit       *Unit     `json:"unit,omitempty" gorm:"foreignKey:UnitID"`
 	CreatedAt  time.Time `json:"created_at"`
 	UpdatedAt  time.Time `json:"updated_at"`
 }
 
+// User roles
+const (
+	RoleAdmin           = "admin"            // Manages users, periods and templates, sees everything
+	RoleProvinceOfficer = "province_officer" // Works with all units of their province and reviews submissions
+	RoleUnitOfficer     = "unit_officer"     // Uploads and works with the files of their unit only
+	RoleViewer          = "viewer"           // Read-only, optionally limited to a province or unit
+	RoleAPIKey          = "api_key"          // Script using a write API key: uploads within the key's scope, never reviews
+)
+
+// User represents an account that can sign in to the API
+type User struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	Username     string     `json:"username" gorm:"unique;not null"`
+	PasswordHash string     `json:"-" gorm:"not null"`
+	FullName     string     `json:"full_name"`
+	Role         string     `json:"role" gorm:"not null;default:viewer"`
+	ProvinceID   *uint      `json:"province_id,omitempty"` // Province the user is limited to
+	UnitID       *uint      `json:"unit_id,omitempty"`     // Unit the user is limited to
+	Active       bool       `json:"active" gorm:"not null;default:true"`
+	LastLoginAt  *time.Time `json:"last_login_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// LoginRequest represents a username/password sign-in
+type LoginRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// LoginResponse carries the access token to send as "Authorization: Bearer <token>"
+type LoginResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+	User      User      `json:"user"`
+}
+
+// UserRequest represents a request to create a user account. Province officers need a
+// province, unit officers a unit; viewers may have either to narrow what they see.
+type UserRequest struct {
+	Username   string `json:"username" binding:"required,min=3,max=64"`
+	Password   string `json:"password" binding:"required"`
+	FullName   string `json:"full_name"`
+	Role       string `json:"role" binding:"required,oneof=admin province_officer unit_officer viewer"`
+	ProvinceID *uint  `json:"province_id,omitempty"`
+	UnitID     *uint  `json:"unit_id,omitempty"`
+}
+
+// UserUpdateRequest represents an admin's change to a user account. Omitted fields are kept;
+// the province and unit are always replaced together with the role.
+type UserUpdateRequest struct {
+	FullName   *string `json:"full_name,omitempty"`
+	Role       string  `json:"role" binding:"omitempty,oneof=admin province_officer unit_officer viewer"`
+	ProvinceID *uint   `json:"province_id,omitempty"`
+	UnitID     *uint   `json:"unit_id,omitempty"`
+	Active     *bool   `json:"active,omitempty"`
+	Password   string  `json:"password,omitempty"` // Resets the password
+}
+
+// ChangePasswordRequest represents a user changing their own password
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+// APIKey is a revocable key for scripts, sent as the X-API-Key header. Only a hash of the
+// key is stored; the key itself is shown once when it is created.
+type APIKey struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	Name       string     `json:"name" gorm:"not null"`
+	Prefix     string     `json:"prefix" gorm:"not null"` // First characters of the key, to tell keys apart
+	KeyHash    string     `json:"-" gorm:"uniqueIndex;not null"`
+	ProvinceID *uint      `json:"province_id,omitempty"`
+	UnitID     *uint      `json:"unit_id,omitempty"` // Unit the key is limited to, nil for a global key
+	ReadOnly   bool       `json:"read_only"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedBy  string     `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// APIKeyRequest represents a request to create an API key
+type APIKeyRequest struct {
+	Name      string     `json:"name" binding:"required,max=100"`
+	UnitID    *uint      `json:"unit_id,omitempty"` // Omit for a global key
+	ReadOnly  bool       `json:"read_only"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// AuditLog records one data-changing request. Entries are append-only.
+type AuditLog struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	Action     string    `json:"action" gorm:"not null;index"` // e.g. "upload", "merge", "export"
+	Actor      string    `json:"actor" gorm:"index"`
+	UserID     *uint     `json:"user_id,omitempty"` // Nil for API keys
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	FileIDs    []uint    `json:"file_ids" gorm:"serializer:json"`    // Files read or created by the request
+	Params     string    `json:"params" gorm:"type:text"`            // Query, form fields and body as JSON
+	InputHash  string    `json:"input_hash,omitempty" gorm:"index"`  // SHA-256 of the uploaded file
+	OutputName string    `json:"output_name,omitempty"`              // Name of the downloaded file
+	OutputHash string    `json:"output_hash,omitempty" gorm:"index"` // SHA-256 of the response sent
+	Status     int       `json:"status"`
+	Error      string    `json:"error,omitempty"`
+	ClientIP   string    `json:"client_ip"`
+	CreatedAt  time.Time `json:"created_at" gorm:"index"`
+}
+
+// BeforeUpdate keeps audit entries from being changed
+func (a *AuditLog) BeforeUpdate(tx *gorm.DB) error {
+	return errors.New("audit log entries cannot be changed")
+}
+
+// BeforeDelete keeps audit entries from being deleted
+func (a *AuditLog) BeforeDelete(tx *gorm.DB) error {
+	return errors.New("audit log entries cannot be deleted")
+}
+
+// AuditQuery filters the audit log. Hash matches either the input or the output hash.
+type AuditQuery struct {
+	Action string     `form:"action"`
+	Actor  string     `form:"actor"`
+	FileID uint       `form:"file_id"`
+	Hash   string     `form:"hash"`
+	From   *time.Time `form:"from" time_format:"2006-01-02"`
+	To     *time.Time `form:"to" time_format:"2006-01-02"` // Inclusive
+	Limit  int        `form:"limit" binding:"omitempty,min=1,max=1000"`
+	Offset int        `form:"offset" binding:"omitempty,min=0"`
+}
+
+// Reporting period types
+const (
+	PeriodMonth   = "month"
+	PeriodQuarter = "quarter"
+	PeriodYear    = "year"
+)
+
+// ReportingPeriod represents a month, quarter or year that units report for
+type ReportingPeriod struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	Type      string     `json:"type" gorm:"not null;uniqueIndex:idx_period"`
+	Year      int        `json:"year" gorm:"not null;uniqueIndex:idx_period"`
+	Number    int        `json:"number" gorm:"uniqueIndex:idx_period"` // Month 1-12 or quarter 1-4, 0 for a year
+	Name      string     `json:"name"`                                 // e.g. "Tháng 03/2025", "Quý 1/2025"
+	StartDate time.Time  `json:"start_date"`
+	EndDate   time.Time  `json:"end_date"`
+	DueDate   *time.Time `json:"due_date,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// Submission statuses
+const (
+	SubmissionPending   = "pending"
+	SubmissionSubmitted = "submitted"
+	SubmissionValidated = "validated"
+	SubmissionRejected  = "rejected"
+	SubmissionApproved  = "approved"
+)
+
+// Submission links the file a unit submitted for a reporting period and template
+type Submission struct {
+	ID          uint              `json:"id" gorm:"primaryKey"`
+	UnitID      uint              `json:"unit_id" gorm:"not null;uniqueIndex:idx_submission;uniqueIndex:idx_submission_no_template,where:template_id IS NULL"`
+	ProvinceID  uint              `json:"province_id" gorm:"not null;index"`
+	PeriodID    uint              `json:"period_id" gorm:"not null;uniqueIndex:idx_submission;uniqueIndex:idx_submission_no_template,where:template_id IS NULL"`
+	TemplateID  *uint             `json:"template_id,omitempty" gorm:"uniqueIndex:idx_submission"` // NULLs never clash in idx_submission, so idx_submission_no_template covers them
+	FileID      *uint             `json:"file_id,omitempty"`
+	Status      string            `json:"status" gorm:"not null;default:pending"`
+	Note        string            `json:"note,omitempty"`
+	SubmittedAt *time.Time        `json:"submitted_at,omitempty"`
+	Period      *ReportingPeriod  `json:"period,omitempty" gorm:"foreignKey:PeriodID"`
+	File        *ExcelFile        `json:"file,omitempty" gorm:"foreignKey:FileID"`
+	Events      []SubmissionEvent `json:"events,omitempty" gorm:"foreignKey:SubmissionID"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+// SubmissionEvent records one status transition of a submission
+type SubmissionEvent struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	SubmissionID uint      `json:"submission_id" gorm:"not null;index"`
+	FromStatus   string    `json:"from_status"` // Empty when the submission was created
+	ToStatus     string    `json:"to_status" gorm:"not null"`
+	Actor        string    `json:"actor"` // Who made the change
+	Comment      string    `json:"comment,omitempty"`
+	FileID       *uint     `json:"file_id,omitempty"` // File under review at the time
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// PeriodRequest represents a request to create a reporting period
+type PeriodRequest struct {
+	Type    string     `json:"type" binding:"required,oneof=month quarter year"`
+	Year    int        `json:"year" binding:"required,min=2000,max=2100"`
+	Number  int        `json:"number"` // Month 1-12 or quarter 1-4, ignored for a year
+	DueDate *time.Time `json:"due_date,omitempty"`
+}
+
+// SubmissionRequest represents a request to register a unit's submission for a period
+type SubmissionRequest struct {
+	UnitID     uint   `json:"unit_id" binding:"required"`
+	PeriodID   uint   `json:"period_id" binding:"required"`
+	TemplateID *uint  `json:"template_id,omitempty"`
+	FileID     *uint  `json:"file_id,omitempty"` // Submitted file (the submission stays pending without one)
+	Note       string `json:"note,omitempty"`
+}
+
+// SubmissionStatusRequest represents a status change of a submission
+type SubmissionStatusRequest struct {
+	Status string `json:"status" binding:"required,oneof=pending submitted validated rejected approved"`
+	Note   string `json:"note,omitempty"`
+}
+
+// ReviewRequest represents the signed-in user's approval or rejection of a submission
+type ReviewRequest struct {
+	Comment string `json:"comment,omitempty"` // Required when rejecting
+}
+
+// UnitSubmissionStatus is one unit's row on the submission dashboard
+type UnitSubmissionStatus struct {
+	UnitID       uint       `json:"unit_id"`
+	UnitCode     string     `json:"unit_code"`
+	UnitName     string     `json:"unit_name"`
+	Status       string     `json:"status"` // "missing" when the unit has no submission
+	SubmissionID *uint      `json:"submission_id,omitempty"`
+	FileID       *uint      `json:"file_id,omitempty"`
+	SubmittedAt  *time.Time `json:"submitted_at,omitempty"`
+}
+
+// SubmissionDashboard summarises which units of a province have submitted for a period
+type SubmissionDashboard struct {
+	Province     Province               `json:"province"`
+	Period       ReportingPeriod        `json:"period"`
+	TotalUnits   int                    `json:"total_units"`
+	StatusCounts map[string]int         `json:"status_counts"`
+	NotSubmitted []UnitSubmissionStatus `json:"not_submitted"` // Units without a submission, still pending or rejected
+	Units        []UnitSubmissionStatus `json:"units"`
+	Overdue      bool                   `json:"overdue"` // Due date passed with units not submitted
+}
+
 // SheetInfo represents information about an Excel sheet
 type SheetInfo struct {
-	Name    string   `json:"name"`
-	Columns []string `json:"columns"`
-	RowCount int     `json:"row_count"`
+	Name     string      `json:"name"`
+	Columns  []string    `json:"columns"`
+	RowCount int         `json:"row_count"`
+	Region   *DataRegion `json:"region,omitempty"` // Detected data table, nil for an empty sheet
+
+	Headers       map[string]string `json:"headers,omitempty"`        // Column letter -> header name, e.g. "Thu nhập / Lương"
+	MergedRegions []MergedRegion    `json:"merged_regions,omitempty"` // Merged cell ranges of the sheet
+}
+
+// MergedRegion is a merged cell range and the value shown across it
+type MergedRegion struct {
+	Range string `json:"range"` // e.g. "H6:Y6"
+	Value string `json:"value"`
+}
+
+// DataRegion is the data table detected on a sheet. Row numbers are 1-based; 0 means not found.
+type DataRegion struct {
+	HeaderRow    int   `json:"header_row"`
+	HeaderEndRow int   `json:"header_end_row"` // Last header row when the header spans several rows
+	FirstDataRow int   `json:"first_data_row"`
+	LastDataRow  int   `json:"last_data_row"`
+	TotalRows    []int `json:"total_rows,omitempty"` // Totals and subtotals rows
+	BlankRows    []int `json:"blank_rows,omitempty"` // Blank rows between data rows
+	FooterRow    int   `json:"footer_row"`           // First row of the totals and signature block under the data
 }
 
 // CalculationRequest represents a calculation request
 type CalculationRequest struct {
-	FileID       uint     `json:"file_id" binding:"required"`
-	SheetName    string   `json:"sheet_name" binding:"required"`
-	MainColumn   string   `json:"main_column" binding:"required"`
-	TargetColumns []string `json:"target_columns" binding:"required"`
-	Operation    string   `json:"operation" binding:"required"` // sum, average, etc.
-	StartRow     *int     `json:"start_row,omitempty"`          // Optional: 0-based row index to start calculation from
+	FileID        uint       `json:"file_id" binding:"required"`
+	SheetName     string     `json:"sheet_name" binding:"required"`
+	MainColumn    string     `json:"main_column" binding:"required"`
+	TargetColumns []string   `json:"target_columns" binding:"required"`
+	Operation     string     `json:"operation" binding:"required"` // sum, average, etc.
+	StartRow      *int       `json:"start_row,omitempty"`          // Optional: 0-based row index to start from; omitted means the detected data table, 0 the first row
+	Filter        *RowFilter `json:"filter,omitempty"`             // Optional: only rows matching the filter are calculated
+
+	Arithmetic string                  `json:"arithmetic,omitempty" binding:"omitempty,oneof=float decimal"` // float (default) or decimal
+	Rounding   map[string]RoundingRule `json:"rounding,omitempty" binding:"omitempty,dive"`                  // Column -> rounding of its results
+}
+
+// Arithmetic modes. Decimal mode calculates exactly, so sums of money do not pick up the
+// binary rounding noise of float64 such as 12345678.999999998.
+const (
+	ArithmeticFloat   = "float"
+	ArithmeticDecimal = "decimal"
+)
+
+// RoundingRule rounds the results of a column. Precision is the number of decimal places;
+// negative values round to tens, hundreds and so on, e.g. -3 rounds to 1,000 VND.
+type RoundingRule struct {
+	Mode      string `json:"mode" binding:"required,oneof=half_up half_even down up floor ceiling"`
+	Precision int    `json:"precision" binding:"min=-15,max=15"`
+}
+
+// RowFilter selects rows by their cell values. It is either a single condition on Column or
+// a group of conditions in All (AND) or Any (OR), which can be nested.
+// Operators: eq, ne, gt, gte, lt, lte, contains, not_contains, starts_with, blank, not_blank,
+// in and not_in. Text comparisons ignore case.
+type RowFilter struct {
+	Column   string        `json:"column,omitempty"`
+	Operator string        `json:"operator,omitempty"`
+	Value    interface{}   `json:"value,omitempty"`
+	Values   []interface{} `json:"values,omitempty"` // For in and not_in
+	All      []RowFilter   `json:"all,omitempty"`
+	Any      []RowFilter   `json:"any,omitempty"`
 }
 
 // RowCalculationRequest represents a row-wise calculation request
 type RowCalculationRequest struct {
-	FileID        uint     `json:"file_id" binding:"required"`
-	SheetName     string   `json:"sheet_name" binding:"required"`
-	SourceColumns []string `json:"source_columns" binding:"required"` // Columns to calculate from (e.g., I, K, M)
-	TargetColumn  string   `json:"target_column" binding:"required"`  // Column to write result to (e.g., L)
-	Operation     string   `json:"operation" binding:"required"`      // add, subtract, multiply, divide
-	StartRow      int      `json:"start_row"`                         // Row to start calculation from (e.g., 11)
-	EndRow        *int     `json:"end_row,omitempty"`                 // Row to end calculation (optional, auto-detect if nil)
+	FileID        uint       `json:"file_id" binding:"required"`
+	SheetName     string     `json:"sheet_name" binding:"required"`
+	SourceColumns []string   `json:"source_columns" binding:"required_unless=Operation formula"` // Columns to calculate from (e.g., I, K, M)
+	TargetColumn  string     `json:"target_column" binding:"required"`                           // Column to write result to (e.g., L)
+	Operation     string     `json:"operation" binding:"required"`                               // add, subtract, multiply, divide, copy or formula
+	StartRow      *int       `json:"start_row,omitempty"`                                        // 0-based row index to start from; omitted means the detected data table, 0 the first row
+	EndRow        *int       `json:"end_row,omitempty"`                                          // Row to end calculation (optional; end of the detected table, or of the sheet when start_row is given)
+	Filter        *RowFilter `json:"filter,omitempty"`                                           // Only rows matching the filter are calculated (optional)
+
+	OnDivideByZero string `json:"on_divide_by_zero,omitempty" binding:"omitempty,oneof=error skip_row blank zero skip_divisor"` // Policy for a division by zero: error, skip_row, blank, zero or skip_divisor (default)
+	OnInvalidValue string `json:"on_invalid_value,omitempty" binding:"omitempty,oneof=error skip_row blank zero"`               // Policy for source cells that are not numbers: error, skip_row, blank or zero (default)
+
+	Arithmetic string                  `json:"arithmetic,omitempty" binding:"omitempty,oneof=float decimal"` // See CalculationRequest
+	Rounding   map[string]RoundingRule `json:"rounding,omitempty" binding:"omitempty,dive"`                  // Target column -> rounding of its results
+
+	Formula  string `json:"formula,omitempty" binding:"required_if=Operation formula"` // e.g. "PIT(AI - AJ - AL)", for the formula operation
+	TaxTable string `json:"tax_table,omitempty"`                                       // Tax table version for PIT functions, default the one in effect
+}
+
+// Value policies for a division by zero or a source cell that is not a number
+const (
+	ValuePolicyError       = "error"        // Fail the calculation
+	ValuePolicySkipRow     = "skip_row"     // Leave the row out of the results
+	ValuePolicyBlank       = "blank"        // Keep the row with an empty result
+	ValuePolicyZero        = "zero"         // Use 0 for the cell or the quotient
+	ValuePolicySkipDivisor = "skip_divisor" // Leave the zero divisor out, so 10 / 0 stays 10; divisions only
+)
+
+// Row warning issues
+const (
+	IssueDivideByZero = "divide_by_zero"
+	IssueInvalidValue = "invalid_value"
+)
+
+// RowWarning reports a row whose result was decided by a value policy
+type RowWarning struct {
+	Step      int    `json:"step,omitempty"`   // Recipe step, 1-based, for recipe runs
+	RowNumber int    `json:"row_number"`       // 1-based
+	Column    string `json:"column,omitempty"` // Source column holding the value
+	Value     string `json:"value,omitempty"`  // Cell text, e.g. "#N/A"
+	Issue     string `json:"issue"`            // divide_by_zero or invalid_value
+	Action    string `json:"action"`           // Policy applied
 }
 
 // RowCalculationResult represents the result of a row-wise calculation
@@ -76,28 +411,255 @@ type RowCalculationResult struct {
 	EndRow        int                      `json:"end_row"`
 	Results       []map[string]interface{} `json:"results"` // Each result contains row number and calculated value
 	TotalRows     int                      `json:"total_rows"`
-	Formula       string                   `json:"formula"` // e.g., "I + K + M"
+	Formula       string                   `json:"formula"`                  // e.g., "I + K + M"
+	CalculationID uint                     `json:"calculation_id,omitempty"` // ID of the stored result
+	FilteredRows  int                      `json:"filtered_rows,omitempty"`  // Rows in range left out by the filter
+	Warnings      []RowWarning             `json:"warnings,omitempty"`       // Rows affected by a division by zero or an invalid value
 }
 
 // CalculationResult represents the result of a calculation
 type CalculationResult struct {
-	MainColumn    string                 `json:"main_column"`
+	MainColumn    string                   `json:"main_column"`
 	Results       []map[string]interface{} `json:"results"`
-	Summary       map[string]float64     `json:"summary"`
+	Summary       map[string]float64       `json:"summary"`
+	CalculationID uint                     `json:"calculation_id,omitempty"` // ID of the stored result
+}
+
+// Calculation kinds
+const (
+	CalculationColumns = "columns" // CalculateColumns
+	CalculationColumn  = "column"  // CalculateColumn
+	CalculationRowWise = "rowwise" // CalculateRowWise
+)
+
+// Calculation is a stored calculation result together with the request that produced it
+type Calculation struct {
+	ID        uint            `json:"id" gorm:"primaryKey"`
+	Kind      string          `json:"kind" gorm:"not null;index"`
+	FileID    uint            `json:"file_id" gorm:"not null;index"`
+	SheetName string          `json:"sheet_name"`
+	Request   json.RawMessage `json:"request" gorm:"type:text"`
+	Result    json.RawMessage `json:"result,omitempty" gorm:"type:text"` // Left out of listings
+	RowCount  int             `json:"row_count"`
+	CreatedBy string          `json:"created_by"`
+	CreatedAt time.Time       `json:"created_at" gorm:"index"`
+}
+
+// Recipe step types
+const (
+	RecipeStepRowWise  = "rowwise"
+	RecipeStepFilter   = "filter"
+	RecipeStepGroupBy  = "group_by"
+	RecipeStepTemplate = "template"
+)
+
+// Recipe is a named, ordered list of steps that can be replayed on any uploaded file
+type Recipe struct {
+	ID          uint         `json:"id" gorm:"primaryKey"`
+	Name        string       `json:"name" gorm:"uniqueIndex;not null"`
+	Description string       `json:"description"`
+	SheetName   string       `json:"sheet_name"`        // Sheet to read (defaults to the first sheet)
+	StartRow    int          `json:"start_row"`         // First data row, 1-based (detected if 0)
+	EndRow      *int         `json:"end_row,omitempty"` // Last data row (detected if nil)
+	Steps       []RecipeStep `json:"steps" gorm:"serializer:json;type:text"`
+	CreatedBy   string       `json:"created_by"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+// RecipeStep is one operation of a recipe. Which fields apply depends on Type:
+//   - rowwise: SourceColumns, TargetColumn, Operation (add, subtract, multiply, divide, copy)
+//   - filter: Filter, or a single condition in Column, Operator and Value; rows that do not match are dropped
+//   - group_by: GroupBy and Aggregates; one row is kept per group
+//   - template: TemplateID and the placement fields; must be the last step
+type RecipeStep struct {
+	Type string `json:"type" binding:"required,oneof=rowwise filter group_by template"`
+
+	SourceColumns  []string `json:"source_columns,omitempty"`
+	TargetColumn   string   `json:"target_column,omitempty"`
+	Operation      string   `json:"operation,omitempty"`
+	OnDivideByZero string   `json:"on_divide_by_zero,omitempty" binding:"omitempty,oneof=error skip_row blank zero skip_divisor"` // See RowCalculationRequest
+	OnInvalidValue string   `json:"on_invalid_value,omitempty" binding:"omitempty,oneof=error skip_row blank zero"`
+
+	Arithmetic string                  `json:"arithmetic,omitempty" binding:"omitempty,oneof=float decimal"` // rowwise and group_by steps
+	Rounding   map[string]RoundingRule `json:"rounding,omitempty" binding:"omitempty,dive"`                  // Target or aggregate column -> rounding
+
+	Column   string      `json:"column,omitempty"`
+	Operator string      `json:"operator,omitempty"` // See RowFilter
+	Value    interface{} `json:"value,omitempty"`
+	Filter   *RowFilter  `json:"filter,omitempty"` // Combined conditions; used instead of Column, Operator and Value
+
+	GroupBy    []string          `json:"group_by,omitempty"`
+	Aggregates []RecipeAggregate `json:"aggregates,omitempty" binding:"omitempty,dive"`
+
+	TemplateID     uint              `json:"template_id,omitempty"`
+	TemplateSheet  string            `json:"template_sheet,omitempty"`   // Defaults to the first sheet
+	Columns        map[string]string `json:"columns,omitempty"`          // Data column -> template column
+	StartRow       int               `json:"start_row,omitempty"`        // Write rows one after another from this row
+	RowOffset      int               `json:"row_offset,omitempty"`       // Otherwise write each row at its source row + offset
+	PrototypeRow   int               `json:"prototype_row,omitempty"`    // Template row cloned when rows exceed the pre-formatted ones
+	TemplateEndRow int               `json:"template_end_row,omitempty"` // Last pre-formatted row (auto-detected if 0)
+}
+
+// RecipeAggregate aggregates a column within each group of a group_by step
+type RecipeAggregate struct {
+	Column       string `json:"column" binding:"required"`
+	Operation    string `json:"operation" binding:"required,oneof=sum avg count min max"`
+	TargetColumn string `json:"target_column,omitempty"` // Column the result goes to (defaults to Column)
+}
+
+// RecipeRequest creates or replaces a recipe
+type RecipeRequest struct {
+	Name        string       `json:"name" binding:"required"`
+	Description string       `json:"description"`
+	SheetName   string       `json:"sheet_name"`
+	StartRow    int          `json:"start_row" binding:"min=0"`
+	EndRow      *int         `json:"end_row,omitempty"`
+	Steps       []RecipeStep `json:"steps" binding:"required,min=1,dive"`
+}
+
+// RecipeRunRequest applies a recipe to an uploaded file. Sheet and rows default to the
+// recipe's own.
+type RecipeRunRequest struct {
+	FileID    uint   `json:"file_id" binding:"required"`
+	SheetName string `json:"sheet_name,omitempty"`
+	StartRow  int    `json:"start_row,omitempty" binding:"min=0"`
+	EndRow    *int   `json:"end_row,omitempty"`
+}
+
+// TaxTable is a versioned set of Vietnamese personal income tax and compulsory insurance
+// rates used by the PIT formula functions. Amounts are VND per month.
+type TaxTable struct {
+	ID                 uint           `json:"id" gorm:"primaryKey"`
+	Version            string         `json:"version" gorm:"uniqueIndex;not null"` // e.g. "2024-07"
+	EffectiveFrom      time.Time      `json:"effective_from"`
+	Description        string         `json:"description"`
+	Brackets           []TaxBracket   `json:"brackets" gorm:"serializer:json;type:text"`
+	SelfDeduction      float64        `json:"self_deduction"`      // Giảm trừ bản thân
+	DependantDeduction float64        `json:"dependant_deduction"` // Giảm trừ mỗi người phụ thuộc
+	Insurance          InsuranceRates `json:"insurance" gorm:"serializer:json;type:text"`
+	CreatedBy          string         `json:"created_by"`
+	CreatedAt          time.Time      `json:"created_at"`
+	UpdatedAt          time.Time      `json:"updated_at"`
+}
+
+// TaxBracket is one step of the progressive tax on monthly taxable income
+type TaxBracket struct {
+	UpTo float64 `json:"up_to"` // Upper bound of the bracket, 0 for the top bracket
+	Rate float64 `json:"rate"`  // e.g. 0.05 for 5%
+}
+
+// InsuranceRates are the compulsory insurance contributions and the salary they are capped at
+type InsuranceRates struct {
+	BaseSalary           float64        `json:"base_salary"`            // Lương cơ sở, caps social and health insurance
+	RegionalMinimumWages []float64      `json:"regional_minimum_wages"` // Regions I to IV, cap unemployment insurance
+	CapMultiple          float64        `json:"cap_multiple"`           // The caps are this many times the base or minimum wage
+	Employee             InsuranceShare `json:"employee"`
+	Employer             InsuranceShare `json:"employer"`
+}
+
+// InsuranceShare are the contribution rates paid by the employee or the employer
+type InsuranceShare struct {
+	Social       float64 `json:"social"`       // BHXH
+	Health       float64 `json:"health"`       // BHYT
+	Unemployment float64 `json:"unemployment"` // BHTN
+}
+
+// TaxTableRequest creates or replaces a tax table
+type TaxTableRequest struct {
+	Version            string         `json:"version" binding:"required"`
+	EffectiveFrom      time.Time      `json:"effective_from" binding:"required"`
+	Description        string         `json:"description"`
+	Brackets           []TaxBracket   `json:"brackets" binding:"required,min=1"`
+	SelfDeduction      float64        `json:"self_deduction" binding:"min=0"`
+	DependantDeduction float64        `json:"dependant_deduction" binding:"min=0"`
+	Insurance          InsuranceRates `json:"insurance"`
 }
 
 // ExportRequest represents an export request
 type ExportRequest struct {
-	FileID       uint                   `json:"file_id" binding:"required"`
-	SheetName    string                 `json:"sheet_name" binding:"required"`
-	Data         []map[string]interface{} `json:"data" binding:"required"`
-	TemplateName string                 `json:"template_name,omitempty"`
+	FileID        uint                     `json:"file_id" binding:"required"`
+	SheetName     string                   `json:"sheet_name" binding:"required"`
+	Data          []map[string]interface{} `json:"data" binding:"required"`
+	TemplateName  string                   `json:"template_name,omitempty"`
+	Columns       []ExportColumn           `json:"columns,omitempty" binding:"omitempty,dive"` // Column order and formatting (defaults to the data keys in sorted order)
+	FreezeColumns int                      `json:"freeze_columns,omitempty"`                   // Leading columns kept visible when scrolling right
+}
+
+// WorkbookExportRequest represents an export of several sheets into one workbook,
+// e.g. raw data, calculation results, a group-by summary and validation errors
+type WorkbookExportRequest struct {
+	Sheets []ExportSheet `json:"sheets" binding:"required,min=1,dive"`
+}
+
+// ExportSheet is one sheet of a workbook export with its own column spec
+type ExportSheet struct {
+	Name          string                   `json:"name" binding:"required"`
+	Data          []map[string]interface{} `json:"data"`
+	Columns       []ExportColumn           `json:"columns,omitempty" binding:"omitempty,dive"` // Column order and formatting (defaults to the data keys in sorted order)
+	FreezeColumns int                      `json:"freeze_columns,omitempty"`                   // Leading columns kept visible when scrolling right
+}
+
+// ExportColumn describes one column of a generic export
+type ExportColumn struct {
+	Key          string  `json:"key" binding:"required"`  // Key in each data row
+	Header       string  `json:"header,omitempty"`        // Header text (defaults to the key)
+	Type         string  `json:"type,omitempty"`          // text, number, integer, currency, percent or date
+	NumberFormat string  `json:"number_format,omitempty"` // Custom Excel number format, overrides the type's format
+	Width        float64 `json:"width,omitempty"`         // Column width in characters (auto-fitted if 0)
 }
 
 // TemplateExportRequest represents a template export request for row calculations
 type TemplateExportRequest struct {
-	CalculationResult RowCalculationResult `json:"calculation_result" binding:"required"`
-	TemplatePath      string               `json:"template_path,omitempty"`
+	CalculationID     uint                 `json:"calculation_id,omitempty"`     // Stored row-wise calculation to export
+	CalculationResult RowCalculationResult `json:"calculation_result,omitempty"` // Used when no calculation_id is given
+	FileID            uint                 `json:"file_id,omitempty"`            // Data file a posted calculation_result was calculated from
+	TemplateID        uint                 `json:"template_id,omitempty"`        // Uploaded template to write into
+	TemplatePath      string               `json:"template_path,omitempty"`      // Legacy: template path on the server
+	TemplateSheet     string               `json:"template_sheet,omitempty"`     // Target sheet (defaults to the first sheet)
+	RowOffset         int                  `json:"row_offset"`                   // Added to each source row number to get the template row
+	PrototypeRow      int                  `json:"prototype_row,omitempty"`      // Template row cloned when results exceed the pre-formatted rows
+	TemplateEndRow    int                  `json:"template_end_row,omitempty"`   // Last pre-formatted row (auto-detected if 0)
+}
+
+// PlaceholderReportRequest represents a request to fill a placeholder-based report template.
+// Templates may contain {{province.name}}, {{unit.code}}, {{sum:L}}, {{today}} and a
+// {{#rows}}...{{/rows}} block repeated for each source row
+type PlaceholderReportRequest struct {
+	SourceFileID  uint   `json:"source_file_id" binding:"required"`
+	SourceSheet   string `json:"source_sheet" binding:"required"`
+	TemplateID    uint   `json:"template_id" binding:"required"`
+	TemplateSheet string `json:"template_sheet,omitempty"`     // Sheet to fill (all sheets if empty)
+	StartRow      int    `json:"start_row" binding:"required"` // First source data row (1-based)
+	EndRow        *int   `json:"end_row,omitempty"`            // Last source data row (defaults to the last row)
+	ProvinceID    *uint  `json:"province_id,omitempty"`        // Overrides the province stored with the upload
+	UnitID        *uint  `json:"unit_id,omitempty"`            // Overrides the unit stored with the upload
+}
+
+// PDFOptions controls how a sheet is rendered to PDF. Empty fields fall back to the
+// sheet's own page setup and print titles, then to A4 with automatic orientation
+type PDFOptions struct {
+	SheetName   string  `form:"sheet_name" json:"sheet_name,omitempty"`   // Sheet to render (defaults to the first sheet)
+	PageSize    string  `form:"page_size" json:"page_size,omitempty"`     // A3, A4, A5, Letter or Legal
+	Orientation string  `form:"orientation" json:"orientation,omitempty"` // portrait or landscape
+	HeaderRows  int     `form:"header_rows" json:"header_rows,omitempty"` // Top rows repeated on every page
+	FontSize    float64 `form:"font_size" json:"font_size,omitempty"`     // Base font size in points (default 9)
+	Gridlines   bool    `form:"gridlines" json:"gridlines,omitempty"`     // Draw borders around every cell
+}
+
+// ConsolidationRequest represents a consolidation of the same sheet/range from many unit
+// submissions into one workbook. Files are selected by FileIDs, or all files of ProvinceID
+// (optionally for one PeriodID)
+type ConsolidationRequest struct {
+	FileIDs    []uint   `json:"file_ids,omitempty"`
+	ProvinceID *uint    `json:"province_id,omitempty"`
+	PeriodID   *uint    `json:"period_id,omitempty"` // Narrows province files to one reporting period
+	SheetName  string   `json:"sheet_name" binding:"required"`
+	StartRow   int      `json:"start_row" binding:"required,min=1"` // First data row (1-based)
+	EndRow     *int     `json:"end_row,omitempty"`                  // Last data row (defaults to the last used row of each file)
+	HeaderRow  int      `json:"header_row,omitempty"`               // Row holding column titles (column letters are used if 0)
+	Columns    []string `json:"columns,omitempty"`                  // Columns to read (defaults to all used columns)
+	SumColumns []string `json:"sum_columns,omitempty"`              // Columns subtotaled per unit (defaults to the numeric columns)
 }
 
 // MergeDataRequest represents data merge request
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestHashPassword(t *testing.T) {
+	s := NewAuthService([]byte("secret"), time.Hour)
+	if _, err := s.HashPassword("short"); err == nil {
+		t.Error("HashPassword accepted a password shorter than the minimum")
+	}
+
+	hash, err := s.HashPassword("mật khẩu an toàn")
+	if err != nil {
+		t.Fatalf("HashPassword returned %v", err)
+	}
+	if !s.CheckPassword(hash, "mật khẩu an toàn") {
+		t.Error("CheckPassword rejected the right password")
+	}
+	if s.CheckPassword(hash, "mật khẩu sai") {
+		t.Error("CheckPassword accepted a wrong password")
+	}
+}
+
+func TestParseToken(t *testing.T) {
+	s := NewAuthService([]byte("secret"), time.Hour)
+	token, expiresAt, err := s.IssueToken(42, "an.nguyen")
+	if err != nil {
+		t.Fatalf("IssueToken returned %v", err)
+	}
+	if d := time.Until(expiresAt); d < 59*time.Minute || d > time.Hour {
+		t.Errorf("token expires in %v, want an hour", d)
+	}
+
+	userID, claims, err := s.ParseToken(token)
+	if err != nil {
+		t.Fatalf("ParseToken returned %v", err)
+	}
+	if userID != 42 || claims.Username != "an.nguyen" {
+		t.Errorf("ParseToken = %d, %s, want 42, an.nguyen", userID, claims.Username)
+	}
+
+	expired, _, _ := NewAuthService([]byte("secret"), -time.Minute).IssueToken(42, "an.nguyen")
+	otherSecret, _, _ := NewAuthService([]byte("other"), time.Hour).IssueToken(42, "an.nguyen")
+	unsigned, _ := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.RegisteredClaims{
+		Subject:   "42",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	noExpiry, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{Subject: "42"}).SignedString([]byte("secret"))
+	noSubject, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}).SignedString([]byte("secret"))
+
+	for name, bad := range map[string]string{
+		"expired":      expired,
+		"other secret": otherSecret,
+		"unsigned":     unsigned,
+		"no expiry":    noExpiry,
+		"no subject":   noSubject,
+		"garbage":      "not.a.token",
+	} {
+		if _, _, err := s.ParseToken(bad); err == nil {
+			t.Errorf("ParseToken accepted a token with %s", name)
+		}
+	}
+}
+
+func TestGenerateAPIKey(t *testing.T) {
+	s := NewAuthService([]byte("secret"), time.Hour)
+	key, hash, err := s.GenerateAPIKey()
+	if err != nil {
+		t.Fatalf("GenerateAPIKey returned %v", err)
+	}
+	if !strings.HasPrefix(key, apiKeyPrefix) || len(key) != len(apiKeyPrefix)+64 {
+		t.Errorf("key %q does not look like %s followed by 32 random bytes", key, apiKeyPrefix)
+	}
+	if hash != s.HashAPIKey(key) || strings.Contains(hash, key) {
+		t.Error("the stored hash does not match the key")
+	}
+	if other, _, _ := s.GenerateAPIKey(); other == key {
+		t.Error("GenerateAPIKey returned the same key twice")
+	}
+}
@@ -0,0 +1,327 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+	"excel-processor/internal/models"
+)
+
+// ConsolidationSource is one submitted file together with the unit it belongs to
+type ConsolidationSource struct {
+	File     models.ExcelFile
+	UnitCode string
+	UnitName string
+}
+
+// consolidationSheet is the data read from one source, keyed by column letter
+type consolidationSheet struct {
+	Source  ConsolidationSource
+	Rows    []map[string]interface{}
+	RowNums []int
+}
+
+// ConsolidateFiles reads the same sheet and row range from every source and writes one
+// workbook with the appended rows tagged by unit, per-unit subtotals and a list of sources
+func (s *ExcelService) ConsolidateFiles(sources []ConsolidationSource, req models.ConsolidationRequest) (string, error) {
+	if len(sources) == 0 {
+		return "", fmt.Errorf("no files to consolidate")
+	}
+
+	sheets := make([]consolidationSheet, 0, len(sources))
+	headers := make(map[string]string)
+	maxCol := 0
+	for _, source := range sources {
+		sheet, cols, err := s.readConsolidationSheet(source, req, headers)
+		if err != nil {
+			return "", fmt.Errorf("file %s (ID %d): %w", source.File.FileName, source.File.ID, err)
+		}
+		if cols > maxCol {
+			maxCol = cols
+		}
+		sheets = append(sheets, sheet)
+	}
+
+	columns := make([]string, 0)
+	for _, column := range req.Columns {
+		columns = append(columns, strings.ToUpper(strings.TrimSpace(column)))
+	}
+	if len(columns) == 0 {
+		for col := 1; col <= maxCol; col++ {
+			name, _ := excelize.ColumnNumberToName(col)
+			columns = append(columns, name)
+		}
+	}
+	if len(columns) == 0 {
+		return "", fmt.Errorf("no data found in rows %d and below of sheet %s", req.StartRow, req.SheetName)
+	}
+
+	sumColumns := make([]string, 0)
+	for _, column := range req.SumColumns {
+		sumColumns = append(sumColumns, strings.ToUpper(strings.TrimSpace(column)))
+	}
+	if len(sumColumns) == 0 {
+		sumColumns = numericColumns(sheets, columns)
+	}
+
+	f := excelize.NewFile()
+	defer f.Close()
+
+	if err := writeConsolidatedRows(f, sheets, columns, headers); err != nil {
+		return "", err
+	}
+	if err := writeUnitSubtotals(f, sheets, sumColumns, headers); err != nil {
+		return "", err
+	}
+	if err := writeConsolidationSources(f, sheets); err != nil {
+		return "", err
+	}
+	f.SetActiveSheet(0)
+
+	// Create exports directory if not exists
+	exportsDir := "exports"
+	if err := os.MkdirAll(exportsDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create exports directory: %w", err)
+	}
+
+	// Save file
+	outputPath := fmt.Sprintf("%s/consolidated_%d.xlsx", exportsDir, time.Now().UnixNano())
+	if err := f.SaveAs(outputPath); err != nil {
+		return "", fmt.Errorf("failed to save file: %w", err)
+	}
+
+	return outputPath, nil
+}
+
+// readConsolidationSheet reads the requested rows of one source. Column titles found in the
+// header row are added to headers unless another file already named the column. It also
+// returns the number of used columns in the range.
+func (s *ExcelService) readConsolidationSheet(source ConsolidationSource, req models.ConsolidationRequest, headers map[string]string) (consolidationSheet, int, error) {
+	sheet := consolidationSheet{Source: source}
+
+	f, err := s.openWorkbook(source.File.FilePath)
+	if err != nil {
+		return sheet, 0, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	if idx, _ := f.GetSheetIndex(req.SheetName); idx == -1 {
+		return sheet, 0, fmt.Errorf("sheet %s not found", req.SheetName)
+	}
+	rows, err := f.GetRows(req.SheetName)
+	if err != nil {
+		return sheet, 0, fmt.Errorf("failed to read sheet %s: %w", req.SheetName, err)
+	}
+
+	if req.HeaderRow > 0 && req.HeaderRow <= len(rows) {
+		for col, title := range rows[req.HeaderRow-1] {
+			name, _ := excelize.ColumnNumberToName(col + 1)
+			if _, exists := headers[name]; !exists && strings.TrimSpace(title) != "" {
+				headers[name] = strings.TrimSpace(title)
+			}
+		}
+	}
+
+	endRow := len(rows)
+	if req.EndRow != nil && *req.EndRow < endRow {
+		endRow = *req.EndRow
+	}
+
+	maxCol := 0
+	for rowNum := req.StartRow; rowNum <= endRow; rowNum++ {
+		values := make(map[string]interface{})
+		for col, display := range rows[rowNum-1] {
+			cell, _ := excelize.CoordinatesToCellName(col+1, rowNum)
+			text, number, isNumber := typedCellValue(f, req.SheetName, cell, display)
+			name, _ := excelize.ColumnNumberToName(col + 1)
+			switch {
+			case isNumber:
+				values[name] = number
+			case strings.TrimSpace(text) != "":
+				values[name] = text
+			default:
+				continue
+			}
+			if col+1 > maxCol {
+				maxCol = col + 1
+			}
+		}
+
+		if len(values) == 0 {
+			continue
+		}
+		sheet.Rows = append(sheet.Rows, values)
+		sheet.RowNums = append(sheet.RowNums, rowNum)
+	}
+
+	return sheet, maxCol, nil
+}
+
+// numericColumns returns the columns that hold at least one number and nothing but numbers
+func numericColumns(sheets []consolidationSheet, columns []string) []string {
+	numeric := make([]string, 0)
+	for _, column := range columns {
+		hasNumber, onlyNumbers := false, true
+		for _, sheet := range sheets {
+			for _, row := range sheet.Rows {
+				switch row[column].(type) {
+				case nil:
+				case float64:
+					hasNumber = true
+				default:
+					onlyNumbers = false
+				}
+			}
+		}
+		if hasNumber && onlyNumbers {
+			numeric = append(numeric, column)
+		}
+	}
+	return numeric
+}
+
+// consolidationHeader returns the column title from the header row, or the column letter
+func consolidationHeader(headers map[string]string, column string) string {
+	if title, ok := headers[column]; ok {
+		return title
+	}
+	return column
+}
+
+// writeConsolidatedRows writes all source rows, each prefixed with its unit and source row
+func writeConsolidatedRows(f *excelize.File, sheets []consolidationSheet, columns []string, headers map[string]string) error {
+	sheetName := "Consolidated"
+	f.SetSheetName("Sheet1", sheetName)
+
+	exportColumns := []models.ExportColumn{
+		{Key: "unit_code", Header: "Unit code", Type: "text"},
+		{Key: "unit_name", Header: "Unit"},
+		{Key: "source_row", Header: "Source row", Type: "integer"},
+	}
+	for _, column := range columns {
+		exportColumns = append(exportColumns, models.ExportColumn{Key: column, Header: consolidationHeader(headers, column)})
+	}
+
+	rows := make([][]interface{}, 0)
+	for _, sheet := range sheets {
+		for i, values := range sheet.Rows {
+			row := []interface{}{sheet.Source.UnitCode, sheet.Source.UnitName, sheet.RowNums[i]}
+			for _, column := range columns {
+				row = append(row, values[column])
+			}
+			rows = append(rows, row)
+		}
+	}
+
+	if err := writeStyledSheet(f, sheetName, exportColumns, rows, 2); err != nil {
+		return fmt.Errorf("failed to write consolidated rows: %w", err)
+	}
+	return nil
+}
+
+// writeUnitSubtotals writes one row per unit with the sums of sumColumns and a grand total.
+// Files of the same unit are added together.
+func writeUnitSubtotals(f *excelize.File, sheets []consolidationSheet, sumColumns []string, headers map[string]string) error {
+	sheetName := "Subtotals"
+	if _, err := f.NewSheet(sheetName); err != nil {
+		return fmt.Errorf("failed to create subtotals sheet: %w", err)
+	}
+
+	exportColumns := []models.ExportColumn{
+		{Key: "unit_code", Header: "Unit code", Type: "text"},
+		{Key: "unit_name", Header: "Unit"},
+		{Key: "files", Header: "Files", Type: "integer"},
+		{Key: "rows", Header: "Rows", Type: "integer"},
+	}
+	for _, column := range sumColumns {
+		exportColumns = append(exportColumns, models.ExportColumn{Key: column, Header: consolidationHeader(headers, column), Type: "number"})
+	}
+
+	rows := make([][]interface{}, 0)
+	unitRows := make(map[string][]interface{})
+	total := make([]interface{}, len(exportColumns))
+	total[1], total[2], total[3] = "Total", 0, 0
+	for _, sheet := range sheets {
+		row, ok := unitRows[sheet.Source.UnitCode]
+		if !ok {
+			row = make([]interface{}, len(exportColumns))
+			row[0], row[1], row[2], row[3] = sheet.Source.UnitCode, sheet.Source.UnitName, 0, 0
+			unitRows[sheet.Source.UnitCode] = row
+			rows = append(rows, row)
+		}
+		row[2] = row[2].(int) + 1
+		row[3] = row[3].(int) + len(sheet.Rows)
+		total[2] = total[2].(int) + 1
+		total[3] = total[3].(int) + len(sheet.Rows)
+
+		for i, column := range sumColumns {
+			sum := 0.0
+			for _, values := range sheet.Rows {
+				if number, ok := values[column].(float64); ok {
+					sum += number
+				}
+			}
+			current, _ := row[4+i].(float64)
+			row[4+i] = current + sum
+			grand, _ := total[4+i].(float64)
+			total[4+i] = grand + sum
+		}
+	}
+	rows = append(rows, total)
+
+	if err := writeStyledSheet(f, sheetName, exportColumns, rows, 2); err != nil {
+		return fmt.Errorf("failed to write subtotals: %w", err)
+	}
+
+	// Make the grand total stand out, keeping the number format of the sums
+	totalRow := len(rows) + 1
+	labelStyle, err := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		return fmt.Errorf("failed to create total style: %w", err)
+	}
+	if err := f.SetCellStyle(sheetName, fmt.Sprintf("A%d", totalRow), fmt.Sprintf("D%d", totalRow), labelStyle); err != nil {
+		return fmt.Errorf("failed to style total row: %w", err)
+	}
+	if len(sumColumns) > 0 {
+		numFmt := exportNumberFormats["number"]
+		sumStyle, err := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}, CustomNumFmt: &numFmt})
+		if err != nil {
+			return fmt.Errorf("failed to create total style: %w", err)
+		}
+		lastCell, _ := excelize.CoordinatesToCellName(len(exportColumns), totalRow)
+		if err := f.SetCellStyle(sheetName, fmt.Sprintf("E%d", totalRow), lastCell, sumStyle); err != nil {
+			return fmt.Errorf("failed to style total row: %w", err)
+		}
+	}
+	return nil
+}
+
+// writeConsolidationSources lists the consolidated files so the result can be traced back
+func writeConsolidationSources(f *excelize.File, sheets []consolidationSheet) error {
+	sheetName := "Sources"
+	if _, err := f.NewSheet(sheetName); err != nil {
+		return fmt.Errorf("failed to create sources sheet: %w", err)
+	}
+
+	exportColumns := []models.ExportColumn{
+		{Key: "file_id", Header: "File ID", Type: "integer"},
+		{Key: "file_name", Header: "File"},
+		{Key: "unit_code", Header: "Unit code", Type: "text"},
+		{Key: "unit_name", Header: "Unit"},
+		{Key: "rows", Header: "Rows", Type: "integer"},
+		{Key: "uploaded_at", Header: "Uploaded", Type: "date"},
+	}
+	rows := make([][]interface{}, 0, len(sheets))
+	for _, sheet := range sheets {
+		file := sheet.Source.File
+		rows = append(rows, []interface{}{file.ID, file.FileName, sheet.Source.UnitCode, sheet.Source.UnitName, len(sheet.Rows), file.CreatedAt})
+	}
+
+	if err := writeStyledSheet(f, sheetName, exportColumns, rows, 0); err != nil {
+		return fmt.Errorf("failed to write sources: %w", err)
+	}
+	return nil
+}
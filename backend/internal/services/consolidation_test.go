@@ -0,0 +1,100 @@
+package services
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+
+	"excel-processor/internal/models"
+)
+
+// writeConsolidationSource saves a unit file with a title row, a header row and data rows
+func writeConsolidationSource(t *testing.T, id uint, rows [][]interface{}) models.ExcelFile {
+	t.Helper()
+	f := excelize.NewFile()
+	defer f.Close()
+	f.SetSheetName("Sheet1", "Data")
+	f.SetCellValue("Data", "A1", "Báo cáo thu nhập")
+	f.SetSheetRow("Data", "A2", &[]interface{}{"Họ tên", "Thu nhập", "Ghi chú"})
+	for i, row := range rows {
+		f.SetSheetRow("Data", fmt.Sprintf("A%d", i+3), &row)
+	}
+	path := fmt.Sprintf("unit_%d.xlsx", id)
+	if err := f.SaveAs(path); err != nil {
+		t.Fatalf("SaveAs returned %v", err)
+	}
+	return models.ExcelFile{ID: id, FileName: path, FilePath: path}
+}
+
+func TestConsolidateFiles(t *testing.T) {
+	t.Chdir(t.TempDir())
+	sources := []ConsolidationSource{
+		{File: writeConsolidationSource(t, 1, [][]interface{}{{"An", 1000, "đủ"}, {"Bình", 2500}}), UnitCode: "HN-01", UnitName: "Ba Đình"},
+		{File: writeConsolidationSource(t, 2, [][]interface{}{{"Chi", 500}}), UnitCode: "HN-02", UnitName: "Hoàn Kiếm"},
+		// A second file of the same unit with a blank row inside the range
+		{File: writeConsolidationSource(t, 3, [][]interface{}{{"Dũng", 300}, {}, {"Hà", 200}}), UnitCode: "HN-01", UnitName: "Ba Đình"},
+	}
+	req := models.ConsolidationRequest{SheetName: "Data", StartRow: 3, HeaderRow: 2}
+
+	outputPath, err := NewExcelService().ConsolidateFiles(sources, req)
+	if err != nil {
+		t.Fatalf("ConsolidateFiles returned %v", err)
+	}
+	f, err := excelize.OpenFile(outputPath)
+	if err != nil {
+		t.Fatalf("OpenFile returned %v", err)
+	}
+	defer f.Close()
+
+	want := map[string][][]string{
+		"Consolidated": {
+			{"Unit code", "Unit", "Source row", "Họ tên", "Thu nhập", "Ghi chú"},
+			{"HN-01", "Ba Đình", "3", "An", "1000", "đủ"},
+			{"HN-01", "Ba Đình", "4", "Bình", "2500"},
+			{"HN-02", "Hoàn Kiếm", "3", "Chi", "500"},
+			{"HN-01", "Ba Đình", "3", "Dũng", "300"},
+			{"HN-01", "Ba Đình", "5", "Hà", "200"},
+		},
+		// Only the all-numeric column is subtotaled and both HN-01 files are added together
+		"Subtotals": {
+			{"Unit code", "Unit", "Files", "Rows", "Thu nhập"},
+			{"HN-01", "Ba Đình", "2", "4", "4000"},
+			{"HN-02", "Hoàn Kiếm", "1", "1", "500"},
+			{"", "Total", "3", "5", "4500"},
+		},
+	}
+	for sheet, wantRows := range want {
+		rows, err := f.GetRows(sheet, excelize.Options{RawCellValue: true})
+		if err != nil {
+			t.Fatalf("GetRows(%s) returned %v", sheet, err)
+		}
+		if len(rows) != len(wantRows) {
+			t.Errorf("%s has %d rows, want %d: %q", sheet, len(rows), len(wantRows), rows)
+			continue
+		}
+		for i := range wantRows {
+			if !slices.Equal(rows[i], wantRows[i]) {
+				t.Errorf("%s row %d = %q, want %q", sheet, i+1, rows[i], wantRows[i])
+			}
+		}
+	}
+	if rows, _ := f.GetRows("Sources"); len(rows) != len(sources)+1 {
+		t.Errorf("Sources has %d rows, want a header and one row per file", len(rows))
+	}
+}
+
+func TestConsolidateFilesErrors(t *testing.T) {
+	t.Chdir(t.TempDir())
+	source := ConsolidationSource{File: writeConsolidationSource(t, 7, [][]interface{}{{"An", 1000}}), UnitCode: "HN-01"}
+
+	if _, err := NewExcelService().ConsolidateFiles(nil, models.ConsolidationRequest{SheetName: "Data", StartRow: 3}); err == nil {
+		t.Error("ConsolidateFiles accepted no sources")
+	}
+	_, err := NewExcelService().ConsolidateFiles([]ConsolidationSource{source}, models.ConsolidationRequest{SheetName: "Missing", StartRow: 3})
+	if err == nil || !strings.Contains(err.Error(), "ID 7") {
+		t.Errorf("ConsolidateFiles with a missing sheet returned %v, want an error naming file 7", err)
+	}
+}
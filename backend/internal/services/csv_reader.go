@@ -0,0 +1,147 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/xuri/excelize/v2"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/unicode/norm"
+)
+
+// csvSheetName is the name of the single virtual sheet exposed for CSV/TSV files
+const csvSheetName = "Sheet1"
+
+// csvDelimiters are the candidate delimiters tried when sniffing a CSV file
+var csvDelimiters = []rune{',', ';', '\t', '|'}
+
+// isDelimitedFile reports whether a file is read as CSV/TSV rather than a workbook
+func isDelimitedFile(filePath string) bool {
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".csv", ".tsv":
+		return true
+	}
+	return false
+}
+
+// openDelimitedFile reads a CSV/TSV file into an in-memory workbook with a single sheet
+func (s *ExcelService) openDelimitedFile(filePath string) (*excelize.File, error) {
+	raw, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	text, err := decodeDelimitedText(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	delimiter := '\t'
+	if strings.ToLower(filepath.Ext(filePath)) != ".tsv" {
+		delimiter = detectDelimiter(text)
+	}
+
+	reader := csv.NewReader(strings.NewReader(text))
+	reader.Comma = delimiter
+	reader.LazyQuotes = true
+	reader.FieldsPerRecord = -1
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse delimited file: %w", err)
+	}
+
+	f := excelize.NewFile()
+	for i, record := range records {
+		row := make([]interface{}, len(record))
+		for j, field := range record {
+			row[j] = field
+		}
+		cell, _ := excelize.CoordinatesToCellName(1, i+1)
+		if err := f.SetSheetRow(csvSheetName, cell, &row); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to load row %d: %w", i+1, err)
+		}
+	}
+
+	return f, nil
+}
+
+// decodeDelimitedText converts the file content to NFC UTF-8. UTF-16 and UTF-8 are recognised
+// by BOM or validity; anything else is assumed to be Windows-1258 (Vietnamese ANSI exports).
+func decodeDelimitedText(raw []byte) (string, error) {
+	switch {
+	case bytes.HasPrefix(raw, []byte{0xFF, 0xFE}), bytes.HasPrefix(raw, []byte{0xFE, 0xFF}):
+		decoded, err := unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM).NewDecoder().Bytes(raw)
+		if err != nil {
+			return "", fmt.Errorf("failed to decode UTF-16 text: %w", err)
+		}
+		raw = decoded
+	case bytes.HasPrefix(raw, []byte{0xEF, 0xBB, 0xBF}):
+		raw = raw[3:]
+	case !utf8.Valid(raw):
+		decoded, err := charmap.Windows1258.NewDecoder().Bytes(raw)
+		if err != nil {
+			return "", fmt.Errorf("failed to decode Windows-1258 text: %w", err)
+		}
+		raw = decoded
+	}
+
+	// Windows-1258 and some HR exports use combining tone marks, compose them
+	return norm.NFC.String(string(raw)), nil
+}
+
+// detectDelimiter picks the candidate delimiter that splits the first lines most consistently
+func detectDelimiter(text string) rune {
+	lines := strings.SplitN(text, "\n", 11)
+	if len(lines) > 10 {
+		lines = lines[:10]
+	}
+
+	best, bestScore := ',', 0
+	for _, delimiter := range csvDelimiters {
+		first := -1
+		consistent := true
+		for _, line := range lines {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			count := countUnquoted(line, delimiter)
+			if first == -1 {
+				first = count
+			} else if count != first {
+				consistent = false
+			}
+		}
+
+		score := first
+		if !consistent {
+			score /= 2
+		}
+		if score > bestScore {
+			best, bestScore = delimiter, score
+		}
+	}
+	return best
+}
+
+// countUnquoted counts occurrences of delimiter outside double-quoted fields
+func countUnquoted(line string, delimiter rune) int {
+	count := 0
+	inQuotes := false
+	for _, r := range line {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+		case r == delimiter && !inQuotes:
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"golang.org/x/text/encoding/unicode"
+)
+
+func TestDetectDelimiter(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want rune
+	}{
+		{"comma", "name,amount\nAn,100\nBình,200\n", ','},
+		{"semicolon with decimal commas", "name;amount\nAn;1,5\nBình;2,25\n", ';'},
+		{"tab", "name\tamount\tnote\nAn\t100\t\n", '\t'},
+		{"pipe", "name|amount\nAn|100\n", '|'},
+		{"quoted delimiters are ignored", "\"Hà Nội, VN\";1;2\n\"Huế, VN\";3;4\n", ';'},
+		{"single column falls back to comma", "name\nAn\nBình\n", ','},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectDelimiter(tt.text); got != tt.want {
+				t.Errorf("detectDelimiter(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeDelimitedText(t *testing.T) {
+	utf16, err := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder().Bytes([]byte("Hà Nội"))
+	if err != nil {
+		t.Fatalf("encoding UTF-16 returned %v", err)
+	}
+
+	tests := []struct {
+		name string
+		raw  []byte
+	}{
+		{"UTF-8", []byte("Hà Nội")},
+		{"UTF-8 with BOM", append([]byte{0xEF, 0xBB, 0xBF}, "Hà Nội"...)},
+		{"UTF-8 with combining marks", []byte("Ha\u0300 No\u0302\u0323i")},
+		// Windows-1258 writes ộ as ô followed by a combining dot below (0xF2)
+		{"Windows-1258", []byte("H\xE0 N\xF4\xF2i")},
+		{"UTF-16 with BOM", utf16},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeDelimitedText(tt.raw)
+			if err != nil {
+				t.Fatalf("decodeDelimitedText returned %v", err)
+			}
+			if got != "Hà Nội" {
+				t.Errorf("decodeDelimitedText = %q, want %q", got, "Hà Nội")
+			}
+		})
+	}
+}
+
+func TestOpenDelimitedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "staff.csv")
+	content := "\xEF\xBB\xBFname;address;amount\nAn;\"12 Tràng Tiền;\nHoàn Kiếm\";1,5\nBình;\"Huế\";2\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("WriteFile returned %v", err)
+	}
+
+	f, err := NewExcelService().openDelimitedFile(path)
+	if err != nil {
+		t.Fatalf("openDelimitedFile returned %v", err)
+	}
+	defer f.Close()
+
+	if sheets := f.GetSheetList(); !slices.Equal(sheets, []string{csvSheetName}) {
+		t.Errorf("sheets = %v, want [%s]", sheets, csvSheetName)
+	}
+	rows, err := f.GetRows(csvSheetName)
+	if err != nil {
+		t.Fatalf("GetRows returned %v", err)
+	}
+	want := [][]string{
+		{"name", "address", "amount"},
+		{"An", "12 Tràng Tiền;\nHoàn Kiếm", "1,5"},
+		{"Bình", "Huế", "2"},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("got %d rows, want %d: %q", len(rows), len(want), rows)
+	}
+	for i := range want {
+		if !slices.Equal(rows[i], want[i]) {
+			t.Errorf("row %d = %q, want %q", i+1, rows[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,359 @@
+package services
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+
+	"excel-processor/internal/models"
+)
+
+const (
+	// maxHeaderSearchRows is how far down the sheet a header row is looked for; title and
+	// address blocks above the table are rarely longer
+	maxHeaderSearchRows = 30
+	// maxBlankGap is the number of consecutive blank rows that ends the table
+	maxBlankGap = 3
+)
+
+// columnNumberPattern matches the cells of a column numbering row, e.g. "1", "(2)" or
+// "21=11+12", which also shows how the column is calculated
+var columnNumberPattern = regexp.MustCompile(`^\(?\d{1,3}\)?(\s*=.*)?$`)
+
+// totalRowKeywords start the first text cell of totals rows
+var totalRowKeywords = []string{"tổng cộng", "tổng số", "cộng", "total", "grand total"}
+
+// footerRowKeywords appear in the signature block under a table
+var footerRowKeywords = []string{"người lập", "kế toán", "thủ trưởng", "giám đốc", "xác nhận", "ký, ghi rõ", "ký tên", "prepared by", "approved by"}
+
+// footerDatePattern matches the place and date line of a signature block, e.g.
+// "Hà Nội, ngày 10 tháng 5 năm 2024"
+var footerDatePattern = regexp.MustCompile(`ngày\s*[\d.…_ ]*\s*tháng`)
+
+// DetectRegion opens a file and detects the data table of one of its sheets
+func (s *ExcelService) DetectRegion(filePath, sheetName string) (*models.DataRegion, error) {
+	f, err := s.openWorkbook(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	rows, err := f.GetRows(sheetName)
+	if err != nil {
+		return nil, err
+	}
+	return detectDataRegion(f, sheetName, rows), nil
+}
+
+// detectDataRegion finds the data table of a sheet: the header (the first row, below any
+// title block, whose cells are mostly filled with text, extended down over the rows its
+// merged cells span), the data rows under it and the totals and signature rows that follow.
+// Blank rows inside the table are reported as gaps. It returns nil for a sheet without data.
+func detectDataRegion(f *excelize.File, sheetName string, rows [][]string) *models.DataRegion {
+	merges := mergedRanges(f, sheetName)
+	width := 0
+	filled := make([]int, min(len(rows), maxHeaderSearchRows))
+	for i := range filled {
+		filled[i] = filledCells(rows[i]) + mergedCells(merges, i+1)
+		width = max(width, filled[i])
+	}
+	if width == 0 {
+		return nil
+	}
+
+	region := &models.DataRegion{}
+	first := 0
+	for i := range filled {
+		// A title merged across the table has one value and is not a header
+		if filledCells(rows[i]) >= 2 && filled[i]*2 >= width && numericCells(rows[i])*2 < filled[i] {
+			region.HeaderRow = i + 1
+			region.HeaderEndRow = headerEnd(merges, i+1)
+			first = min(region.HeaderEndRow, len(rows))
+			break
+		}
+	}
+	// Column numbering rows such as (1) (2) (3) belong to the header
+	for first < len(rows) && isColumnNumberRow(rows[first]) {
+		first++
+	}
+	for first < len(rows) && filledCells(rows[first]) == 0 {
+		first++
+	}
+	if first >= len(rows) {
+		if region.HeaderRow == 0 {
+			return nil
+		}
+		return region
+	}
+	region.FirstDataRow = first + 1
+	region.LastDataRow = first + 1
+
+	// Rows with fewer cells than this are labels or notes rather than data
+	minCells := 1
+	if region.HeaderRow > 0 {
+		minCells = max(1, filledCells(rows[region.HeaderRow-1])/3)
+	}
+
+	var blanks []int
+scan:
+	for i := first; i < len(rows); i++ {
+		row := rows[i]
+		filled := filledCells(row)
+		switch {
+		case filled == 0:
+			blanks = append(blanks, i+1)
+			if len(blanks) >= maxBlankGap {
+				break scan
+			}
+		case isFooterRow(row):
+			break scan
+		case isTotalRow(f, sheetName, row, i+1):
+			region.TotalRows = append(region.TotalRows, i+1)
+		case filled >= minCells:
+			region.BlankRows = append(region.BlankRows, blanks...)
+			blanks = nil
+			region.LastDataRow = i + 1
+		}
+	}
+
+	// Totals after the last data row close the table; the rest below is footer
+	for _, row := range region.TotalRows {
+		if row > region.LastDataRow {
+			region.FooterRow = row
+			break
+		}
+	}
+	if region.FooterRow == 0 {
+		for i := region.LastDataRow; i < len(rows); i++ {
+			if filledCells(rows[i]) > 0 {
+				region.FooterRow = i + 1
+				break
+			}
+		}
+	}
+	return region
+}
+
+// mergedRange is a merged cell range of a sheet with the value shown across it
+type mergedRange struct {
+	startCol, startRow int
+	endCol, endRow     int
+	ref                string
+	value              string
+}
+
+// contains reports whether a cell lies inside the range
+func (m mergedRange) contains(col, row int) bool {
+	return col >= m.startCol && col <= m.endCol && row >= m.startRow && row <= m.endRow
+}
+
+// mergedRanges returns the merged ranges of a sheet
+func mergedRanges(f *excelize.File, sheetName string) []mergedRange {
+	cells, err := f.GetMergeCells(sheetName)
+	if err != nil {
+		return nil
+	}
+
+	ranges := make([]mergedRange, 0, len(cells))
+	for _, cell := range cells {
+		startCol, startRow, err1 := excelize.CellNameToCoordinates(cell.GetStartAxis())
+		endCol, endRow, err2 := excelize.CellNameToCoordinates(cell.GetEndAxis())
+		if err1 == nil && err2 == nil {
+			ranges = append(ranges, mergedRange{
+				startCol: startCol,
+				startRow: startRow,
+				endCol:   endCol,
+				endRow:   endRow,
+				ref:      cell.GetStartAxis() + ":" + cell.GetEndAxis(),
+				value:    headerText(cell.GetCellValue()),
+			})
+		}
+	}
+	return ranges
+}
+
+// mergedCells counts the cells of a row covered by merged ranges besides the cell holding
+// the value, so a group header spanning ten columns counts as ten filled cells
+func mergedCells(merges []mergedRange, rowNumber int) int {
+	n := 0
+	for _, m := range merges {
+		if m.value == "" || rowNumber < m.startRow || rowNumber > m.endRow {
+			continue
+		}
+		n += m.endCol - m.startCol + 1
+		if rowNumber == m.startRow {
+			n--
+		}
+	}
+	return n
+}
+
+// headerEnd returns the last row of a header starting at headerRow: the bottom of the
+// merged cells that start within it, such as an "STT" cell merged down over three rows
+func headerEnd(merges []mergedRange, headerRow int) int {
+	end := headerRow
+	for changed := true; changed; {
+		changed = false
+		for _, m := range merges {
+			if m.value != "" && m.startRow >= headerRow && m.startRow <= end && m.endRow > end {
+				end = m.endRow
+				changed = true
+			}
+		}
+	}
+	return end
+}
+
+// compositeHeaders names each column of a table after its header cells from top to bottom,
+// e.g. "Thu nhập / Lương" under a "Thu nhập" group header. Cells covered by a merged range
+// take its value, and a value repeated down a column is used once.
+func compositeHeaders(rows [][]string, merges []mergedRange, region *models.DataRegion) map[string]string {
+	if region == nil || region.HeaderRow == 0 {
+		return nil
+	}
+
+	lastCol := 0
+	for row := region.HeaderRow; row <= region.HeaderEndRow && row <= len(rows); row++ {
+		lastCol = max(lastCol, len(rows[row-1]))
+	}
+	for _, m := range merges {
+		if m.startRow <= region.HeaderEndRow && m.endRow >= region.HeaderRow {
+			lastCol = max(lastCol, m.endCol)
+		}
+	}
+
+	headers := make(map[string]string)
+	for col := 1; col <= lastCol; col++ {
+		var parts []string
+		for row := region.HeaderRow; row <= region.HeaderEndRow; row++ {
+			text := ""
+			if row <= len(rows) && col <= len(rows[row-1]) {
+				text = headerText(rows[row-1][col-1])
+			}
+			if text == "" {
+				for _, m := range merges {
+					if m.contains(col, row) {
+						text = m.value
+						break
+					}
+				}
+			}
+			if text != "" && (len(parts) == 0 || parts[len(parts)-1] != text) {
+				parts = append(parts, text)
+			}
+		}
+		if len(parts) > 0 {
+			column, _ := excelize.ColumnNumberToName(col)
+			headers[column] = strings.Join(parts, " / ")
+		}
+	}
+	return headers
+}
+
+// headerText collapses the line breaks and runs of spaces of a header cell
+func headerText(value string) string {
+	return strings.Join(strings.Fields(value), " ")
+}
+
+// mergedRegions lists the merged ranges of a sheet for SheetInfo
+func mergedRegions(merges []mergedRange) []models.MergedRegion {
+	if len(merges) == 0 {
+		return nil
+	}
+	regions := make([]models.MergedRegion, len(merges))
+	for i, m := range merges {
+		regions[i] = models.MergedRegion{Range: m.ref, Value: m.value}
+	}
+	return regions
+}
+
+// filledCells counts the non-blank cells of a row
+func filledCells(row []string) int {
+	n := 0
+	for _, cell := range row {
+		if strings.TrimSpace(cell) != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// numericCells counts the cells of a row that hold numbers
+func numericCells(row []string) int {
+	n := 0
+	for _, cell := range row {
+		if _, ok := cellNumber(cell); ok {
+			n++
+		}
+	}
+	return n
+}
+
+// isColumnNumberRow reports whether a row only numbers the columns of the header
+func isColumnNumberRow(row []string) bool {
+	filled := 0
+	for _, cell := range row {
+		cell = strings.TrimSpace(cell)
+		if cell == "" {
+			continue
+		}
+		if !columnNumberPattern.MatchString(cell) {
+			return false
+		}
+		filled++
+	}
+	return filled >= 2
+}
+
+// firstText returns the first non-numeric cell of a row in lower case
+func firstText(row []string) string {
+	for _, cell := range row {
+		cell = strings.TrimSpace(cell)
+		if _, numeric := cellNumber(cell); cell != "" && !numeric {
+			return strings.ToLower(cell)
+		}
+	}
+	return ""
+}
+
+// isTotalRow reports whether a row totals the rows above it, either by its label or by a
+// SUM over a range that ends above it
+func isTotalRow(f *excelize.File, sheetName string, row []string, rowNumber int) bool {
+	text := firstText(row)
+	for _, keyword := range totalRowKeywords {
+		// "Cộng:" and "Tổng cộng toàn đơn vị" count, "Tổng công ty" does not
+		if rest, found := strings.CutPrefix(text, keyword); found && (rest == "" || strings.ContainsAny(rest[:1], " :.-(")) {
+			return true
+		}
+	}
+
+	for col := range row {
+		cell, _ := excelize.CoordinatesToCellName(col+1, rowNumber)
+		formula, _ := f.GetCellFormula(sheetName, cell)
+		if !strings.Contains(strings.ToUpper(formula), "SUM") {
+			continue
+		}
+		for _, m := range rangeRefPattern.FindAllStringSubmatch(formula, -1) {
+			if end, _ := strconv.Atoi(m[4]); end < rowNumber {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isFooterRow reports whether a row belongs to the signature block under a table
+func isFooterRow(row []string) bool {
+	text := firstText(row)
+	if text == "" || numericCells(row) > 0 {
+		return false
+	}
+	for _, keyword := range footerRowKeywords {
+		if strings.Contains(text, keyword) {
+			return true
+		}
+	}
+	return footerDatePattern.MatchString(text)
+}
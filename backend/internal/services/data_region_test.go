@@ -0,0 +1,109 @@
+package services
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+
+	"excel-processor/internal/models"
+)
+
+// writeMultiRowHeaderSheet saves a report with a merged title, a two-row header grouping
+// "Lương" and "Phụ cấp" under "Thu nhập", a column numbering row, data, totals and signatures
+func writeMultiRowHeaderSheet(t *testing.T) string {
+	t.Helper()
+	f := excelize.NewFile()
+	defer f.Close()
+	sheet := "Sheet1"
+	f.SetCellValue(sheet, "A1", "BÁO CÁO THU NHẬP")
+	f.SetSheetRow(sheet, "A3", &[]interface{}{"STT", "Họ tên", "Thu nhập", nil, "Ghi\nchú"})
+	f.SetSheetRow(sheet, "C4", &[]interface{}{"Lương", "Phụ cấp"})
+	f.SetSheetRow(sheet, "A5", &[]interface{}{"(1)", "(2)", "(3)", "(4)", "(5)"})
+	f.SetSheetRow(sheet, "A6", &[]interface{}{1, "An", 1000, 200})
+	f.SetSheetRow(sheet, "A7", &[]interface{}{2, "Bình", 2000, 300})
+	f.SetSheetRow(sheet, "A8", &[]interface{}{3, "Chi", 1500, 100, "nghỉ phép"})
+	f.SetSheetRow(sheet, "A9", &[]interface{}{"Tổng cộng", nil, 4500, 600})
+	f.SetCellValue(sheet, "D11", "Người lập biểu")
+	for _, ref := range [][2]string{{"A1", "E1"}, {"A3", "A4"}, {"B3", "B4"}, {"C3", "D3"}, {"E3", "E4"}} {
+		if err := f.MergeCell(sheet, ref[0], ref[1]); err != nil {
+			t.Fatalf("MergeCell returned %v", err)
+		}
+	}
+	if err := f.SaveAs("report.xlsx"); err != nil {
+		t.Fatalf("SaveAs returned %v", err)
+	}
+	return "report.xlsx"
+}
+
+func TestGetSheetsMultiRowHeader(t *testing.T) {
+	t.Chdir(t.TempDir())
+	sheets, err := NewExcelService().GetSheets(writeMultiRowHeaderSheet(t))
+	if err != nil {
+		t.Fatalf("GetSheets returned %v", err)
+	}
+	if len(sheets) != 1 {
+		t.Fatalf("GetSheets returned %d sheets, want 1", len(sheets))
+	}
+	sheet := sheets[0]
+
+	wantRegion := models.DataRegion{HeaderRow: 3, HeaderEndRow: 4, FirstDataRow: 6, LastDataRow: 8, TotalRows: []int{9}, FooterRow: 9}
+	if sheet.Region == nil {
+		t.Fatal("no data region detected")
+	}
+	if got := *sheet.Region; fmt.Sprint(got) != fmt.Sprint(wantRegion) {
+		t.Errorf("region = %+v, want %+v", got, wantRegion)
+	}
+
+	wantHeaders := map[string]string{
+		"A": "STT",
+		"B": "Họ tên",
+		"C": "Thu nhập / Lương",
+		"D": "Thu nhập / Phụ cấp",
+		"E": "Ghi chú",
+	}
+	if !maps.Equal(sheet.Headers, wantHeaders) {
+		t.Errorf("headers = %v, want %v", sheet.Headers, wantHeaders)
+	}
+
+	var merged []string
+	for _, region := range sheet.MergedRegions {
+		merged = append(merged, region.Range+"="+region.Value)
+	}
+	slices.Sort(merged)
+	wantMerged := []string{"A1:E1=BÁO CÁO THU NHẬP", "A3:A4=STT", "B3:B4=Họ tên", "C3:D3=Thu nhập", "E3:E4=Ghi chú"}
+	if !slices.Equal(merged, wantMerged) {
+		t.Errorf("merged regions = %q, want %q", merged, wantMerged)
+	}
+}
+
+func TestDetectDataRegionSingleHeader(t *testing.T) {
+	f := excelize.NewFile()
+	defer f.Close()
+	rows := [][]interface{}{
+		{"Họ tên", "Lương"},
+		{"An", 1000},
+		{},
+		{"Bình", 2000},
+		{"Cộng", 3000},
+	}
+	for i, row := range rows {
+		f.SetSheetRow("Sheet1", fmt.Sprintf("A%d", i+1), &row)
+	}
+	sheetRows, _ := f.GetRows("Sheet1")
+
+	region := detectDataRegion(f, "Sheet1", sheetRows)
+	want := models.DataRegion{HeaderRow: 1, HeaderEndRow: 1, FirstDataRow: 2, LastDataRow: 4, TotalRows: []int{5}, BlankRows: []int{3}, FooterRow: 5}
+	if region == nil || fmt.Sprint(*region) != fmt.Sprint(want) {
+		t.Errorf("region = %+v, want %+v", region, want)
+	}
+	if headers := compositeHeaders(sheetRows, nil, region); headers["B"] != "Lương" || len(headers) != 2 {
+		t.Errorf("headers = %v, want A and B named after row 1", headers)
+	}
+
+	if region := detectDataRegion(f, "Sheet1", nil); region != nil {
+		t.Errorf("region of an empty sheet = %+v, want nil", region)
+	}
+}
@@ -0,0 +1,200 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+
+	"excel-processor/internal/models"
+)
+
+// ValidateRounding checks the columns of a rounding map and upper-cases them
+func ValidateRounding(rounding map[string]models.RoundingRule) error {
+	columns := make([]string, 0, len(rounding))
+	for column := range rounding {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	for _, column := range columns {
+		rule := rounding[column]
+		normalized := strings.ToUpper(strings.TrimSpace(column))
+		if err := validateColumns(normalized); err != nil {
+			return fmt.Errorf("rounding: %w", err)
+		}
+		delete(rounding, column)
+		rounding[normalized] = rule
+	}
+	return nil
+}
+
+// roundingRule returns the rounding of a column, nil when it has none. Columns of the map
+// are upper case after ValidateRounding.
+func roundingRule(rounding map[string]models.RoundingRule, column string) *models.RoundingRule {
+	if rule, ok := rounding[strings.ToUpper(strings.TrimSpace(column))]; ok {
+		return &rule
+	}
+	return nil
+}
+
+// decimalValue returns the exact value of a cell or number. Floats are read from their
+// shortest decimal form, so a cell holding 0.1 is 1/10 rather than its binary approximation.
+func decimalValue(v interface{}) (*big.Rat, bool) {
+	switch val := v.(type) {
+	case float64:
+		if math.IsInf(val, 0) || math.IsNaN(val) {
+			return nil, false
+		}
+		return new(big.Rat).SetString(strconv.FormatFloat(val, 'f', -1, 64))
+	case int:
+		return big.NewRat(int64(val), 1), true
+	case string:
+		// Accept exactly what cellNumber accepts, so "1/3" is not a fraction here either
+		text := strings.TrimSpace(strings.ReplaceAll(val, ",", ""))
+		if _, err := strconv.ParseFloat(text, 64); err != nil {
+			return nil, false
+		}
+		return new(big.Rat).SetString(text)
+	}
+	return nil, false
+}
+
+// decimalRowWise is rowWiseValue in exact arithmetic
+func decimalRowWise(operation string, values []*big.Rat) (*big.Rat, bool) {
+	if len(values) == 0 {
+		return new(big.Rat), true
+	}
+
+	result, ok := new(big.Rat).Set(values[0]), true
+	for _, value := range values[1:] {
+		switch operation {
+		case "add":
+			result.Add(result, value)
+		case "subtract":
+			result.Sub(result, value)
+		case "multiply":
+			result.Mul(result, value)
+		case "divide":
+			if value.Sign() == 0 {
+				ok = false
+				continue
+			}
+			result.Quo(result, value)
+		}
+	}
+	return result, ok
+}
+
+// roundDecimal rounds a value to the precision of a rule
+func roundDecimal(value *big.Rat, rule models.RoundingRule) *big.Rat {
+	scale := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(rule.Precision))), nil))
+	scaled := new(big.Rat)
+	if rule.Precision >= 0 {
+		scaled.Mul(value, scale)
+	} else {
+		scaled.Quo(value, scale)
+	}
+
+	// Truncate towards zero, then move away from it as the mode asks
+	quotient, remainder := new(big.Int).QuoRem(scaled.Num(), scaled.Denom(), new(big.Int))
+	if remainder.Sign() != 0 {
+		sign := scaled.Sign()
+		half := new(big.Int).Abs(remainder)
+		half.Mul(half, big.NewInt(2))
+		cmp := half.Cmp(scaled.Denom())
+
+		away := false
+		switch rule.Mode {
+		case "up":
+			away = true
+		case "floor":
+			away = sign < 0
+		case "ceiling":
+			away = sign > 0
+		case "half_up":
+			away = cmp >= 0
+		case "half_even":
+			away = cmp > 0 || (cmp == 0 && quotient.Bit(0) == 1)
+		}
+		if away {
+			quotient.Add(quotient, big.NewInt(int64(sign)))
+		}
+	}
+
+	rounded := new(big.Rat).SetInt(quotient)
+	if rule.Precision >= 0 {
+		return rounded.Quo(rounded, scale)
+	}
+	return rounded.Mul(rounded, scale)
+}
+
+// decimalResult converts an exact result to float64 for JSON and spreadsheets, rounding it
+// first when a rule is given. The nearest float64 prints as the exact decimal.
+func decimalResult(value *big.Rat, rule *models.RoundingRule) float64 {
+	if rule != nil {
+		value = roundDecimal(value, *rule)
+	}
+	f, _ := value.Float64()
+	return f
+}
+
+// floatResult rounds a float64 result when a rule is given
+func floatResult(value float64, rule *models.RoundingRule) float64 {
+	if rule == nil {
+		return value
+	}
+	exact, ok := decimalValue(value)
+	if !ok {
+		return value
+	}
+	return decimalResult(exact, rule)
+}
+
+// numberTotal sums numbers as float64, or exactly in decimal mode
+type numberTotal struct {
+	decimal bool
+	count   int
+	float   float64
+	exact   big.Rat
+}
+
+// add adds a number to the total
+func (t *numberTotal) add(value float64) {
+	t.count++
+	if !t.decimal {
+		t.float += value
+		return
+	}
+	if exact, ok := decimalValue(value); ok {
+		t.exact.Add(&t.exact, exact)
+	}
+}
+
+// sum returns the total, rounded when a rule is given
+func (t *numberTotal) sum(rule *models.RoundingRule) float64 {
+	if t.decimal {
+		return decimalResult(new(big.Rat).Set(&t.exact), rule)
+	}
+	return floatResult(t.float, rule)
+}
+
+// average returns the mean of the numbers added, 0 when there are none
+func (t *numberTotal) average(rule *models.RoundingRule) float64 {
+	if t.count == 0 {
+		return 0
+	}
+	if t.decimal {
+		return decimalResult(new(big.Rat).Quo(&t.exact, big.NewRat(int64(t.count), 1)), rule)
+	}
+	return floatResult(t.float/float64(t.count), rule)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"math/big"
+	"testing"
+
+	"excel-processor/internal/models"
+)
+
+func TestRoundDecimal(t *testing.T) {
+	tests := []struct {
+		value     string
+		mode      string
+		precision int
+		want      string
+	}{
+		{"2.675", "half_up", 2, "2.68"}, // 2.67 in float64
+		{"-2.5", "half_up", 0, "-3"},
+		{"2.5", "half_even", 0, "2"},
+		{"3.5", "half_even", 0, "4"},
+		{"2.51", "half_even", 0, "3"},
+		{"-2.7", "down", 0, "-2"},
+		{"2.1", "up", 0, "3"},
+		{"-2.1", "floor", 0, "-3"},
+		{"-2.9", "ceiling", 0, "-2"},
+		{"2.1", "ceiling", 0, "3"},
+		{"1234567", "half_up", -3, "1235000"}, // To the nearest 1,000 VND
+		{"1234999", "down", -3, "1234000"},
+		{"2.5", "up", 1, "2.5"},
+	}
+	for _, tt := range tests {
+		got := roundDecimal(rat(t, tt.value), models.RoundingRule{Mode: tt.mode, Precision: tt.precision})
+		if got.Cmp(rat(t, tt.want)) != 0 {
+			t.Errorf("roundDecimal(%s, %s, %d) = %s, want %s", tt.value, tt.mode, tt.precision, got.FloatString(4), tt.want)
+		}
+	}
+}
+
+func TestDecimalValue(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  string // "" when the value is not a number
+	}{
+		{0.1, "0.1"},
+		{12, "12"},
+		{"1,200.5", "1200.5"},
+		{" 3 ", "3"},
+		{"1/3", ""},
+		{"#N/A", ""},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		got, ok := decimalValue(tt.value)
+		if ok != (tt.want != "") || (ok && got.Cmp(rat(t, tt.want)) != 0) {
+			t.Errorf("decimalValue(%#v) = %v, %v; want %q", tt.value, got, ok, tt.want)
+		}
+	}
+}
+
+func TestDecimalArithmetic(t *testing.T) {
+	exact := &numberTotal{decimal: true}
+	float := &numberTotal{}
+	for range 10 {
+		exact.add(0.1)
+		float.add(0.1)
+	}
+	if got := exact.sum(nil); got != 1 {
+		t.Errorf("decimal sum of ten 0.1 = %v, want 1", got)
+	}
+	if got := float.sum(nil); got == 1 {
+		t.Error("float sum of ten 0.1 is exactly 1; the decimal test proves nothing")
+	}
+	if got := exact.average(&models.RoundingRule{Mode: "half_up", Precision: 2}); got != 0.1 {
+		t.Errorf("decimal average = %v, want 0.1", got)
+	}
+
+	third, ok := decimalRowWise("divide", []*big.Rat{rat(t, "1"), rat(t, "3")})
+	if product, _ := decimalRowWise("multiply", []*big.Rat{third, rat(t, "3")}); !ok || product.Cmp(rat(t, "1")) != 0 {
+		t.Errorf("1 / 3 * 3 = %s, want 1", product.FloatString(20))
+	}
+	if result, ok := decimalRowWise("divide", []*big.Rat{rat(t, "10"), new(big.Rat), rat(t, "4")}); ok || result.Cmp(rat(t, "2.5")) != 0 {
+		t.Errorf("10 / 0 / 4 = %s, %v; want 2.5 with the zero divisor reported", result.FloatString(2), ok)
+	}
+	if got := floatResult(2.675, &models.RoundingRule{Mode: "half_up", Precision: 2}); got != 2.68 {
+		t.Errorf("floatResult(2.675) = %v, want 2.68", got)
+	}
+}
+
+func TestValidateRounding(t *testing.T) {
+	rounding := map[string]models.RoundingRule{" l ": {Mode: "half_up"}, "m": {Mode: "down", Precision: -3}}
+	if err := ValidateRounding(rounding); err != nil {
+		t.Fatalf("ValidateRounding returned %v", err)
+	}
+	if rule := roundingRule(rounding, "l"); rule == nil || rule.Mode != "half_up" || len(rounding) != 2 {
+		t.Errorf("rounding = %v, want columns L and M", rounding)
+	}
+	if roundingRule(rounding, "N") != nil {
+		t.Error("column N has a rounding rule, want none")
+	}
+	if err := ValidateRounding(map[string]models.RoundingRule{"1X": {Mode: "up"}}); err == nil {
+		t.Error("ValidateRounding accepted column 1X")
+	}
+}
@@ -1,11 +1,13 @@
 package services
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
-	"os"
 
 	"github.com/xuri/excelize/v2"
 	"excel-processor/internal/models"
@@ -18,6 +20,62 @@ func NewExcelService() *ExcelService {
 	return &ExcelService{}
 }
 
+// openWorkbook opens an uploaded spreadsheet. CSV/TSV files are loaded into an
+// in-memory workbook with a single sheet so all readers can treat them alike.
+func (s *ExcelService) openWorkbook(filePath string) (*excelize.File, error) {
+	if isDelimitedFile(filePath) {
+		return s.openDelimitedFile(filePath)
+	}
+	return excelize.OpenFile(filePath)
+}
+
+// PrepareUpload makes an uploaded file readable by the rest of the service and returns the
+// path to use from now on. Legacy .xls (BIFF8) and OpenDocument .ods workbooks are converted
+// to .xlsx next to the original; every file is then opened once so unreadable uploads are
+// rejected immediately. A converted copy is removed again when the check fails, and the
+// original is removed once its copy is accepted since only the .xlsx is registered. The
+// caller stays responsible for the original when an error is returned.
+func (s *ExcelService) PrepareUpload(filePath string) (string, error) {
+	var convert func(string) (string, error)
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".xls":
+		convert = s.ConvertXLSToXLSX
+	case ".ods":
+		convert = s.ConvertODSToXLSX
+	}
+	if convert != nil {
+		converted, err := convert(filePath)
+		if err != nil {
+			return "", err
+		}
+		if err := s.checkWorkbook(converted); err != nil {
+			os.Remove(converted)
+			return "", err
+		}
+		os.Remove(filePath)
+		return converted, nil
+	}
+
+	if err := s.checkWorkbook(filePath); err != nil {
+		return "", err
+	}
+	return filePath, nil
+}
+
+// checkWorkbook opens a file once and rejects it when it is unreadable or has no sheets
+func (s *ExcelService) checkWorkbook(filePath string) error {
+	f, err := s.openWorkbook(filePath)
+	if err != nil {
+		return fmt.Errorf("file is not a readable spreadsheet: %w", err)
+	}
+	defer f.Close()
+
+	if len(f.GetSheetList()) == 0 {
+		return fmt.Errorf("file has no sheets")
+	}
+	return nil
+}
+
 // getAllExcelColumns returns all column letters (A, B, C, ..., AK) that have data in any row
 func (s *ExcelService) getAllExcelColumns(f *excelize.File, sheetName string) []string {
 	// Get the sheet dimension to find the actual used range
@@ -63,7 +121,7 @@ func (s *ExcelService) parseNumberWithCommas(str string) (float64, error) {
 
 // GetSheets returns all sheet names and their info from an Excel file
 func (s *ExcelService) GetSheets(filePath string) ([]models.SheetInfo, error) {
-	f, err := excelize.OpenFile(filePath)
+	f, err := s.openWorkbook(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open file: %w", err)
 	}
@@ -81,10 +139,15 @@ func (s *ExcelService) GetSheets(filePath string) ([]models.SheetInfo, error) {
 		// Get all columns from Excel structure, not just from first row
 		columns := s.getAllExcelColumns(f, sheetName)
 
+		merges := mergedRanges(f, sheetName)
+		region := detectDataRegion(f, sheetName, rows)
 		sheets = append(sheets, models.SheetInfo{
-			Name:     sheetName,
-			Columns:  columns,
-			RowCount: len(rows),
+			Name:          sheetName,
+			Columns:       columns,
+			RowCount:      len(rows),
+			Region:        region,
+			Headers:       compositeHeaders(rows, merges, region),
+			MergedRegions: mergedRegions(merges),
 		})
 	}
 
@@ -93,7 +156,7 @@ func (s *ExcelService) GetSheets(filePath string) ([]models.SheetInfo, error) {
 
 // GetSheetData returns all data from a specific sheet
 func (s *ExcelService) GetSheetData(filePath, sheetName string) ([]map[string]interface{}, error) {
-	f, err := excelize.OpenFile(filePath)
+	f, err := s.openWorkbook(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open file: %w", err)
 	}
@@ -143,15 +206,34 @@ func (s *ExcelService) CalculateColumns(filePath string, req models.CalculationR
 		return nil, err
 	}
 
+	// Leave out the header and totals rows unless a start row is given
+	var region *models.DataRegion
+	if req.StartRow == nil {
+		if region, err = s.DetectRegion(filePath, req.SheetName); err != nil {
+			return nil, fmt.Errorf("failed to read sheet data: %w", err)
+		}
+	}
+	startRow, endRow := dataRange(region, req.StartRow, nil, len(data))
+	if startRow < 0 || startRow > len(data) {
+		return nil, fmt.Errorf("invalid start row: %d (data has %d rows)", startRow, len(data))
+	}
+	data = data[startRow : endRow+1]
+
 	result := &models.CalculationResult{
 		MainColumn: req.MainColumn,
 		Results:    make([]map[string]interface{}, 0),
 		Summary:    make(map[string]float64),
 	}
 
+	decimal := req.Arithmetic == models.ArithmeticDecimal
+	totals := make(map[string]*numberTotal)
+
 	// Group data by main column value
 	groups := make(map[string][]map[string]interface{})
 	for _, row := range data {
+		if !rowFilterMatches(req.Filter, mapCell(row)) {
+			continue
+		}
 		if mainVal, exists := row[req.MainColumn]; exists {
 			key := fmt.Sprintf("%v", mainVal)
 			groups[key] = append(groups[key], row)
@@ -165,41 +247,39 @@ func (s *ExcelService) CalculateColumns(filePath string, req models.CalculationR
 		}
 
 		for _, targetCol := range req.TargetColumns {
-			sum := 0.0
-			count := 0
+			group := &numberTotal{decimal: decimal}
+			if totals[targetCol] == nil {
+				totals[targetCol] = &numberTotal{decimal: decimal}
+			}
 
 			for _, row := range groupData {
 				if val, exists := row[targetCol]; exists {
 					if numVal, ok := val.(float64); ok {
-						sum += numVal
-						count++
+						group.add(numVal)
+						totals[targetCol].add(numVal)
 					}
 				}
 			}
 
+			rule := roundingRule(req.Rounding, targetCol)
 			switch req.Operation {
 			case "sum":
-				groupResult[targetCol] = sum
+				groupResult[targetCol] = group.sum(rule)
 			case "average":
-				if count > 0 {
-					groupResult[targetCol] = sum / float64(count)
-				} else {
-					groupResult[targetCol] = 0
-				}
+				groupResult[targetCol] = group.average(rule)
 			case "count":
-				groupResult[targetCol] = count
-			}
-
-			// Add to summary
-			if _, exists := result.Summary[targetCol]; !exists {
-				result.Summary[targetCol] = 0
+				groupResult[targetCol] = group.count
 			}
-			result.Summary[targetCol] += sum
 		}
 
 		result.Results = append(result.Results, groupResult)
 	}
 
+	// The summary adds up every group
+	for targetCol, total := range totals {
+		result.Summary[targetCol] = total.sum(roundingRule(req.Rounding, targetCol))
+	}
+
 	return result, nil
 }
 
@@ -226,20 +306,28 @@ func (s *ExcelService) CalculateColumnWithFile(file models.ExcelFile, req models
 		return nil, fmt.Errorf("no data found in sheet")
 	}
 
-	// Determine start row (default to 0 if not specified)
-	startRow := 0
-	if req.StartRow != nil {
-		startRow = *req.StartRow
-		if startRow < 0 || startRow >= len(data) {
-			return nil, fmt.Errorf("invalid start row: %d (data has %d rows)", startRow, len(data))
+	// Determine the range, defaulting to the detected data table
+	var region *models.DataRegion
+	if req.StartRow == nil {
+		if region, err = s.DetectRegion(file.FilePath, req.SheetName); err != nil {
+			return nil, fmt.Errorf("failed to read sheet data: %w", err)
 		}
 	}
+	startRow, endRow := dataRange(region, req.StartRow, nil, len(data))
+	if startRow < 0 || startRow >= len(data) {
+		return nil, fmt.Errorf("invalid start row: %d (data has %d rows)", startRow, len(data))
+	}
 
-	// Extract column values starting from the specified row
+	// Extract column values from the rows in range
 	var values []float64
-	processedRows := data[startRow:] // Only process rows from startRow onwards
+	processedRows := data[startRow : endRow+1]
+	filteredRows := 0
 	
 	for _, row := range processedRows {
+		if !rowFilterMatches(req.Filter, mapCell(row)) {
+			filteredRows++
+			continue
+		}
 		if val, exists := row[req.MainColumn]; exists {
 			if strVal, ok := val.(string); ok {
 				// Use parseNumberWithCommas to handle comma-separated numbers
@@ -258,17 +346,16 @@ func (s *ExcelService) CalculateColumnWithFile(file models.ExcelFile, req models
 
 	// Perform calculation
 	var result float64
+	total := &numberTotal{decimal: req.Arithmetic == models.ArithmeticDecimal}
+	for _, v := range values {
+		total.add(v)
+	}
+	rule := roundingRule(req.Rounding, req.MainColumn)
 	switch req.Operation {
 	case "sum":
-		for _, v := range values {
-			result += v
-		}
+		result = total.sum(rule)
 	case "average":
-		sum := 0.0
-		for _, v := range values {
-			sum += v
-		}
-		result = sum / float64(len(values))
+		result = total.average(rule)
 	case "count":
 		result = float64(len(values))
 	case "max":
@@ -278,6 +365,7 @@ func (s *ExcelService) CalculateColumnWithFile(file models.ExcelFile, req models
 				result = v
 			}
 		}
+		result = floatResult(result, rule)
 	case "min":
 		result = values[0]
 		for _, v := range values {
@@ -285,6 +373,7 @@ func (s *ExcelService) CalculateColumnWithFile(file models.ExcelFile, req models
 				result = v
 			}
 		}
+		result = floatResult(result, rule)
 	default:
 		return nil, fmt.Errorf("unsupported operation: %s", req.Operation)
 	}
@@ -296,21 +385,35 @@ func (s *ExcelService) CalculateColumnWithFile(file models.ExcelFile, req models
 		},
 		Results: []map[string]interface{}{
 			{
-				"column":      req.MainColumn,
-				"operation":   req.Operation,
-				"result":      result,
-				"count":       len(values),
-				"total_rows":  len(processedRows),
-				"start_row":   startRow,
-				"data_length": len(data),
+				"column":        req.MainColumn,
+				"operation":     req.Operation,
+				"result":        result,
+				"count":         len(values),
+				"total_rows":    len(processedRows),
+				"start_row":     startRow,
+				"data_length":   len(data),
+				"filtered_rows": filteredRows,
 			},
 		},
 	}, nil
 }
 
 // CalculateRowWise performs row-wise calculations (e.g., I11 + K11 = L11, I12 + K12 = L12, ...)
-func (s *ExcelService) CalculateRowWise(filePath string, req models.RowCalculationRequest) (*models.RowCalculationResult, error) {
-	f, err := excelize.OpenFile(filePath)
+// The formula operation calculates req.Formula, whose PIT functions read taxTable.
+func (s *ExcelService) CalculateRowWise(filePath string, req models.RowCalculationRequest, taxTable *models.TaxTable) (*models.RowCalculationResult, error) {
+	var parsed *Formula
+	if req.Operation == "formula" {
+		var err error
+		if parsed, err = ParseFormula(req.Formula); err != nil {
+			return nil, err
+		}
+		if parsed.UsesTaxTable() && taxTable == nil {
+			return nil, fmt.Errorf("the formula needs a tax table")
+		}
+		req.SourceColumns = parsed.Columns
+	}
+
+	f, err := s.openWorkbook(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open file: %w", err)
 	}
@@ -322,17 +425,14 @@ func (s *ExcelService) CalculateRowWise(filePath string, req models.RowCalculati
 		return nil, fmt.Errorf("failed to read sheet %s: %w", req.SheetName, err)
 	}
 
-	// Determine end row (auto-detect if not specified)
-	endRow := len(rows) - 1 // Convert to 0-based index
-	if req.EndRow != nil {
-		endRow = *req.EndRow
-	}
+	// Determine the range, defaulting to the detected data table
+	startRow, endRow := dataRange(detectDataRegion(f, req.SheetName, rows), req.StartRow, req.EndRow, len(rows))
 
 	// Validate row range
-	if req.StartRow < 0 || req.StartRow >= len(rows) {
-		return nil, fmt.Errorf("invalid start row: %d (sheet has %d rows)", req.StartRow, len(rows))
+	if startRow < 0 || startRow >= len(rows) {
+		return nil, fmt.Errorf("invalid start row: %d (sheet has %d rows)", startRow, len(rows))
 	}
-	if endRow < req.StartRow || endRow >= len(rows) {
+	if endRow < startRow || endRow >= len(rows) {
 		endRow = len(rows) - 1
 	}
 
@@ -361,25 +461,40 @@ func (s *ExcelService) CalculateRowWise(filePath string, req models.RowCalculati
 		}
 	case "copy":
 		formula = fmt.Sprintf("Copy from %s", req.SourceColumns[0])
+	case "formula":
+		formula = req.Formula
 	}
 
 	result := &models.RowCalculationResult{
 		SourceColumns: req.SourceColumns,
 		TargetColumn:  req.TargetColumn,
 		Operation:     req.Operation,
-		StartRow:      req.StartRow + 1, // Convert to 1-based for display
+		StartRow:      startRow + 1, // Convert to 1-based for display
 		EndRow:        endRow + 1,       // Convert to 1-based for display
 		Results:       make([]map[string]interface{}, 0),
 		Formula:       formula,
 	}
 
+	options := rowWiseOptions{
+		OnDivideByZero: req.OnDivideByZero,
+		OnInvalidValue: req.OnInvalidValue,
+		Decimal:        req.Arithmetic == models.ArithmeticDecimal,
+		Rounding:       roundingRule(req.Rounding, req.TargetColumn),
+		Formula:        parsed,
+		TaxTable:       taxTable,
+	}
+
 	// Perform row-wise calculations
-	for rowIndex := req.StartRow; rowIndex <= endRow; rowIndex++ {
+	for rowIndex := startRow; rowIndex <= endRow; rowIndex++ {
 		if rowIndex >= len(rows) {
 			break
 		}
 
 		row := rows[rowIndex]
+		if !rowFilterMatches(req.Filter, sheetRowCell(row)) {
+			result.FilteredRows++
+			continue
+		}
 		
 		// For copy operation, handle text data differently
 		if req.Operation == "copy" {
@@ -409,59 +524,27 @@ func (s *ExcelService) CalculateRowWise(filePath string, req models.RowCalculati
 			continue
 		}
 		
-		// For numeric operations, continue with existing logic
-		values := make([]float64, 0)
-		
-		// Get values from source columns
-		for _, colName := range req.SourceColumns {
-			colIndex, err := excelize.ColumnNameToNumber(colName)
-			if err != nil {
-				continue
-			}
-			colIndex-- // Convert to 0-based
-
-			var value float64 = 0
-			if colIndex < len(row) && row[colIndex] != "" {
-				// Use the new parseNumberWithCommas function to handle commas
-				if val, err := s.parseNumberWithCommas(row[colIndex]); err == nil {
-					value = val
-				}
-			}
-			values = append(values, value)
-		}
-
-		// Calculate result based on operation
-		var calculatedValue float64 = 0
-		if len(values) > 0 {
-			calculatedValue = values[0]
-			
-			for i := 1; i < len(values); i++ {
-				switch req.Operation {
-				case "add":
-					calculatedValue += values[i]
-				case "subtract":
-					calculatedValue -= values[i]
-				case "multiply":
-					calculatedValue *= values[i]
-				case "divide":
-					if values[i] != 0 {
-						calculatedValue /= values[i]
-					}
-				}
-			}
+		// For numeric operations, blank cells count as 0 and problem values follow the policies
+		outcome, err := evaluateRowWise(req.Operation, req.SourceColumns, sheetRowCell(row), rowIndex+1, options)
+		result.Warnings = append(result.Warnings, outcome.warnings...)
+		if err != nil {
+			return nil, err
+		}
+		if outcome.skip {
+			continue
 		}
 
 		// Store result
 		rowResult := map[string]interface{}{
 			"row_number":       rowIndex + 1, // 1-based for display
-			"calculated_value": calculatedValue,
-			req.TargetColumn:   calculatedValue,
+			"calculated_value": outcome.value,
+			req.TargetColumn:   outcome.value,
 		}
 
 		// Add source values for reference
 		for i, colName := range req.SourceColumns {
-			if i < len(values) {
-				rowResult[colName] = values[i]
+			if i < len(outcome.values) {
+				rowResult[colName] = outcome.values[i]
 			}
 		}
 
@@ -472,13 +555,103 @@ func (s *ExcelService) CalculateRowWise(filePath string, req models.RowCalculati
 	return result, nil
 }
 
-// ExportRowCalculationToTemplate exports row calculation results to a template file
-func (s *ExcelService) ExportRowCalculationToTemplate(calculationResult *models.RowCalculationResult, templatePath string) (string, error) {
-	// Check if template exists
+// dataRange returns the 0-based row range to calculate over. Without a start row the range
+// defaults to the detected data table when there is one, and otherwise to the whole sheet.
+// An explicit start row without an end row runs to the end of the sheet, so rows below a
+// detected totals row or blank gap are not dropped silently.
+func dataRange(region *models.DataRegion, start, end *int, rowCount int) (int, int) {
+	startRow, endRow := 0, rowCount-1
+	detected := start == nil && region != nil && region.FirstDataRow > 0
+	if start != nil {
+		startRow = *start
+	} else if detected {
+		startRow = region.FirstDataRow - 1
+	}
+	if end != nil {
+		endRow = *end
+	} else if detected && region.LastDataRow > startRow {
+		endRow = min(region.LastDataRow-1, rowCount-1)
+	}
+	return startRow, endRow
+}
+
+// sheetRowCell returns a cell accessor over a row read with GetRows, for row filters
+func sheetRowCell(row []string) func(string) interface{} {
+	return func(column string) interface{} {
+		colIndex, err := excelize.ColumnNameToNumber(column)
+		if err != nil || colIndex > len(row) {
+			return nil
+		}
+		return row[colIndex-1]
+	}
+}
+
+// mapCell returns a cell accessor over a row from GetSheetData, for row filters
+func mapCell(row map[string]interface{}) func(string) interface{} {
+	return func(column string) interface{} {
+		return row[column]
+	}
+}
+
+// typedCellValue returns the display text of a cell and its numeric value when it is a number,
+// for writers that keep numbers typed (ODS, CSV/JSON, PDF alignment, consolidation and recipes).
+// Formulas are recalculated since cached values may be stale after template edits.
+func typedCellValue(f *excelize.File, sheet, cell, display string) (string, float64, bool) {
+	raw, _ := f.GetCellValue(sheet, cell, excelize.Options{RawCellValue: true})
+	if formula, _ := f.GetCellFormula(sheet, cell); formula != "" {
+		if calculated, err := f.CalcCellValue(sheet, cell, excelize.Options{RawCellValue: true}); err == nil {
+			raw = calculated
+			display = calculated
+		}
+	}
+
+	cellType, _ := f.GetCellType(sheet, cell)
+	if cellType != excelize.CellTypeSharedString && cellType != excelize.CellTypeInlineString {
+		if number, err := strconv.ParseFloat(raw, 64); err == nil {
+			if display == "" {
+				display = raw
+			}
+			return display, number, true
+		}
+	}
+	return display, 0, false
+}
+
+// rowWiseValue folds the source values of one row with a row-wise operation
+// (add, subtract, multiply or divide). It reports false when a divisor is 0; that
+// divisor is then left out.
+func rowWiseValue(operation string, values []float64) (float64, bool) {
+	if len(values) == 0 {
+		return 0, true
+	}
+
+	calculatedValue, ok := values[0], true
+	for i := 1; i < len(values); i++ {
+		switch operation {
+		case "add":
+			calculatedValue += values[i]
+		case "subtract":
+			calculatedValue -= values[i]
+		case "multiply":
+			calculatedValue *= values[i]
+		case "divide":
+			if values[i] == 0 {
+				ok = false
+				continue
+			}
+			calculatedValue /= values[i]
+		}
+	}
+	return calculatedValue, ok
+}
+
+// ExportRowCalculationToTemplate exports row calculation results to a template file.
+// Each result is written to TargetColumn at row_number + RowOffset on the requested sheet.
+func (s *ExcelService) ExportRowCalculationToTemplate(templatePath string, req models.TemplateExportRequest) (string, error) {
 	if templatePath == "" {
-		templatePath = "templates/FileMauImportThuNhap.xlsx"
+		return "", fmt.Errorf("template not specified")
 	}
-	
+
 	// Open template file
 	f, err := excelize.OpenFile(templatePath)
 	if err != nil {
@@ -486,22 +659,54 @@ func (s *ExcelService) ExportRowCalculationToTemplate(calculationResult *models.
 	}
 	defer f.Close()
 
-	// Get the first sheet name
+	// Resolve target sheet, defaulting to the first one
 	sheetList := f.GetSheetList()
 	if len(sheetList) == 0 {
 		return "", fmt.Errorf("template file has no sheets")
 	}
-	sheetName := sheetList[0]
+	sheetName := req.TemplateSheet
+	if sheetName == "" {
+		sheetName = sheetList[0]
+	} else if idx, _ := f.GetSheetIndex(sheetName); idx < 0 {
+		return "", fmt.Errorf("sheet %s not found in template", sheetName)
+	}
+
+	calculationResult := req.CalculationResult
+	if calculationResult.TargetColumn == "" {
+		return "", fmt.Errorf("target column not specified")
+	}
+	targetCol, err := excelize.ColumnNameToNumber(calculationResult.TargetColumn)
+	if err != nil {
+		return "", fmt.Errorf("invalid target column %s: %w", calculationResult.TargetColumn, err)
+	}
+
+	// Resolve target rows first so the template can grow before writing
+	targetRows := make([]int, len(calculationResult.Results))
+	lastTargetRow := 0
+	for i, rowResult := range calculationResult.Results {
+		rowNumber, ok := toInt(rowResult["row_number"])
+		if !ok {
+			return "", fmt.Errorf("result %d has no valid row_number", i)
+		}
+
+		targetRows[i] = rowNumber + req.RowOffset
+		if targetRows[i] < 1 {
+			return "", fmt.Errorf("row %d with offset %d is outside the sheet", rowNumber, req.RowOffset)
+		}
+		if targetRows[i] > lastTargetRow {
+			lastTargetRow = targetRows[i]
+		}
+	}
+
+	if _, err := s.prepareTemplateRows(f, sheetName, req.PrototypeRow, req.TemplateEndRow, lastTargetRow); err != nil {
+		return "", fmt.Errorf("failed to extend template rows: %w", err)
+	}
 
 	// Write calculation results to the target column
-	for _, rowResult := range calculationResult.Results {
-		rowNumber := int(rowResult["row_number"].(float64))
-		calculatedValue := rowResult["calculated_value"].(float64)
-		
-		// Write to target column at the specific row
-		cellAddress := fmt.Sprintf("%s%d", calculationResult.TargetColumn, rowNumber)
-		err := f.SetCellValue(sheetName, cellAddress, calculatedValue)
-		if err != nil {
+	for i, rowResult := range calculationResult.Results {
+		targetRow := targetRows[i]
+		cellAddress, _ := excelize.CoordinatesToCellName(targetCol, targetRow)
+		if err := f.SetCellValue(sheetName, cellAddress, templateCellValue(rowResult["calculated_value"])); err != nil {
 			return "", fmt.Errorf("failed to write to cell %s: %w", cellAddress, err)
 		}
 	}
@@ -521,7 +726,53 @@ func (s *ExcelService) ExportRowCalculationToTemplate(calculationResult *models.
 	return outputPath, nil
 }
 
-// ExportToExcel exports data to Excel file
+// toInt converts a row number coming either from an in-process result (int)
+// or from decoded JSON (float64) into an int
+func toInt(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case int64:
+		return int(n), true
+	case float64:
+		return int(n), true
+	case json.Number:
+		i, err := n.Int64()
+		return int(i), err == nil
+	case string:
+		i, err := strconv.Atoi(strings.TrimSpace(n))
+		return i, err == nil
+	}
+	return 0, false
+}
+
+// optionalInt reads an optional integer parameter from a decoded JSON request, 0 if absent
+func optionalInt(params map[string]interface{}, key string) int {
+	n, _ := toInt(params[key])
+	return n
+}
+
+// templateCellValue keeps numbers numeric and writes everything else as text
+func templateCellValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case json.Number:
+		if f, err := val.Float64(); err == nil {
+			return f
+		}
+		return val.String()
+	case int, int64, float64:
+		return val
+	case string:
+		return val
+	default:
+		return fmt.Sprintf("%v", val)
+	}
+}
+
+// ExportToExcel exports data to Excel file. Columns are written in the order of req.Columns
+// (or the sorted data keys) with a styled, frozen header row and per-column number formats.
 func (s *ExcelService) ExportToExcel(req models.ExportRequest) (string, error) {
 	f := excelize.NewFile()
 	defer f.Close()
@@ -533,24 +784,62 @@ func (s *ExcelService) ExportToExcel(req models.ExportRequest) (string, error) {
 		return "", fmt.Errorf("no data to export")
 	}
 
-	// Write headers
-	headers := make([]string, 0)
-	for key := range req.Data[0] {
-		headers = append(headers, key)
+	columns := ResolveExportColumns(req.Columns, req.Data)
+	if err := writeStyledSheet(f, sheetName, columns, s.exportRows(columns, req.Data), req.FreezeColumns); err != nil {
+		return "", err
+	}
+
+	// Create exports directory if not exists
+	exportsDir := "exports"
+	if err := os.MkdirAll(exportsDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create exports directory: %w", err)
+	}
+
+	// Save file
+	outputPath := fmt.Sprintf("%s/export_%d.xlsx", exportsDir, time.Now().Unix())
+	if err := f.SaveAs(outputPath); err != nil {
+		return "", fmt.Errorf("failed to save file: %w", err)
 	}
 
-	for i, header := range headers {
-		cell := fmt.Sprintf("%c1", 'A'+i)
-		f.SetCellValue(sheetName, cell, header)
+	return outputPath, nil
+}
+
+// ExportWorkbook exports several sheets into one workbook, each with its own column spec.
+// Sheets without data get a header row only.
+func (s *ExcelService) ExportWorkbook(req models.WorkbookExportRequest) (string, error) {
+	if len(req.Sheets) == 0 {
+		return "", fmt.Errorf("no sheets to export")
 	}
 
-	// Write data
-	for rowIndex, row := range req.Data {
-		for colIndex, header := range headers {
-			cell := fmt.Sprintf("%c%d", 'A'+colIndex, rowIndex+2)
-			f.SetCellValue(sheetName, cell, row[header])
+	f := excelize.NewFile()
+	defer f.Close()
+
+	seen := make(map[string]bool)
+	for i, sheet := range req.Sheets {
+		// Excel compares sheet names case-insensitively
+		key := strings.ToLower(sheet.Name)
+		if seen[key] {
+			return "", fmt.Errorf("duplicate sheet name %s", sheet.Name)
+		}
+		seen[key] = true
+
+		if i == 0 {
+			if err := f.SetSheetName("Sheet1", sheet.Name); err != nil {
+				return "", fmt.Errorf("invalid sheet name %s: %w", sheet.Name, err)
+			}
+		} else if _, err := f.NewSheet(sheet.Name); err != nil {
+			return "", fmt.Errorf("invalid sheet name %s: %w", sheet.Name, err)
+		}
+
+		columns := ResolveExportColumns(sheet.Columns, sheet.Data)
+		if len(columns) == 0 {
+			return "", fmt.Errorf("sheet %s has neither columns nor data", sheet.Name)
+		}
+		if err := writeStyledSheet(f, sheet.Name, columns, s.exportRows(columns, sheet.Data), sheet.FreezeColumns); err != nil {
+			return "", fmt.Errorf("sheet %s: %w", sheet.Name, err)
 		}
 	}
+	f.SetActiveSheet(0)
 
 	// Create exports directory if not exists
 	exportsDir := "exports"
@@ -559,7 +848,7 @@ func (s *ExcelService) ExportToExcel(req models.ExportRequest) (string, error) {
 	}
 
 	// Save file
-	outputPath := fmt.Sprintf("%s/export_%d.xlsx", exportsDir, time.Now().Unix())
+	outputPath := fmt.Sprintf("%s/workbook_%d.xlsx", exportsDir, time.Now().UnixNano())
 	if err := f.SaveAs(outputPath); err != nil {
 		return "", fmt.Errorf("failed to save file: %w", err)
 	}
@@ -609,6 +898,12 @@ func (s *ExcelService) MergeDataToTemplate(templatePath string, mergeData map[st
 		return "", fmt.Errorf("calculated data not found")
 	}
 
+	// Grow the template block when the data exceeds its pre-formatted rows
+	lastRow := int(startRow) + len(calculatedData) - 1
+	if _, err := s.prepareTemplateRows(f, templateSheet, optionalInt(mergeData, "prototypeRow"), optionalInt(mergeData, "templateEndRow"), lastRow); err != nil {
+		return "", fmt.Errorf("failed to extend template rows: %v", err)
+	}
+
 	// Insert calculated values into template
 	for i, data := range calculatedData {
 		if dataMap, ok := data.(map[string]interface{}); ok {
@@ -668,6 +963,24 @@ func (s *ExcelService) MergeMultipleColumnsToTemplate(templatePath string, merge
 		return s.MergeDataToTemplate(templatePath, mergeData)
 	}
 
+	// Grow the template block when the data exceeds its pre-formatted rows
+	lastRow := 0
+	for _, columnData := range mergeDataArray {
+		if columnMap, ok := columnData.(map[string]interface{}); ok {
+			calculatedData, _ := columnMap["calculatedData"].([]interface{})
+			for _, data := range calculatedData {
+				if dataMap, ok := data.(map[string]interface{}); ok {
+					if targetRow, ok := toInt(dataMap["targetRow"]); ok && targetRow > lastRow {
+						lastRow = targetRow
+					}
+				}
+			}
+		}
+	}
+	if _, err := s.prepareTemplateRows(f, templateSheet, optionalInt(mergeData, "prototypeRow"), optionalInt(mergeData, "templateEndRow"), lastRow); err != nil {
+		return "", fmt.Errorf("failed to extend template rows: %v", err)
+	}
+
 	// Process each column mapping
 	for _, columnData := range mergeDataArray {
 		if columnMap, ok := columnData.(map[string]interface{}); ok {
@@ -0,0 +1,213 @@
+package services
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+
+	"excel-processor/internal/models"
+)
+
+func TestPrepareUploadConvertsODS(t *testing.T) {
+	dir := t.TempDir()
+	xlsxPath := filepath.Join(dir, "source.xlsx")
+	f := excelize.NewFile()
+	f.SetCellValue("Sheet1", "A1", "value")
+	if err := f.SaveAs(xlsxPath); err != nil {
+		t.Fatalf("SaveAs returned %v", err)
+	}
+	f.Close()
+
+	s := NewExcelService()
+	odsPath, err := s.ConvertXLSXToODS(xlsxPath)
+	if err != nil {
+		t.Fatalf("ConvertXLSXToODS returned %v", err)
+	}
+	os.Remove(xlsxPath)
+
+	stored, err := s.PrepareUpload(odsPath)
+	if err != nil {
+		t.Fatalf("PrepareUpload returned %v", err)
+	}
+	if stored != xlsxPath {
+		t.Errorf("PrepareUpload = %s, want %s", stored, xlsxPath)
+	}
+	if _, err := os.Stat(stored); err != nil {
+		t.Errorf("converted file missing: %v", err)
+	}
+	if _, err := os.Stat(odsPath); !os.IsNotExist(err) {
+		t.Errorf("original %s was kept after conversion", odsPath)
+	}
+}
+
+func TestPrepareUploadRejectsUnreadable(t *testing.T) {
+	for _, name := range []string{"broken.xls", "broken.ods", "broken.xlsx"} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, name)
+			if err := os.WriteFile(path, []byte("not a spreadsheet"), 0644); err != nil {
+				t.Fatalf("WriteFile returned %v", err)
+			}
+			if _, err := NewExcelService().PrepareUpload(path); err == nil {
+				t.Fatalf("PrepareUpload(%s) accepted an unreadable file", name)
+			}
+
+			entries, _ := os.ReadDir(dir)
+			if len(entries) != 1 || entries[0].Name() != name {
+				t.Errorf("directory holds %v, want only the original upload", entries)
+			}
+		})
+	}
+}
+
+func TestDataRange(t *testing.T) {
+	intPtr := func(v int) *int { return &v }
+	// Header on row 1, data on rows 2-10, totals on row 11 and notes below, 1-based
+	region := &models.DataRegion{HeaderRow: 1, FirstDataRow: 2, LastDataRow: 10, TotalRows: []int{11}}
+	tests := []struct {
+		name      string
+		region    *models.DataRegion
+		start     *int
+		end       *int
+		wantStart int
+		wantEnd   int
+	}{
+		{"detected table", region, nil, nil, 1, 9},
+		{"explicit start runs to the end of the sheet", region, intPtr(3), nil, 3, 14},
+		{"explicit start at the first row", region, intPtr(0), nil, 0, 14},
+		{"explicit end with detected start", region, nil, intPtr(12), 1, 12},
+		{"explicit start and end", region, intPtr(4), intPtr(6), 4, 6},
+		{"no region", nil, nil, nil, 0, 14},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end := dataRange(tt.region, tt.start, tt.end, 15)
+			if start != tt.wantStart || end != tt.wantEnd {
+				t.Errorf("dataRange() = %d, %d, want %d, %d", start, end, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestExportRowCalculationToTemplate(t *testing.T) {
+	t.Chdir(t.TempDir())
+	templatePath := "template.xlsx"
+	f := excelize.NewFile()
+	f.NewSheet("Report")
+	if err := f.SaveAs(templatePath); err != nil {
+		t.Fatalf("SaveAs returned %v", err)
+	}
+	f.Close()
+
+	// Row numbers arrive as int from CalculateRowWise and as json.Number from clients
+	req := models.TemplateExportRequest{
+		CalculationResult: models.RowCalculationResult{
+			TargetColumn: "C",
+			Results: []map[string]interface{}{
+				{"row_number": 2, "calculated_value": 1500.5},
+				{"row_number": json.Number("3"), "calculated_value": "Hà Nội"},
+				{"row_number": 4.0, "calculated_value": nil},
+			},
+		},
+		TemplateSheet: "Report",
+		RowOffset:     5,
+	}
+	outputPath, err := NewExcelService().ExportRowCalculationToTemplate(templatePath, req)
+	if err != nil {
+		t.Fatalf("ExportRowCalculationToTemplate returned %v", err)
+	}
+
+	out, err := excelize.OpenFile(outputPath)
+	if err != nil {
+		t.Fatalf("OpenFile returned %v", err)
+	}
+	defer out.Close()
+	for cell, want := range map[string]string{"C7": "1500.5", "C8": "Hà Nội", "C9": ""} {
+		if got, _ := out.GetCellValue("Report", cell); got != want {
+			t.Errorf("Report!%s = %q, want %q", cell, got, want)
+		}
+	}
+	if got, _ := out.GetCellValue("Sheet1", "C7"); got != "" {
+		t.Errorf("Sheet1!C7 = %q, want the first sheet untouched", got)
+	}
+
+	req.TemplateSheet = "Missing"
+	if _, err := NewExcelService().ExportRowCalculationToTemplate(templatePath, req); err == nil {
+		t.Error("ExportRowCalculationToTemplate accepted a missing sheet")
+	}
+	req.TemplateSheet, req.RowOffset = "", -2
+	if _, err := NewExcelService().ExportRowCalculationToTemplate(templatePath, req); err == nil {
+		t.Error("ExportRowCalculationToTemplate accepted a row above the sheet")
+	}
+}
+
+func TestExportWorkbook(t *testing.T) {
+	t.Chdir(t.TempDir())
+	req := models.WorkbookExportRequest{Sheets: []models.ExportSheet{
+		{
+			Name: "Dữ liệu",
+			Data: []map[string]interface{}{
+				{"name": "An", "amount": "1,500,000"},
+				{"name": "Bình", "amount": json.Number("2000000")},
+			},
+			Columns: []models.ExportColumn{
+				{Key: "name", Header: "Họ tên"},
+				{Key: "amount", Header: "Số tiền", Type: "number"},
+			},
+			FreezeColumns: 1,
+		},
+		{Name: "Tổng hợp", Data: []map[string]interface{}{{"total": 3500000.0, "count": 2}}},
+		{Name: "Lỗi", Columns: []models.ExportColumn{{Key: "row"}, {Key: "message", Header: "Lỗi"}}},
+	}}
+
+	outputPath, err := NewExcelService().ExportWorkbook(req)
+	if err != nil {
+		t.Fatalf("ExportWorkbook returned %v", err)
+	}
+	f, err := excelize.OpenFile(outputPath)
+	if err != nil {
+		t.Fatalf("OpenFile returned %v", err)
+	}
+	defer f.Close()
+
+	if sheets := f.GetSheetList(); !slices.Equal(sheets, []string{"Dữ liệu", "Tổng hợp", "Lỗi"}) {
+		t.Errorf("sheets = %v, want Dữ liệu, Tổng hợp, Lỗi", sheets)
+	}
+	want := map[string][][]string{
+		"Dữ liệu":  {{"Họ tên", "Số tiền"}, {"An", "1500000"}, {"Bình", "2000000"}},
+		"Tổng hợp": {{"count", "total"}, {"2", "3500000"}},
+		"Lỗi":      {{"row", "Lỗi"}},
+	}
+	for sheet, wantRows := range want {
+		rows, err := f.GetRows(sheet, excelize.Options{RawCellValue: true})
+		if err != nil {
+			t.Fatalf("GetRows(%s) returned %v", sheet, err)
+		}
+		if len(rows) != len(wantRows) {
+			t.Errorf("%s has %d rows, want %d: %q", sheet, len(rows), len(wantRows), rows)
+			continue
+		}
+		for i := range wantRows {
+			if !slices.Equal(rows[i], wantRows[i]) {
+				t.Errorf("%s row %d = %q, want %q", sheet, i+1, rows[i], wantRows[i])
+			}
+		}
+	}
+	if panes, _ := f.GetPanes("Dữ liệu"); !panes.Freeze || panes.XSplit != 1 || panes.YSplit != 1 {
+		t.Errorf("Dữ liệu panes = %+v, want the header row and first column frozen", panes)
+	}
+
+	for name, sheets := range map[string][]models.ExportSheet{
+		"duplicate name":           {{Name: "Data", Columns: []models.ExportColumn{{Key: "a"}}}, {Name: "DATA", Columns: []models.ExportColumn{{Key: "a"}}}},
+		"neither columns nor data": {{Name: "Empty"}},
+		"no sheets":                nil,
+	} {
+		if _, err := NewExcelService().ExportWorkbook(models.WorkbookExportRequest{Sheets: sheets}); err == nil {
+			t.Errorf("ExportWorkbook accepted %s", name)
+		}
+	}
+}
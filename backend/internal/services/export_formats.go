@@ -0,0 +1,349 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+	"excel-processor/internal/models"
+)
+
+// exportFormats maps supported output formats to their content types
+var exportFormats = map[string]string{
+	"xlsx":   "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	"ods":    odsMimeType,
+	"csv":    "text/csv; charset=utf-8",
+	"json":   "application/json",
+	"ndjson": "application/x-ndjson",
+	"pdf":    "application/pdf",
+}
+
+// utf8BOM makes Excel open UTF-8 CSV files with Vietnamese text correctly
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// ExportTable is a format-neutral table written by the export writers
+type ExportTable struct {
+	Columns []string
+	Rows    [][]interface{}
+}
+
+// IsExportFormat reports whether format is a supported output format
+func IsExportFormat(format string) bool {
+	_, ok := exportFormats[strings.ToLower(format)]
+	return ok
+}
+
+// ExportContentType returns the HTTP content type of an output format
+func ExportContentType(format string) string {
+	return exportFormats[strings.ToLower(format)]
+}
+
+// TableFromMaps builds an export table from result rows, taking the columns in the given order
+func TableFromMaps(columns []string, data []map[string]interface{}) ExportTable {
+	table := ExportTable{Columns: columns, Rows: make([][]interface{}, 0, len(data))}
+	for _, item := range data {
+		row := make([]interface{}, len(columns))
+		for i, column := range columns {
+			row[i] = item[column]
+		}
+		table.Rows = append(table.Rows, row)
+	}
+	return table
+}
+
+// DataColumns returns the keys used across data rows in sorted order: column letters in
+// sheet order (A, B, ..., Z, AA), then any other keys in text order
+func DataColumns(data []map[string]interface{}) []string {
+	numbers := make(map[string]int)
+	columns := make([]string, 0)
+	for _, row := range data {
+		for key := range row {
+			if _, seen := numbers[key]; !seen {
+				numbers[key] = columnKeyNumber(key)
+				columns = append(columns, key)
+			}
+		}
+	}
+	sort.Slice(columns, func(i, j int) bool {
+		a, b := numbers[columns[i]], numbers[columns[j]]
+		if (a > 0) != (b > 0) {
+			return a > 0
+		}
+		if a > 0 {
+			return a < b
+		}
+		return columns[i] < columns[j]
+	})
+	return columns
+}
+
+// columnKeyNumber returns the column number of a key written as upper-case column letters,
+// 0 for any other key
+func columnKeyNumber(key string) int {
+	if key == "" || strings.ToUpper(key) != key {
+		return 0
+	}
+	number, err := excelize.ColumnNameToNumber(key)
+	if err != nil {
+		return 0
+	}
+	return number
+}
+
+// RowCalculationTable lays out row-wise results as row number, source columns and result
+func RowCalculationTable(result *models.RowCalculationResult) ExportTable {
+	columns := []string{"row_number"}
+	for _, column := range result.SourceColumns {
+		if column != result.TargetColumn {
+			columns = append(columns, column)
+		}
+	}
+	columns = append(columns, result.TargetColumn)
+	return TableFromMaps(columns, result.Results)
+}
+
+// ConvertExport converts an exported .xlsx file into the requested output format and
+// returns the path of the converted file. The intermediate .xlsx is removed.
+// Tabular formats (csv, json, ndjson) contain the first sheet keyed by column letter.
+// PDF output needs page options and is produced by RenderPDF instead.
+func (s *ExcelService) ConvertExport(xlsxPath, format string) (string, error) {
+	var (
+		outputPath string
+		err        error
+	)
+
+	switch format = strings.ToLower(format); format {
+	case "", "xlsx":
+		return xlsxPath, nil
+	case "ods":
+		outputPath, err = s.ConvertXLSXToODS(xlsxPath)
+	case "csv", "json", "ndjson":
+		var table ExportTable
+		if table, err = s.sheetTable(xlsxPath); err == nil {
+			outputPath, err = s.WriteExportTable(table, format, "export")
+		}
+	default:
+		return "", fmt.Errorf("unsupported export format: %s", format)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to convert export to %s: %w", format, err)
+	}
+
+	os.Remove(xlsxPath)
+	return outputPath, nil
+}
+
+// sheetTable reads the first sheet of a workbook as a table with a "row" column followed by
+// one column per sheet column letter. Formulas are recalculated.
+func (s *ExcelService) sheetTable(xlsxPath string) (ExportTable, error) {
+	f, err := excelize.OpenFile(xlsxPath)
+	if err != nil {
+		return ExportTable{}, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	sheet := f.GetSheetList()[0]
+	rows, err := f.GetRows(sheet)
+	if err != nil {
+		return ExportTable{}, fmt.Errorf("failed to read sheet %s: %w", sheet, err)
+	}
+
+	maxCol := 0
+	for _, row := range rows {
+		if len(row) > maxCol {
+			maxCol = len(row)
+		}
+	}
+
+	table := ExportTable{Columns: []string{"row"}}
+	for col := 1; col <= maxCol; col++ {
+		name, _ := excelize.ColumnNumberToName(col)
+		table.Columns = append(table.Columns, name)
+	}
+
+	for i, row := range rows {
+		values := make([]interface{}, maxCol+1)
+		values[0] = i + 1
+		for j, display := range row {
+			cell, _ := excelize.CoordinatesToCellName(j+1, i+1)
+			text, number, isNumber := typedCellValue(f, sheet, cell, display)
+			switch {
+			case isNumber:
+				values[j+1] = number
+			case text != "":
+				values[j+1] = text
+			}
+		}
+		table.Rows = append(table.Rows, values)
+	}
+	return table, nil
+}
+
+// WriteExportTable writes a table in the given format to the exports directory
+func (s *ExcelService) WriteExportTable(table ExportTable, format, baseName string) (string, error) {
+	format = strings.ToLower(format)
+	if !IsExportFormat(format) {
+		return "", fmt.Errorf("unsupported export format: %s", format)
+	}
+
+	// Create exports directory if not exists
+	if err := os.MkdirAll("exports", 0755); err != nil {
+		return "", fmt.Errorf("failed to create exports directory: %w", err)
+	}
+	outputPath := fmt.Sprintf("exports/%s_%d.%s", baseName, time.Now().UnixNano(), format)
+
+	var err error
+	switch format {
+	case "csv":
+		err = writeCSVTable(table, outputPath)
+	case "json":
+		err = writeJSONTable(table, outputPath, false)
+	case "ndjson":
+		err = writeJSONTable(table, outputPath, true)
+	case "xlsx", "ods":
+		xlsxPath := strings.TrimSuffix(outputPath, "."+format) + ".xlsx"
+		if err = writeXLSXTable(table, xlsxPath); err == nil && format == "ods" {
+			outputPath, err = s.ConvertExport(xlsxPath, format)
+		}
+	case "pdf":
+		xlsxPath := strings.TrimSuffix(outputPath, ".pdf") + ".xlsx"
+		if err = writeXLSXTable(table, xlsxPath); err == nil {
+			var pdfPath string
+			if pdfPath, err = s.RenderPDF(xlsxPath, models.PDFOptions{HeaderRows: 1, Gridlines: true}); err == nil {
+				err = os.Rename(pdfPath, outputPath)
+			}
+			os.Remove(xlsxPath)
+		}
+	}
+	if err != nil {
+		os.Remove(outputPath)
+		return "", err
+	}
+	return outputPath, nil
+}
+
+// writeCSVTable writes a UTF-8 CSV with BOM in Vietnamese convention: fields separated by
+// semicolons and a decimal comma, which is what Excel with vi-VN regional settings expects
+func writeCSVTable(table ExportTable, outputPath string) error {
+	var buf bytes.Buffer
+	buf.Write(utf8BOM)
+
+	w := csv.NewWriter(&buf)
+	w.Comma = ';'
+	w.UseCRLF = true
+	if err := w.Write(table.Columns); err != nil {
+		return fmt.Errorf("failed to write csv header: %w", err)
+	}
+	for _, row := range table.Rows {
+		record := make([]string, len(row))
+		for i, value := range row {
+			record[i] = formatCSVValue(value)
+		}
+		if err := w.Write(record); err != nil {
+			return fmt.Errorf("failed to write csv row: %w", err)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed to write csv: %w", err)
+	}
+
+	return os.WriteFile(outputPath, buf.Bytes(), 0644)
+}
+
+// formatCSVValue renders a value for CSV, numbers with a decimal comma and no exponent
+func formatCSVValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strings.Replace(strconv.FormatFloat(v, 'f', -1, 64), ".", ",", 1)
+	case float32:
+		return strings.Replace(strconv.FormatFloat(float64(v), 'f', -1, 32), ".", ",", 1)
+	case int:
+		return strconv.Itoa(v)
+	case time.Time:
+		return v.Format("02/01/2006")
+	case string:
+		return v
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// writeJSONTable writes the rows as a JSON array of objects, or one object per line for NDJSON.
+// Object keys keep the table's column order.
+func writeJSONTable(table ExportTable, outputPath string, lines bool) error {
+	var buf bytes.Buffer
+	if !lines {
+		buf.WriteString("[")
+	}
+	for i, row := range table.Rows {
+		if !lines && i > 0 {
+			buf.WriteString(",")
+		}
+		if !lines {
+			buf.WriteString("\n  ")
+		}
+		if err := writeJSONObject(&buf, table.Columns, row); err != nil {
+			return err
+		}
+		if lines {
+			buf.WriteString("\n")
+		}
+	}
+	if !lines {
+		buf.WriteString("\n]\n")
+	}
+
+	return os.WriteFile(outputPath, buf.Bytes(), 0644)
+}
+
+// writeJSONObject writes one row as a JSON object with keys in column order
+func writeJSONObject(buf *bytes.Buffer, columns []string, row []interface{}) error {
+	buf.WriteString("{")
+	for i, column := range columns {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		key, _ := json.Marshal(column)
+		value, err := json.Marshal(row[i])
+		if err != nil {
+			return fmt.Errorf("failed to encode %s: %w", column, err)
+		}
+		buf.Write(key)
+		buf.WriteString(":")
+		buf.Write(value)
+	}
+	buf.WriteString("}")
+	return nil
+}
+
+// writeXLSXTable writes a table to a single-sheet workbook with a header row
+func writeXLSXTable(table ExportTable, outputPath string) error {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	sheetName := "Export"
+	f.SetSheetName("Sheet1", sheetName)
+
+	columns := make([]models.ExportColumn, len(table.Columns))
+	for i, column := range table.Columns {
+		columns[i] = models.ExportColumn{Key: column}
+	}
+	if err := writeStyledSheet(f, sheetName, columns, table.Rows, 0); err != nil {
+		return err
+	}
+
+	if err := f.SaveAs(outputPath); err != nil {
+		return fmt.Errorf("failed to save file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package services
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDataColumns(t *testing.T) {
+	tests := []struct {
+		name string
+		keys []string
+		want []string
+	}{
+		{"letters past Z", []string{"AB", "B", "AA", "Z", "A"}, []string{"A", "B", "Z", "AA", "AB"}},
+		{"three letters", []string{"AAA", "ZZ", "C"}, []string{"C", "ZZ", "AAA"}},
+		{"other keys after letters", []string{"row_number", "name", "AA", "B"}, []string{"B", "AA", "name", "row_number"}},
+		{"lower case keys are not letters", []string{"id", "C"}, []string{"C", "id"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row := make(map[string]interface{})
+			for _, key := range tt.keys {
+				row[key] = 1
+			}
+			if got := DataColumns([]map[string]interface{}{row}); !slices.Equal(got, tt.want) {
+				t.Errorf("DataColumns(%v) = %v, want %v", tt.keys, got, tt.want)
+			}
+		})
+	}
+}
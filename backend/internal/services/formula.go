@@ -0,0 +1,312 @@
+package services
+
+import (
+	"fmt"
+	"math/big"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+
+	"excel-processor/internal/models"
+)
+
+// FormulaError reports a formula that cannot be parsed or a function called with values it
+// cannot use
+type FormulaError struct {
+	Message string
+}
+
+func (e *FormulaError) Error() string {
+	return e.Message
+}
+
+// formulaErrorf returns a *FormulaError
+func formulaErrorf(format string, args ...interface{}) error {
+	return &FormulaError{Message: fmt.Sprintf(format, args...)}
+}
+
+// Formula is a parsed row formula over the columns of a sheet, e.g.
+// "PIT(AI - FAMILY_DEDUCTION(AK) - AE)". Formulas support + - * /, parentheses, numbers,
+// column letters and the functions in formulaFunctions, and are calculated exactly.
+type Formula struct {
+	Text    string
+	Columns []string // Columns the formula reads, in order of first use
+
+	root     formulaNode
+	taxTable bool // Calls a function that needs a tax table
+}
+
+// formulaContext holds the values of one row while a formula is evaluated
+type formulaContext struct {
+	values       map[string]*big.Rat
+	table        *models.TaxTable
+	divideByZero bool // A divisor was 0 and was left out
+}
+
+type formulaNode interface {
+	eval(ctx *formulaContext) (*big.Rat, error)
+}
+
+type numberNode struct{ value *big.Rat }
+
+type columnNode struct{ column string }
+
+type negateNode struct{ operand formulaNode }
+
+type binaryNode struct {
+	op          rune
+	left, right formulaNode
+}
+
+type callNode struct {
+	name string
+	args []formulaNode
+}
+
+func (n numberNode) eval(*formulaContext) (*big.Rat, error) {
+	return n.value, nil
+}
+
+func (n columnNode) eval(ctx *formulaContext) (*big.Rat, error) {
+	if value, ok := ctx.values[n.column]; ok {
+		return value, nil
+	}
+	return new(big.Rat), nil
+}
+
+func (n negateNode) eval(ctx *formulaContext) (*big.Rat, error) {
+	value, err := n.operand.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Rat).Neg(value), nil
+}
+
+func (n binaryNode) eval(ctx *formulaContext) (*big.Rat, error) {
+	left, err := n.left.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := new(big.Rat)
+	switch n.op {
+	case '+':
+		return result.Add(left, right), nil
+	case '-':
+		return result.Sub(left, right), nil
+	case '*':
+		return result.Mul(left, right), nil
+	}
+	// The zero divisor is left out, which is what the skip_divisor policy keeps
+	if right.Sign() == 0 {
+		ctx.divideByZero = true
+		return result.Set(left), nil
+	}
+	return result.Quo(left, right), nil
+}
+
+func (n callNode) eval(ctx *formulaContext) (*big.Rat, error) {
+	args := make([]*big.Rat, len(n.args))
+	for i, arg := range n.args {
+		value, err := arg.eval(ctx)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = value
+	}
+	return formulaFunctions[n.name].call(ctx.table, args)
+}
+
+// ParseFormula parses a row formula. Column letters are case-insensitive.
+func ParseFormula(text string) (*Formula, error) {
+	p := &formulaParser{input: []rune(text)}
+	formula := &Formula{Text: strings.TrimSpace(text)}
+	p.formula = formula
+
+	root, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpaces()
+	if p.pos < len(p.input) {
+		return nil, formulaErrorf("formula: unexpected %q at position %d", string(p.input[p.pos]), p.pos+1)
+	}
+	formula.root = root
+	return formula, nil
+}
+
+// UsesTaxTable reports whether the formula calls a PIT or insurance function
+func (f *Formula) UsesTaxTable() bool {
+	return f.taxTable
+}
+
+// evaluate calculates the formula for one row. It reports false when a divisor was 0.
+func (f *Formula) evaluate(values map[string]*big.Rat, table *models.TaxTable) (*big.Rat, bool, error) {
+	ctx := &formulaContext{values: values, table: table}
+	value, err := f.root.eval(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	return value, !ctx.divideByZero, nil
+}
+
+// formulaParser is a recursive descent parser for row formulas
+type formulaParser struct {
+	input   []rune
+	pos     int
+	formula *Formula
+}
+
+func (p *formulaParser) skipSpaces() {
+	for p.pos < len(p.input) && unicode.IsSpace(p.input[p.pos]) {
+		p.pos++
+	}
+}
+
+// peek returns the next non-space character, 0 at the end
+func (p *formulaParser) peek() rune {
+	p.skipSpaces()
+	if p.pos < len(p.input) {
+		return p.input[p.pos]
+	}
+	return 0
+}
+
+// parseExpression parses terms joined by + and -
+func (p *formulaParser) parseExpression() (formulaNode, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for op := p.peek(); op == '+' || op == '-'; op = p.peek() {
+		p.pos++
+		right, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+// parseTerm parses factors joined by * and /
+func (p *formulaParser) parseTerm() (formulaNode, error) {
+	left, err := p.parseFactor()
+	if err != nil {
+		return nil, err
+	}
+	for op := p.peek(); op == '*' || op == '/'; op = p.peek() {
+		p.pos++
+		right, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+// parseFactor parses a number, column, function call, negation or parenthesized expression
+func (p *formulaParser) parseFactor() (formulaNode, error) {
+	switch c := p.peek(); {
+	case c == 0:
+		return nil, formulaErrorf("formula: unexpected end")
+	case c == '-':
+		p.pos++
+		operand, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		return negateNode{operand: operand}, nil
+	case c == '(':
+		p.pos++
+		node, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ')' {
+			return nil, formulaErrorf("formula: missing )")
+		}
+		p.pos++
+		return node, nil
+	case unicode.IsDigit(c) || c == '.':
+		start := p.pos
+		for p.pos < len(p.input) && (unicode.IsDigit(p.input[p.pos]) || p.input[p.pos] == '.') {
+			p.pos++
+		}
+		value, ok := new(big.Rat).SetString(string(p.input[start:p.pos]))
+		if !ok {
+			return nil, formulaErrorf("formula: invalid number %q", string(p.input[start:p.pos]))
+		}
+		return numberNode{value: value}, nil
+	case unicode.IsLetter(c):
+		start := p.pos
+		for p.pos < len(p.input) && (unicode.IsLetter(p.input[p.pos]) || unicode.IsDigit(p.input[p.pos]) || p.input[p.pos] == '_') {
+			p.pos++
+		}
+		name := strings.ToUpper(string(p.input[start:p.pos]))
+		if p.peek() == '(' {
+			return p.parseCall(name)
+		}
+		if err := validateColumns(name); err != nil {
+			return nil, formulaErrorf("formula: %q is not a column", name)
+		}
+		if !slices.Contains(p.formula.Columns, name) {
+			p.formula.Columns = append(p.formula.Columns, name)
+		}
+		return columnNode{column: name}, nil
+	default:
+		return nil, formulaErrorf("formula: unexpected %q at position %d", string(c), p.pos+1)
+	}
+}
+
+// parseCall parses the arguments of a function call; the name has been read
+func (p *formulaParser) parseCall(name string) (formulaNode, error) {
+	function, ok := formulaFunctions[name]
+	if !ok {
+		return nil, formulaErrorf("formula: unknown function %s (available: %s)", name, strings.Join(FormulaFunctionNames(), ", "))
+	}
+	p.pos++ // (
+
+	var args []formulaNode
+	if p.peek() != ')' {
+		for {
+			arg, err := p.parseExpression()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if p.peek() != ',' {
+				break
+			}
+			p.pos++
+		}
+	}
+	if p.peek() != ')' {
+		return nil, formulaErrorf("formula: missing ) after the arguments of %s", name)
+	}
+	p.pos++
+
+	if len(args) < function.minArgs || len(args) > function.maxArgs {
+		return nil, formulaErrorf("formula: %s takes %s", name, function.usage)
+	}
+	if function.taxTable {
+		p.formula.taxTable = true
+	}
+	return callNode{name: name, args: args}, nil
+}
+
+// FormulaFunctionNames lists the functions formulas can call
+func FormulaFunctionNames() []string {
+	names := make([]string, 0, len(formulaFunctions))
+	for name := range formulaFunctions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"errors"
+	"math/big"
+	"slices"
+	"testing"
+)
+
+func TestParseFormula(t *testing.T) {
+	table := DefaultTaxTable()
+	values := map[string]*big.Rat{
+		"A":  big.NewRat(50000000, 1),
+		"B":  big.NewRat(1, 1),
+		"C":  big.NewRat(0, 1),
+		"AE": big.NewRat(1, 10),
+	}
+	tests := []struct {
+		formula      string
+		columns      []string
+		usesTaxTable bool
+		want         string
+		divideByZero bool
+	}{
+		{"1 + 2 * 3", nil, false, "7", false},
+		{"(1 + 2) * 3", nil, false, "9", false},
+		{"-A + 1", []string{"A"}, false, "-49999999", false},
+		{"ae + AE + 0.2", []string{"AE"}, false, "0.4", false},
+		{"A / 3", []string{"A"}, false, "16666666.666667", false},
+		{"A / C", []string{"A", "C"}, false, "50000000", true},
+		{"MIN(A, B, 7)", []string{"A", "B"}, false, "1", false},
+		{"MAX(A, B)", []string{"A", "B"}, false, "50000000", false},
+		{"ROUND(A / 3, -3)", []string{"A"}, false, "16667000", false},
+		{"ROUND(2.5)", nil, false, "3", false},
+		{"FAMILY_DEDUCTION(B)", []string{"B"}, true, "15400000", false},
+		{"SELF_DEDUCTION() + DEPENDANT_DEDUCTION(2)", nil, true, "19800000", false},
+		{"PIT(A - INSURANCE(A) - FAMILY_DEDUCTION(C))", []string{"A", "C"}, true, "5263500", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.formula, func(t *testing.T) {
+			formula, err := ParseFormula(tt.formula)
+			if err != nil {
+				t.Fatalf("ParseFormula(%q) returned %v", tt.formula, err)
+			}
+			if !slices.Equal(formula.Columns, tt.columns) {
+				t.Errorf("Columns = %v, want %v", formula.Columns, tt.columns)
+			}
+			if formula.UsesTaxTable() != tt.usesTaxTable {
+				t.Errorf("UsesTaxTable() = %v, want %v", formula.UsesTaxTable(), tt.usesTaxTable)
+			}
+
+			got, ok, err := formula.evaluate(values, &table)
+			if err != nil {
+				t.Fatalf("evaluate returned %v", err)
+			}
+			if ok == tt.divideByZero {
+				t.Errorf("evaluate reported ok = %v, want %v", ok, !tt.divideByZero)
+			}
+			if got.FloatString(6) != rat(t, tt.want).FloatString(6) {
+				t.Errorf("evaluate = %s, want %s", got.FloatString(6), tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFormulaErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"A +",
+		"A +* B",
+		"(A + B",
+		"A B",
+		"PIT(A",
+		"PIT(A, B)",
+		"PIT()",
+		"FOO(A)",
+		"A1 + B",
+		"1..2",
+		"A # B",
+	}
+	for _, text := range tests {
+		t.Run(text, func(t *testing.T) {
+			_, err := ParseFormula(text)
+			var formulaErr *FormulaError
+			if !errors.As(err, &formulaErr) {
+				t.Errorf("ParseFormula(%q) = %v, want a *FormulaError", text, err)
+			}
+		})
+	}
+}
+
+func TestRoundDigits(t *testing.T) {
+	tests := []struct {
+		formula string
+		wantErr bool
+	}{
+		{"ROUND(A, 15)", false},
+		{"ROUND(A, -15)", false},
+		{"ROUND(A, 16)", true},
+		{"ROUND(A, -100000000)", true},
+		{"ROUND(A, 100000000000000000000)", true},
+		{"ROUND(A, 0.5)", true},
+	}
+	values := map[string]*big.Rat{"A": big.NewRat(12345, 100)}
+	for _, tt := range tests {
+		t.Run(tt.formula, func(t *testing.T) {
+			formula, err := ParseFormula(tt.formula)
+			if err != nil {
+				t.Fatalf("ParseFormula(%q) returned %v", tt.formula, err)
+			}
+			_, _, err = formula.evaluate(values, nil)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("evaluate error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
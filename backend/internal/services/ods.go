@@ -0,0 +1,395 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	odsMimeType = "application/vnd.oasis.opendocument.spreadsheet"
+	odsOfficeNS = "urn:oasis:names:tc:opendocument:xmlns:office:1.0"
+	odsTableNS  = "urn:oasis:names:tc:opendocument:xmlns:table:1.0"
+	odsTextNS   = "urn:oasis:names:tc:opendocument:xmlns:text:1.0"
+
+	// odsMaxRepeat caps how often a repeated row/cell with content is expanded.
+	// LibreOffice pads sheets with huge empty repeats, those are skipped instead.
+	odsMaxRepeat = 1000
+)
+
+// odsCell is a cell read from content.xml
+type odsCell struct {
+	Value       interface{}
+	Repeat      int
+	ColsSpanned int
+	RowsSpanned int
+}
+
+// ConvertODSToXLSX reads the cell values of an OpenDocument spreadsheet and writes them
+// into a new .xlsx file next to it. Formatting is not carried over, merged ranges are.
+func (s *ExcelService) ConvertODSToXLSX(odsPath string) (string, error) {
+	f, err := s.readODS(odsPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	outputPath := strings.TrimSuffix(odsPath, filepath.Ext(odsPath)) + ".xlsx"
+	if err := f.SaveAs(outputPath); err != nil {
+		os.Remove(outputPath)
+		return "", fmt.Errorf("failed to save converted file: %w", err)
+	}
+	return outputPath, nil
+}
+
+// ConvertXLSXToODS writes an .ods copy of an .xlsx file and returns its path
+func (s *ExcelService) ConvertXLSXToODS(xlsxPath string) (string, error) {
+	f, err := excelize.OpenFile(xlsxPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	outputPath := strings.TrimSuffix(xlsxPath, filepath.Ext(xlsxPath)) + ".ods"
+	if err := s.SaveAsODS(f, outputPath); err != nil {
+		os.Remove(outputPath)
+		return "", err
+	}
+	return outputPath, nil
+}
+
+// readODS loads an .ods file into an in-memory workbook
+func (s *ExcelService) readODS(odsPath string) (*excelize.File, error) {
+	archive, err := zip.OpenReader(odsPath)
+	if err != nil {
+		return nil, fmt.Errorf("not an OpenDocument spreadsheet: %w", err)
+	}
+	defer archive.Close()
+
+	var content io.ReadCloser
+	for _, file := range archive.File {
+		if file.Name == "content.xml" {
+			if content, err = file.Open(); err != nil {
+				return nil, fmt.Errorf("failed to read content.xml: %w", err)
+			}
+			break
+		}
+	}
+	if content == nil {
+		return nil, fmt.Errorf("not an OpenDocument spreadsheet: content.xml missing")
+	}
+	defer content.Close()
+
+	f := excelize.NewFile()
+	if err := s.parseODSContent(f, content); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if len(f.GetSheetList()) == 0 {
+		f.Close()
+		return nil, fmt.Errorf("spreadsheet has no sheets")
+	}
+	return f, nil
+}
+
+// parseODSContent streams content.xml and writes every table into the workbook
+func (s *ExcelService) parseODSContent(f *excelize.File, r io.Reader) error {
+	decoder := xml.NewDecoder(r)
+
+	var (
+		sheetName  string
+		sheetCount int
+		rowIndex   int
+		rowRepeat  int
+		rowCells   []odsCell
+		cell       *odsCell
+		text       strings.Builder
+		paragraphs int
+	)
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to parse content.xml: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch {
+			case t.Name.Space == odsTableNS && t.Name.Local == "table":
+				sheetName = odsAttr(t, odsTableNS, "name")
+				if sheetName == "" {
+					sheetName = fmt.Sprintf("Sheet%d", sheetCount+1)
+				}
+				if sheetCount == 0 {
+					err = f.SetSheetName("Sheet1", sheetName)
+				} else {
+					_, err = f.NewSheet(sheetName)
+				}
+				if err != nil {
+					return fmt.Errorf("invalid sheet name %s: %w", sheetName, err)
+				}
+				sheetCount++
+				rowIndex = 0
+			case t.Name.Space == odsTableNS && t.Name.Local == "table-row":
+				rowRepeat = odsIntAttr(t, "number-rows-repeated", 1)
+				rowCells = rowCells[:0]
+			case t.Name.Space == odsTableNS && (t.Name.Local == "table-cell" || t.Name.Local == "covered-table-cell"):
+				cell = &odsCell{
+					Repeat:      odsIntAttr(t, "number-columns-repeated", 1),
+					ColsSpanned: odsIntAttr(t, "number-columns-spanned", 1),
+					RowsSpanned: odsIntAttr(t, "number-rows-spanned", 1),
+				}
+				switch odsAttr(t, odsOfficeNS, "value-type") {
+				case "float", "percentage", "currency":
+					if v, err := strconv.ParseFloat(odsAttr(t, odsOfficeNS, "value"), 64); err == nil {
+						cell.Value = v
+					}
+				}
+				text.Reset()
+				paragraphs = 0
+			case cell != nil && t.Name.Space == odsTextNS:
+				switch t.Name.Local {
+				case "p":
+					if paragraphs > 0 {
+						text.WriteString("\n")
+					}
+					paragraphs++
+				case "s":
+					text.WriteString(strings.Repeat(" ", odsIntAttrNS(t, odsTextNS, "c", 1)))
+				case "tab":
+					text.WriteString("\t")
+				case "line-break":
+					text.WriteString("\n")
+				}
+			}
+		case xml.CharData:
+			if cell != nil && paragraphs > 0 {
+				text.Write(t)
+			}
+		case xml.EndElement:
+			switch {
+			case t.Name.Space == odsTableNS && (t.Name.Local == "table-cell" || t.Name.Local == "covered-table-cell"):
+				if cell.Value == nil && text.Len() > 0 {
+					cell.Value = text.String()
+				}
+				rowCells = append(rowCells, *cell)
+				cell = nil
+			case t.Name.Space == odsTableNS && t.Name.Local == "table-row":
+				if !odsRowHasContent(rowCells) {
+					rowIndex += rowRepeat
+					continue
+				}
+				for i := 0; i < rowRepeat && i < odsMaxRepeat; i++ {
+					if err := writeODSRow(f, sheetName, rowIndex+i+1, rowCells); err != nil {
+						return err
+					}
+				}
+				// Rows past the cap are dropped, but the rows after them keep their place
+				rowIndex += rowRepeat
+			}
+		}
+	}
+	return nil
+}
+
+// writeODSRow writes the cells of one parsed row, applying repeats and merged spans
+func writeODSRow(f *excelize.File, sheet string, row int, cells []odsCell) error {
+	col := 1
+	for _, c := range cells {
+		if c.Value == nil {
+			col += c.Repeat
+			continue
+		}
+		for i := 0; i < c.Repeat && i < odsMaxRepeat; i++ {
+			cellName, _ := excelize.CoordinatesToCellName(col+i, row)
+			if err := f.SetCellValue(sheet, cellName, c.Value); err != nil {
+				return fmt.Errorf("failed to write cell %s: %w", cellName, err)
+			}
+			if c.ColsSpanned > 1 || c.RowsSpanned > 1 {
+				endCell, _ := excelize.CoordinatesToCellName(col+i+c.ColsSpanned-1, row+c.RowsSpanned-1)
+				if err := f.MergeCell(sheet, cellName, endCell); err != nil {
+					return fmt.Errorf("failed to merge %s:%s: %w", cellName, endCell, err)
+				}
+			}
+		}
+		col += c.Repeat
+	}
+	return nil
+}
+
+// odsRowHasContent reports whether any cell in the row has a value
+func odsRowHasContent(cells []odsCell) bool {
+	for _, c := range cells {
+		if c.Value != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// odsAttr returns a namespaced attribute value, or "" if absent
+func odsAttr(e xml.StartElement, space, local string) string {
+	for _, attr := range e.Attr {
+		if attr.Name.Space == space && attr.Name.Local == local {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// odsIntAttr returns a positive integer table: attribute, or def if absent
+func odsIntAttr(e xml.StartElement, local string, def int) int {
+	return odsIntAttrNS(e, odsTableNS, local, def)
+}
+
+// odsIntAttrNS returns a positive integer attribute, or def if absent
+func odsIntAttrNS(e xml.StartElement, space, local string, def int) int {
+	if n, err := strconv.Atoi(odsAttr(e, space, local)); err == nil && n > 0 {
+		return n
+	}
+	return def
+}
+
+// SaveAsODS writes the cell values of a workbook as an OpenDocument spreadsheet.
+// Formula cells are written with their calculated values, merged ranges are kept.
+func (s *ExcelService) SaveAsODS(f *excelize.File, outputPath string) error {
+	var content bytes.Buffer
+	content.WriteString(xml.Header)
+	content.WriteString(`<office:document-content xmlns:office="` + odsOfficeNS + `" xmlns:table="` + odsTableNS +
+		`" xmlns:text="` + odsTextNS + `" office:version="1.2"><office:body><office:spreadsheet>`)
+
+	for _, sheet := range f.GetSheetList() {
+		if err := writeODSTable(f, sheet, &content); err != nil {
+			return err
+		}
+	}
+	content.WriteString(`</office:spreadsheet></office:body></office:document-content>`)
+
+	out, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer out.Close()
+
+	zw := zip.NewWriter(out)
+	// The mimetype entry must come first and be stored uncompressed
+	mimetype, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return fmt.Errorf("failed to write ods file: %w", err)
+	}
+	io.WriteString(mimetype, odsMimeType)
+
+	manifest, err := zw.Create("META-INF/manifest.xml")
+	if err != nil {
+		return fmt.Errorf("failed to write ods file: %w", err)
+	}
+	io.WriteString(manifest, xml.Header+`<manifest:manifest xmlns:manifest="urn:oasis:names:tc:opendocument:xmlns:manifest:1.0" manifest:version="1.2">`+
+		`<manifest:file-entry manifest:full-path="/" manifest:version="1.2" manifest:media-type="`+odsMimeType+`"/>`+
+		`<manifest:file-entry manifest:full-path="content.xml" manifest:media-type="text/xml"/>`+
+		`</manifest:manifest>`)
+
+	body, err := zw.Create("content.xml")
+	if err != nil {
+		return fmt.Errorf("failed to write ods file: %w", err)
+	}
+	if _, err := body.Write(content.Bytes()); err != nil {
+		return fmt.Errorf("failed to write ods file: %w", err)
+	}
+
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to write ods file: %w", err)
+	}
+	return nil
+}
+
+// writeODSTable renders one sheet as a table:table element
+func writeODSTable(f *excelize.File, sheet string, w *bytes.Buffer) error {
+	rows, err := f.GetRows(sheet)
+	if err != nil {
+		return fmt.Errorf("failed to read sheet %s: %w", sheet, err)
+	}
+
+	// Index merged ranges by their top-left cell and mark the cells they cover
+	spans := make(map[[2]int][2]int)
+	covered := make(map[[2]int]bool)
+	merges, _ := f.GetMergeCells(sheet)
+	for _, merge := range merges {
+		startCol, startRow, err1 := excelize.CellNameToCoordinates(merge.GetStartAxis())
+		endCol, endRow, err2 := excelize.CellNameToCoordinates(merge.GetEndAxis())
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		spans[[2]int{startCol, startRow}] = [2]int{endCol - startCol + 1, endRow - startRow + 1}
+		for r := startRow; r <= endRow; r++ {
+			for c := startCol; c <= endCol; c++ {
+				if r != startRow || c != startCol {
+					covered[[2]int{c, r}] = true
+				}
+			}
+			for len(rows) < r {
+				rows = append(rows, nil)
+			}
+			for len(rows[r-1]) < endCol {
+				rows[r-1] = append(rows[r-1], "")
+			}
+		}
+	}
+
+	w.WriteString(`<table:table table:name="`)
+	xml.EscapeText(w, []byte(sheet))
+	w.WriteString(`">`)
+
+	for i, row := range rows {
+		rowNumber := i + 1
+		w.WriteString(`<table:table-row>`)
+		if len(row) == 0 {
+			w.WriteString(`<table:table-cell/>`)
+		}
+		for j := range row {
+			colNumber := j + 1
+			if covered[[2]int{colNumber, rowNumber}] {
+				w.WriteString(`<table:covered-table-cell/>`)
+				continue
+			}
+
+			w.WriteString(`<table:table-cell`)
+			if span, ok := spans[[2]int{colNumber, rowNumber}]; ok {
+				fmt.Fprintf(w, ` table:number-columns-spanned="%d" table:number-rows-spanned="%d"`, span[0], span[1])
+			}
+
+			cellName, _ := excelize.CoordinatesToCellName(colNumber, rowNumber)
+			display, number, isNumber := typedCellValue(f, sheet, cellName, row[j])
+			if display == "" {
+				w.WriteString(`/>`)
+				continue
+			}
+			if isNumber {
+				fmt.Fprintf(w, ` office:value-type="float" office:value="%s">`, strconv.FormatFloat(number, 'f', -1, 64))
+			} else {
+				w.WriteString(` office:value-type="string">`)
+			}
+			w.WriteString(`<text:p>`)
+			xml.EscapeText(w, []byte(display))
+			w.WriteString(`</text:p></table:table-cell>`)
+		}
+		w.WriteString(`</table:table-row>`)
+	}
+
+	if len(rows) == 0 {
+		w.WriteString(`<table:table-row><table:table-cell/></table:table-row>`)
+	}
+	w.WriteString(`</table:table>`)
+	return nil
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestParseODSContentRepeats(t *testing.T) {
+	content := `<?xml version="1.0" encoding="UTF-8"?>
+<office:document-content xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" xmlns:table="urn:oasis:names:tc:opendocument:xmlns:table:1.0" xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0">
+<office:body><office:spreadsheet><table:table table:name="Data">
+<table:table-row><table:table-cell><text:p>head</text:p></table:table-cell></table:table-row>
+<table:table-row table:number-rows-repeated="1500"><table:table-cell office:value-type="float" office:value="7"><text:p>7</text:p></table:table-cell></table:table-row>
+<table:table-row><table:table-cell><text:p>after rows</text:p></table:table-cell></table:table-row>
+<table:table-row><table:table-cell table:number-columns-repeated="1200"><text:p>x</text:p></table:table-cell><table:table-cell><text:p>after cells</text:p></table:table-cell></table:table-row>
+</table:table></office:spreadsheet></office:body></office:document-content>`
+
+	f := excelize.NewFile()
+	defer f.Close()
+	if err := NewExcelService().parseODSContent(f, strings.NewReader(content)); err != nil {
+		t.Fatalf("parseODSContent returned %v", err)
+	}
+
+	lastRepeated, _ := excelize.CoordinatesToCellName(1, 1+odsMaxRepeat)
+	pastCap, _ := excelize.CoordinatesToCellName(1, 2+odsMaxRepeat)
+	lastCell, _ := excelize.CoordinatesToCellName(odsMaxRepeat, 1503)
+	afterCells, _ := excelize.CoordinatesToCellName(1201, 1503)
+	tests := []struct {
+		cell string
+		want string
+	}{
+		{"A1", "head"},
+		{"A2", "7"},
+		{lastRepeated, "7"},
+		{pastCap, ""},
+		{"A1502", "after rows"},
+		{"A1503", "x"},
+		{lastCell, "x"},
+		{afterCells, "after cells"},
+	}
+	for _, tt := range tests {
+		got, err := f.GetCellValue("Data", tt.cell)
+		if err != nil {
+			t.Fatalf("GetCellValue(%s) returned %v", tt.cell, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.cell, got, tt.want)
+		}
+	}
+}
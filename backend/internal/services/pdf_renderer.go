@@ -0,0 +1,487 @@
+package services
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/xuri/excelize/v2"
+	"excel-processor/internal/models"
+)
+
+const (
+	pdfFontFamily      = "DejaVu"
+	pdfMargin          = 10.0 // Page margin in mm
+	pdfFooterHeight    = 6.0  // Space reserved for page numbers in mm
+	pdfDefaultFontSize = 9.0
+	pdfMinFontSize     = 5.0
+	pdfPointToMM       = 25.4 / 72
+)
+
+// pdfFontDirs are searched in order for DejaVuSans.ttf and DejaVuSans-Bold.ttf to embed in
+// rendered PDFs. Without them the copies bundled with the server are used; DejaVu covers the
+// full Vietnamese alphabet.
+var pdfFontDirs = []string{os.Getenv("PDF_FONT_DIR"), "fonts"}
+
+var (
+	//go:embed fonts/DejaVuSans.ttf
+	pdfRegularFont []byte
+	//go:embed fonts/DejaVuSans-Bold.ttf
+	pdfBoldFont []byte
+)
+
+// pdfPaperSizes maps Excel page setup paper size codes to PDF page sizes
+var pdfPaperSizes = map[int]string{1: "Letter", 5: "Legal", 8: "A3", 9: "A4", 11: "A5"}
+
+// printTitlesPattern matches the row range of a sheet's print titles, e.g. Sheet1!$1:$3
+var printTitlesPattern = regexp.MustCompile(`\$(\d+):\$(\d+)`)
+
+// pdfCell is a cell prepared for rendering
+type pdfCell struct {
+	Text    string
+	Align   string // L, C or R
+	Bold    bool
+	Border  bool
+	ColSpan int
+	RowSpan int
+	Covered bool // Part of a merged range but not its top-left cell
+}
+
+// pdfSheet is the used range of a sheet with sizes in mm
+type pdfSheet struct {
+	Cells   [][]pdfCell
+	Widths  []float64 // 0 for hidden columns
+	Heights []float64 // 0 for hidden rows
+}
+
+// RenderPDF renders a sheet of a workbook to PDF in the exports directory and returns its path.
+// Column widths, row heights, merged cells, bold text and alignment are taken from the sheet;
+// the table is scaled down to fit the page width and header rows are repeated on each page.
+func (s *ExcelService) RenderPDF(filePath string, opts models.PDFOptions) (string, error) {
+	f, err := s.openWorkbook(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	sheetName := opts.SheetName
+	if sheetName == "" {
+		sheetName = f.GetSheetList()[0]
+	} else if idx, _ := f.GetSheetIndex(sheetName); idx == -1 {
+		return "", fmt.Errorf("sheet %s not found", sheetName)
+	}
+
+	table, err := readPDFSheet(f, sheetName)
+	if err != nil {
+		return "", err
+	}
+
+	pageSize, orientation := pdfPageSetup(f, sheetName, opts)
+	headerRows := opts.HeaderRows
+	if headerRows == 0 {
+		headerRows = printTitleRows(f, sheetName)
+	}
+	fontSize := opts.FontSize
+	if fontSize <= 0 {
+		fontSize = pdfDefaultFontSize
+	}
+
+	fontDir := findPDFFontDir()
+	pdf := gofpdf.NewCustom(&gofpdf.InitType{UnitStr: "mm", SizeStr: pageSize, FontDirStr: fontDir})
+	if fontDir != "" {
+		pdf.AddUTF8Font(pdfFontFamily, "", "DejaVuSans.ttf")
+		pdf.AddUTF8Font(pdfFontFamily, "B", "DejaVuSans-Bold.ttf")
+	} else {
+		pdf.AddUTF8FontFromBytes(pdfFontFamily, "", pdfRegularFont)
+		pdf.AddUTF8FontFromBytes(pdfFontFamily, "B", pdfBoldFont)
+	}
+	if err := pdf.Error(); err != nil {
+		return "", fmt.Errorf("failed to load fonts: %w", err)
+	}
+
+	// Pick landscape when the table does not fit a portrait page
+	totalWidth := 0.0
+	for _, w := range table.Widths {
+		totalWidth += w
+	}
+	portraitWidth, _ := pdf.GetPageSize()
+	if orientation == "" {
+		orientation = "P"
+		if totalWidth > portraitWidth-2*pdfMargin {
+			orientation = "L"
+		}
+	}
+
+	pdf.SetMargins(pdfMargin, pdfMargin, pdfMargin)
+	pdf.SetAutoPageBreak(false, 0)
+	pdf.AliasNbPages("")
+	pdf.SetFooterFunc(func() {
+		pdf.SetFont(pdfFontFamily, "", 7)
+		pdf.SetXY(pdfMargin, -pdfMargin-pdfFooterHeight/2)
+		pdf.CellFormat(0, pdfFooterHeight/2, fmt.Sprintf("Trang %d/{nb}", pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+	pdf.AddPageFormat(orientation, pdf.GetPageSizeStr(pageSize))
+
+	// Scale the table down to the printable width, like Excel's "fit all columns on one page"
+	pageWidth, pageHeight := pdf.GetPageSize()
+	if printable := pageWidth - 2*pdfMargin; totalWidth > printable {
+		scale := printable / totalWidth
+		for i := range table.Widths {
+			table.Widths[i] *= scale
+		}
+		for i := range table.Heights {
+			table.Heights[i] *= scale
+		}
+		fontSize *= scale
+		if fontSize < pdfMinFontSize {
+			fontSize = pdfMinFontSize
+		}
+	}
+	lineHeight := fontSize * pdfPointToMM * 1.2
+	fitPDFRowHeights(pdf, &table, fontSize, lineHeight)
+
+	bottom := pageHeight - pdfMargin - pdfFooterHeight
+	y := pdfMargin
+	pageHasBody := false
+	for row := range table.Cells {
+		height := table.Heights[row]
+		if height == 0 {
+			continue
+		}
+
+		if y+height > bottom && pageHasBody {
+			pdf.AddPageFormat(orientation, pdf.GetPageSizeStr(pageSize))
+			y = pdfMargin
+			pageHasBody = false
+			if row >= headerRows {
+				for header := 0; header < headerRows && header < len(table.Cells); header++ {
+					drawPDFRow(pdf, &table, header, y, bottom, fontSize, lineHeight, opts.Gridlines)
+					y += table.Heights[header]
+				}
+			}
+		}
+
+		drawPDFRow(pdf, &table, row, y, bottom, fontSize, lineHeight, opts.Gridlines)
+		y += height
+		pageHasBody = true
+	}
+
+	// Create exports directory if not exists
+	if err := os.MkdirAll("exports", 0755); err != nil {
+		return "", fmt.Errorf("failed to create exports directory: %w", err)
+	}
+	stem := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+	outputPath := fmt.Sprintf("exports/%s_%d.pdf", stem, time.Now().UnixNano())
+	if err := pdf.OutputFileAndClose(outputPath); err != nil {
+		os.Remove(outputPath)
+		return "", fmt.Errorf("failed to write PDF: %w", err)
+	}
+
+	return outputPath, nil
+}
+
+// findPDFFontDir returns the first font directory that contains the DejaVu fonts, "" to use
+// the bundled ones
+func findPDFFontDir() string {
+	for _, dir := range pdfFontDirs {
+		if dir == "" {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, "DejaVuSans.ttf")); err != nil {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, "DejaVuSans-Bold.ttf")); err != nil {
+			continue
+		}
+		return dir
+	}
+	return ""
+}
+
+// pdfPageSetup resolves page size and orientation ("P", "L" or "" for automatic) from the
+// options, falling back to the sheet's page setup
+func pdfPageSetup(f *excelize.File, sheetName string, opts models.PDFOptions) (string, string) {
+	layout, _ := f.GetPageLayout(sheetName)
+
+	pageSize := "A4"
+	switch strings.ToLower(opts.PageSize) {
+	case "a3":
+		pageSize = "A3"
+	case "a5":
+		pageSize = "A5"
+	case "letter":
+		pageSize = "Letter"
+	case "legal":
+		pageSize = "Legal"
+	case "":
+		if layout.Size != nil {
+			if size, ok := pdfPaperSizes[*layout.Size]; ok {
+				pageSize = size
+			}
+		}
+	}
+
+	orientation := ""
+	switch strings.ToLower(opts.Orientation) {
+	case "portrait", "p":
+		orientation = "P"
+	case "landscape", "l":
+		orientation = "L"
+	case "":
+		// Portrait is also what excelize reports for sheets without a page setup
+		if layout.Orientation != nil && *layout.Orientation == "landscape" {
+			orientation = "L"
+		}
+	}
+
+	return pageSize, orientation
+}
+
+// printTitleRows returns the number of rows repeated at the top of each printed page
+// according to the sheet's print titles, or 0 when none are set
+func printTitleRows(f *excelize.File, sheetName string) int {
+	for _, name := range f.GetDefinedName() {
+		if name.Name != "_xlnm.Print_Titles" || name.Scope != sheetName {
+			continue
+		}
+		match := printTitlesPattern.FindStringSubmatch(name.RefersTo)
+		if match == nil {
+			return 0
+		}
+		if first, _ := strconv.Atoi(match[1]); first != 1 {
+			// Only titles starting at the top row can be repeated as a block
+			return 0
+		}
+		last, _ := strconv.Atoi(match[2])
+		return last
+	}
+	return 0
+}
+
+// readPDFSheet reads the used range of a sheet with display values, styles, merges and sizes
+func readPDFSheet(f *excelize.File, sheetName string) (pdfSheet, error) {
+	rows, err := f.GetRows(sheetName)
+	if err != nil {
+		return pdfSheet{}, fmt.Errorf("failed to read sheet %s: %w", sheetName, err)
+	}
+	mergeCells, err := f.GetMergeCells(sheetName)
+	if err != nil {
+		return pdfSheet{}, fmt.Errorf("failed to read merged cells: %w", err)
+	}
+
+	rowCount, colCount := len(rows), 0
+	for _, row := range rows {
+		if len(row) > colCount {
+			colCount = len(row)
+		}
+	}
+	for _, mc := range mergeCells {
+		endCol, endRow, err := excelize.CellNameToCoordinates(mc.GetEndAxis())
+		if err != nil {
+			continue
+		}
+		if endCol > colCount {
+			colCount = endCol
+		}
+		if endRow > rowCount {
+			rowCount = endRow
+		}
+	}
+
+	table := pdfSheet{
+		Cells:   make([][]pdfCell, rowCount),
+		Widths:  make([]float64, colCount),
+		Heights: make([]float64, rowCount),
+	}
+
+	for col := 1; col <= colCount; col++ {
+		colName, _ := excelize.ColumnNumberToName(col)
+		if visible, _ := f.GetColVisible(sheetName, colName); !visible {
+			continue
+		}
+		width, _ := f.GetColWidth(sheetName, colName)
+		// Excel column widths are in characters of the default font, about 7px each at 96 DPI
+		table.Widths[col-1] = (width*7 + 5) * 25.4 / 96
+	}
+
+	styles := make(map[int]*excelize.Style)
+	for row := 1; row <= rowCount; row++ {
+		if visible, _ := f.GetRowVisible(sheetName, row); visible {
+			height, _ := f.GetRowHeight(sheetName, row)
+			table.Heights[row-1] = height * pdfPointToMM
+		}
+
+		table.Cells[row-1] = make([]pdfCell, colCount)
+		for col := 1; col <= colCount; col++ {
+			cellName, _ := excelize.CoordinatesToCellName(col, row)
+			display := ""
+			if row <= len(rows) && col <= len(rows[row-1]) {
+				display = rows[row-1][col-1]
+			}
+			text, _, isNumber := typedCellValue(f, sheetName, cellName, display)
+
+			cell := pdfCell{Text: text, Align: "L", ColSpan: 1, RowSpan: 1}
+			if isNumber {
+				cell.Align = "R"
+			}
+
+			styleID, _ := f.GetCellStyle(sheetName, cellName)
+			style, ok := styles[styleID]
+			if !ok {
+				style, _ = f.GetStyle(styleID)
+				styles[styleID] = style
+			}
+			if style != nil {
+				cell.Bold = style.Font != nil && style.Font.Bold
+				cell.Border = len(style.Border) > 0
+				if style.Alignment != nil {
+					switch style.Alignment.Horizontal {
+					case "center", "centerContinuous":
+						cell.Align = "C"
+					case "right":
+						cell.Align = "R"
+					case "left":
+						cell.Align = "L"
+					}
+				}
+			}
+			table.Cells[row-1][col-1] = cell
+		}
+	}
+
+	for _, mc := range mergeCells {
+		startCol, startRow, err := excelize.CellNameToCoordinates(mc.GetStartAxis())
+		if err != nil {
+			continue
+		}
+		endCol, endRow, err := excelize.CellNameToCoordinates(mc.GetEndAxis())
+		if err != nil {
+			continue
+		}
+		for row := startRow; row <= endRow; row++ {
+			for col := startCol; col <= endCol; col++ {
+				table.Cells[row-1][col-1].Covered = row != startRow || col != startCol
+			}
+		}
+		anchor := &table.Cells[startRow-1][startCol-1]
+		anchor.ColSpan = endCol - startCol + 1
+		anchor.RowSpan = endRow - startRow + 1
+	}
+
+	return table, nil
+}
+
+// fitPDFRowHeights grows rows so that wrapped text of single-row cells fits
+func fitPDFRowHeights(pdf *gofpdf.Fpdf, table *pdfSheet, fontSize, lineHeight float64) {
+	for row, cells := range table.Cells {
+		if table.Heights[row] == 0 {
+			continue
+		}
+		for col, cell := range cells {
+			if cell.Covered || cell.RowSpan > 1 || cell.Text == "" || table.Widths[col] == 0 {
+				continue
+			}
+			setPDFFont(pdf, cell.Bold, fontSize)
+			lines := wrapPDFText(pdf, cell.Text, pdfSpanWidth(table, col, cell.ColSpan)-2*pdf.GetCellMargin())
+			if needed := float64(len(lines))*lineHeight + 1; needed > table.Heights[row] {
+				table.Heights[row] = needed
+			}
+		}
+	}
+}
+
+// drawPDFRow draws the cells of one sheet row at vertical position y
+func drawPDFRow(pdf *gofpdf.Fpdf, table *pdfSheet, row int, y, bottom, fontSize, lineHeight float64, gridlines bool) {
+	x := pdfMargin
+	for col, cell := range table.Cells[row] {
+		width := table.Widths[col]
+		if width == 0 {
+			continue
+		}
+		if cell.Covered {
+			x += width
+			continue
+		}
+
+		spanWidth := pdfSpanWidth(table, col, cell.ColSpan)
+		spanHeight := 0.0
+		for r := row; r < row+cell.RowSpan && r < len(table.Heights); r++ {
+			spanHeight += table.Heights[r]
+		}
+		if spanHeight > bottom-y {
+			spanHeight = bottom - y
+		}
+
+		if cell.Border || gridlines {
+			pdf.Rect(x, y, spanWidth, spanHeight, "D")
+		}
+		if cell.Text != "" {
+			setPDFFont(pdf, cell.Bold, fontSize)
+			lines := wrapPDFText(pdf, cell.Text, spanWidth-2*pdf.GetCellMargin())
+			textY := y + (spanHeight-float64(len(lines))*lineHeight)/2
+			for _, line := range lines {
+				pdf.SetXY(x, textY)
+				pdf.CellFormat(spanWidth, lineHeight, line, "", 0, cell.Align, false, 0, "")
+				textY += lineHeight
+			}
+		}
+		x += width
+	}
+}
+
+// pdfSpanWidth returns the width of span visible columns starting at col
+func pdfSpanWidth(table *pdfSheet, col, span int) float64 {
+	width := 0.0
+	for c := col; c < col+span && c < len(table.Widths); c++ {
+		width += table.Widths[c]
+	}
+	return width
+}
+
+// setPDFFont selects the regular or bold DejaVu font
+func setPDFFont(pdf *gofpdf.Fpdf, bold bool, size float64) {
+	style := ""
+	if bold {
+		style = "B"
+	}
+	pdf.SetFont(pdfFontFamily, style, size)
+}
+
+// wrapPDFText splits text into lines no wider than width, breaking at spaces where possible
+func wrapPDFText(pdf *gofpdf.Fpdf, text string, width float64) []string {
+	lines := make([]string, 0)
+	for _, paragraph := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			candidate := word
+			if line != "" {
+				candidate = line + " " + word
+			}
+			if pdf.GetStringWidth(candidate) <= width {
+				line = candidate
+				continue
+			}
+			if line != "" {
+				lines = append(lines, line)
+			}
+
+			// Break words longer than the cell by character
+			line = ""
+			for _, r := range word {
+				if line != "" && pdf.GetStringWidth(line+string(r)) > width {
+					lines = append(lines, line)
+					line = ""
+				}
+				line += string(r)
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
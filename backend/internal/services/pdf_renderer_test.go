@@ -0,0 +1,107 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+
+	"excel-processor/internal/models"
+)
+
+func TestPDFPageSetup(t *testing.T) {
+	a3, landscape := 8, "landscape"
+	tests := []struct {
+		name            string
+		layout          *excelize.PageLayoutOptions
+		opts            models.PDFOptions
+		wantSize        string
+		wantOrientation string
+	}{
+		{"defaults", nil, models.PDFOptions{}, "A4", ""},
+		{"sheet page setup", &excelize.PageLayoutOptions{Size: &a3, Orientation: &landscape}, models.PDFOptions{}, "A3", "L"},
+		{"options override the sheet", &excelize.PageLayoutOptions{Size: &a3, Orientation: &landscape}, models.PDFOptions{PageSize: "a5", Orientation: "portrait"}, "A5", "P"},
+		{"unknown page size", nil, models.PDFOptions{PageSize: "B4", Orientation: "L"}, "A4", "L"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := excelize.NewFile()
+			defer f.Close()
+			if tt.layout != nil {
+				if err := f.SetPageLayout("Sheet1", tt.layout); err != nil {
+					t.Fatalf("SetPageLayout returned %v", err)
+				}
+			}
+			size, orientation := pdfPageSetup(f, "Sheet1", tt.opts)
+			if size != tt.wantSize || orientation != tt.wantOrientation {
+				t.Errorf("pdfPageSetup = %s, %q, want %s, %q", size, orientation, tt.wantSize, tt.wantOrientation)
+			}
+		})
+	}
+}
+
+func TestPrintTitleRows(t *testing.T) {
+	tests := []struct {
+		refersTo string
+		want     int
+	}{
+		{"", 0},
+		{"Sheet1!$1:$3", 3},
+		{"Sheet1!$2:$4", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.refersTo, func(t *testing.T) {
+			f := excelize.NewFile()
+			defer f.Close()
+			if tt.refersTo != "" {
+				if err := f.SetDefinedName(&excelize.DefinedName{Name: "_xlnm.Print_Titles", RefersTo: tt.refersTo, Scope: "Sheet1"}); err != nil {
+					t.Fatalf("SetDefinedName returned %v", err)
+				}
+			}
+			if got := printTitleRows(f, "Sheet1"); got != tt.want {
+				t.Errorf("printTitleRows(%q) = %d, want %d", tt.refersTo, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderPDF(t *testing.T) {
+	t.Chdir(t.TempDir())
+	f := excelize.NewFile()
+	f.SetSheetRow("Sheet1", "A1", &[]interface{}{"STT", "Họ và tên", "Thu nhập chịu thuế"})
+	for row := 2; row <= 150; row++ {
+		cell, _ := excelize.CoordinatesToCellName(1, row)
+		f.SetSheetRow("Sheet1", cell, &[]interface{}{row - 1, fmt.Sprintf("Nguyễn Thị Ánh Tuyết %d", row-1), 12500000.5})
+	}
+	xlsxPath := "report.xlsx"
+	if err := f.SaveAs(xlsxPath); err != nil {
+		t.Fatalf("SaveAs returned %v", err)
+	}
+	f.Close()
+
+	s := NewExcelService()
+	if _, err := s.RenderPDF(xlsxPath, models.PDFOptions{SheetName: "Missing"}); err == nil {
+		t.Error("RenderPDF accepted a missing sheet")
+	}
+
+	pdfPath, err := s.RenderPDF(xlsxPath, models.PDFOptions{HeaderRows: 1, Gridlines: true})
+	if err != nil {
+		t.Fatalf("RenderPDF returned %v", err)
+	}
+	content, err := os.ReadFile(pdfPath)
+	if err != nil {
+		t.Fatalf("ReadFile returned %v", err)
+	}
+	if !bytes.HasPrefix(content, []byte("%PDF-")) {
+		t.Fatalf("output does not start with a PDF header: %q", content[:min(len(content), 16)])
+	}
+	// 150 rows do not fit on one A4 page
+	if pages := bytes.Count(content, []byte("/Type /Page\n")); pages < 2 {
+		t.Errorf("rendered %d pages, want at least 2", pages)
+	}
+	if !bytes.Contains(content, []byte("/FontFile2")) {
+		t.Error("the font is not embedded")
+	}
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"excel-processor/internal/models"
+)
+
+// NewReportingPeriod builds a reporting period with its date range and display name.
+// number is the month (1-12) or quarter (1-4) and is ignored for yearly periods.
+func NewReportingPeriod(periodType string, year, number int) (models.ReportingPeriod, error) {
+	period := models.ReportingPeriod{Type: periodType, Year: year, Number: number}
+
+	switch periodType {
+	case models.PeriodMonth:
+		if number < 1 || number > 12 {
+			return period, fmt.Errorf("month must be between 1 and 12, got %d", number)
+		}
+		period.StartDate = time.Date(year, time.Month(number), 1, 0, 0, 0, 0, time.Local)
+		period.EndDate = period.StartDate.AddDate(0, 1, -1)
+		period.Name = fmt.Sprintf("Tháng %02d/%d", number, year)
+	case models.PeriodQuarter:
+		if number < 1 || number > 4 {
+			return period, fmt.Errorf("quarter must be between 1 and 4, got %d", number)
+		}
+		period.StartDate = time.Date(year, time.Month(3*(number-1)+1), 1, 0, 0, 0, 0, time.Local)
+		period.EndDate = period.StartDate.AddDate(0, 3, -1)
+		period.Name = fmt.Sprintf("Quý %d/%d", number, year)
+	case models.PeriodYear:
+		period.Number = 0
+		period.StartDate = time.Date(year, time.January, 1, 0, 0, 0, 0, time.Local)
+		period.EndDate = time.Date(year, time.December, 31, 0, 0, 0, 0, time.Local)
+		period.Name = fmt.Sprintf("Năm %d", year)
+	default:
+		return period, fmt.Errorf("unsupported period type: %s", periodType)
+	}
+
+	return period, nil
+}
+
+// submissionProgress orders statuses from least to most advanced
+var submissionProgress = map[string]int{
+	models.SubmissionPending:   0,
+	models.SubmissionRejected:  1,
+	models.SubmissionSubmitted: 2,
+	models.SubmissionValidated: 3,
+	models.SubmissionApproved:  4,
+}
+
+// BuildSubmissionDashboard lists every unit of a province with its submission status for a
+// period. A unit with several submissions (one per template) shows its least advanced one.
+func BuildSubmissionDashboard(province models.Province, period models.ReportingPeriod, units []models.Unit, submissions []models.Submission) models.SubmissionDashboard {
+	dashboard := models.SubmissionDashboard{
+		Province:     province,
+		Period:       period,
+		TotalUnits:   len(units),
+		StatusCounts: make(map[string]int),
+		NotSubmitted: make([]models.UnitSubmissionStatus, 0),
+		Units:        make([]models.UnitSubmissionStatus, 0, len(units)),
+	}
+
+	byUnit := make(map[uint]models.Submission)
+	for _, submission := range submissions {
+		current, exists := byUnit[submission.UnitID]
+		if !exists || submissionProgress[submission.Status] < submissionProgress[current.Status] {
+			byUnit[submission.UnitID] = submission
+		}
+	}
+
+	for _, unit := range units {
+		status := models.UnitSubmissionStatus{
+			UnitID:   unit.ID,
+			UnitCode: unit.Code,
+			UnitName: unit.Name,
+			Status:   "missing",
+		}
+		if submission, exists := byUnit[unit.ID]; exists {
+			id := submission.ID
+			status.Status = submission.Status
+			status.SubmissionID = &id
+			status.FileID = submission.FileID
+			status.SubmittedAt = submission.SubmittedAt
+		}
+
+		dashboard.StatusCounts[status.Status]++
+		dashboard.Units = append(dashboard.Units, status)
+		// A rejected submission is reopened for a new upload, so the unit still owes a file
+		if status.Status == "missing" || status.Status == models.SubmissionPending || status.Status == models.SubmissionRejected {
+			dashboard.NotSubmitted = append(dashboard.NotSubmitted, status)
+		}
+	}
+
+	if period.DueDate != nil && time.Now().After(*period.DueDate) && len(dashboard.NotSubmitted) > 0 {
+		dashboard.Overdue = true
+	}
+
+	return dashboard
+}
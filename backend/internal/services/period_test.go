@@ -0,0 +1,95 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"excel-processor/internal/models"
+)
+
+func TestNewReportingPeriod(t *testing.T) {
+	tests := []struct {
+		periodType string
+		year       int
+		number     int
+		wantName   string
+		wantStart  string
+		wantEnd    string
+	}{
+		{models.PeriodMonth, 2024, 2, "Tháng 02/2024", "2024-02-01", "2024-02-29"},
+		{models.PeriodMonth, 2025, 12, "Tháng 12/2025", "2025-12-01", "2025-12-31"},
+		{models.PeriodQuarter, 2025, 2, "Quý 2/2025", "2025-04-01", "2025-06-30"},
+		{models.PeriodYear, 2025, 7, "Năm 2025", "2025-01-01", "2025-12-31"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.wantName, func(t *testing.T) {
+			period, err := NewReportingPeriod(tt.periodType, tt.year, tt.number)
+			if err != nil {
+				t.Fatalf("NewReportingPeriod returned %v", err)
+			}
+			if period.Name != tt.wantName {
+				t.Errorf("Name = %q, want %q", period.Name, tt.wantName)
+			}
+			if got := period.StartDate.Format("2006-01-02"); got != tt.wantStart {
+				t.Errorf("StartDate = %s, want %s", got, tt.wantStart)
+			}
+			if got := period.EndDate.Format("2006-01-02"); got != tt.wantEnd {
+				t.Errorf("EndDate = %s, want %s", got, tt.wantEnd)
+			}
+		})
+	}
+
+	for _, bad := range []struct {
+		periodType string
+		number     int
+	}{{models.PeriodMonth, 13}, {models.PeriodMonth, 0}, {models.PeriodQuarter, 5}, {"week", 1}} {
+		if _, err := NewReportingPeriod(bad.periodType, 2025, bad.number); err == nil {
+			t.Errorf("NewReportingPeriod(%s, 2025, %d) returned no error", bad.periodType, bad.number)
+		}
+	}
+}
+
+func TestBuildSubmissionDashboard(t *testing.T) {
+	units := []models.Unit{{ID: 1, Code: "HN-01"}, {ID: 2, Code: "HN-02"}, {ID: 3, Code: "HN-03"}, {ID: 4, Code: "HN-04"}}
+	submissions := []models.Submission{
+		// Unit 1 has two templates and shows the less advanced one
+		{ID: 10, UnitID: 1, Status: models.SubmissionApproved},
+		{ID: 11, UnitID: 1, Status: models.SubmissionSubmitted},
+		{ID: 12, UnitID: 2, Status: models.SubmissionRejected},
+		{ID: 13, UnitID: 3, Status: models.SubmissionApproved},
+	}
+	due := time.Now().Add(-time.Hour)
+	period := models.ReportingPeriod{DueDate: &due}
+
+	dashboard := BuildSubmissionDashboard(models.Province{ID: 1}, period, units, submissions)
+
+	wantStatus := []string{models.SubmissionSubmitted, models.SubmissionRejected, models.SubmissionApproved, "missing"}
+	for i, unit := range dashboard.Units {
+		if unit.Status != wantStatus[i] {
+			t.Errorf("unit %s status = %s, want %s", unit.UnitCode, unit.Status, wantStatus[i])
+		}
+	}
+	if id := dashboard.Units[0].SubmissionID; id == nil || *id != 11 {
+		t.Errorf("unit HN-01 shows submission %v, want 11", id)
+	}
+
+	var notSubmitted []string
+	for _, unit := range dashboard.NotSubmitted {
+		notSubmitted = append(notSubmitted, unit.UnitCode)
+	}
+	if len(notSubmitted) != 2 || notSubmitted[0] != "HN-02" || notSubmitted[1] != "HN-04" {
+		t.Errorf("NotSubmitted = %v, want [HN-02 HN-04]", notSubmitted)
+	}
+	if dashboard.TotalUnits != 4 || dashboard.StatusCounts[models.SubmissionApproved] != 1 || dashboard.StatusCounts["missing"] != 1 {
+		t.Errorf("TotalUnits = %d, StatusCounts = %v", dashboard.TotalUnits, dashboard.StatusCounts)
+	}
+	if !dashboard.Overdue {
+		t.Error("Overdue = false with a past due date and units outstanding")
+	}
+
+	later := time.Now().Add(time.Hour)
+	period.DueDate = &later
+	if BuildSubmissionDashboard(models.Province{ID: 1}, period, units, submissions).Overdue {
+		t.Error("Overdue = true before the due date")
+	}
+}
@@ -0,0 +1,212 @@
+package services
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"excel-processor/internal/models"
+)
+
+// maxRoundDigits limits the digits of ROUND to the precision range of models.RoundingRule
+const maxRoundDigits = 15
+
+// formulaFunction is a function that formulas can call
+type formulaFunction struct {
+	minArgs, maxArgs int
+	usage            string // Arguments, for error messages
+	taxTable         bool   // Reads the tax table
+	call             func(table *models.TaxTable, args []*big.Rat) (*big.Rat, error)
+}
+
+// formulaFunctions are the functions formulas can call. The PIT and insurance functions
+// read their rates from the tax table chosen for the calculation.
+var formulaFunctions = map[string]formulaFunction{
+	"PIT": {1, 1, "(taxable_income)", true, func(table *models.TaxTable, args []*big.Rat) (*big.Rat, error) {
+		return progressiveTax(table, args[0]), nil
+	}},
+	"SELF_DEDUCTION": {0, 0, "no arguments", true, func(table *models.TaxTable, args []*big.Rat) (*big.Rat, error) {
+		return ratOf(table.SelfDeduction), nil
+	}},
+	"DEPENDANT_DEDUCTION": {1, 1, "(dependants)", true, func(table *models.TaxTable, args []*big.Rat) (*big.Rat, error) {
+		return new(big.Rat).Mul(ratOf(table.DependantDeduction), args[0]), nil
+	}},
+	"FAMILY_DEDUCTION": {1, 1, "(dependants)", true, func(table *models.TaxTable, args []*big.Rat) (*big.Rat, error) {
+		deduction := new(big.Rat).Mul(ratOf(table.DependantDeduction), args[0])
+		return deduction.Add(deduction, ratOf(table.SelfDeduction)), nil
+	}},
+	"INSURANCE": {1, 2, "(salary[, region])", true, func(table *models.TaxTable, args []*big.Rat) (*big.Rat, error) {
+		return insuranceContribution(table, table.Insurance.Employee, args)
+	}},
+	"EMPLOYER_INSURANCE": {1, 2, "(salary[, region])", true, func(table *models.TaxTable, args []*big.Rat) (*big.Rat, error) {
+		return insuranceContribution(table, table.Insurance.Employer, args)
+	}},
+	"MIN": {1, 255, "one or more values", false, func(_ *models.TaxTable, args []*big.Rat) (*big.Rat, error) {
+		result := args[0]
+		for _, arg := range args[1:] {
+			if arg.Cmp(result) < 0 {
+				result = arg
+			}
+		}
+		return result, nil
+	}},
+	"MAX": {1, 255, "one or more values", false, func(_ *models.TaxTable, args []*big.Rat) (*big.Rat, error) {
+		result := args[0]
+		for _, arg := range args[1:] {
+			if arg.Cmp(result) > 0 {
+				result = arg
+			}
+		}
+		return result, nil
+	}},
+	"ROUND": {1, 2, "(value[, digits])", false, func(_ *models.TaxTable, args []*big.Rat) (*big.Rat, error) {
+		digits := 0
+		if len(args) == 2 {
+			if !args[1].IsInt() || args[1].Cmp(big.NewRat(-maxRoundDigits, 1)) < 0 || args[1].Cmp(big.NewRat(maxRoundDigits, 1)) > 0 {
+				return nil, formulaErrorf("ROUND: digits must be a whole number from -%d to %d, got %s", maxRoundDigits, maxRoundDigits, args[1].RatString())
+			}
+			digits = int(args[1].Num().Int64())
+		}
+		return roundDecimal(args[0], models.RoundingRule{Mode: "half_up", Precision: digits}), nil
+	}},
+}
+
+// progressiveTax is the personal income tax on a month's taxable income: each bracket's
+// rate applies to the part of the income within it
+func progressiveTax(table *models.TaxTable, income *big.Rat) *big.Rat {
+	tax := new(big.Rat)
+	lower := new(big.Rat)
+	for _, bracket := range table.Brackets {
+		if income.Cmp(lower) <= 0 {
+			break
+		}
+		part := new(big.Rat).Set(income)
+		upper := ratOf(bracket.UpTo)
+		if bracket.UpTo > 0 && income.Cmp(upper) > 0 {
+			part.Set(upper)
+		}
+		part.Sub(part, lower)
+		tax.Add(tax, part.Mul(part, ratOf(bracket.Rate)))
+		if bracket.UpTo <= 0 {
+			break
+		}
+		lower = upper
+	}
+	return tax
+}
+
+// insuranceContribution is the compulsory insurance paid on a salary at the given rates.
+// Social and health insurance are capped at CapMultiple times the base salary, unemployment
+// insurance at CapMultiple times the minimum wage of the region (1 to 4, default 1).
+func insuranceContribution(table *models.TaxTable, share models.InsuranceShare, args []*big.Rat) (*big.Rat, error) {
+	rates := table.Insurance
+	salary := args[0]
+	if salary.Sign() <= 0 {
+		return new(big.Rat), nil
+	}
+
+	region := 1
+	if len(args) == 2 {
+		if !args[1].IsInt() || args[1].Num().Int64() < 1 || args[1].Num().Int64() > int64(max(len(rates.RegionalMinimumWages), 1)) {
+			return nil, formulaErrorf("INSURANCE: region must be 1 to %d, got %s", max(len(rates.RegionalMinimumWages), 1), args[1].RatString())
+		}
+		region = int(args[1].Num().Int64())
+	}
+
+	capped := func(limit float64) *big.Rat {
+		if limit <= 0 || rates.CapMultiple <= 0 {
+			return salary
+		}
+		ceiling := new(big.Rat).Mul(ratOf(limit), ratOf(rates.CapMultiple))
+		if salary.Cmp(ceiling) > 0 {
+			return ceiling
+		}
+		return salary
+	}
+
+	contribution := new(big.Rat).Mul(capped(rates.BaseSalary), ratOf(share.Social+share.Health))
+	unemploymentCap := 0.0
+	if len(rates.RegionalMinimumWages) >= region {
+		unemploymentCap = rates.RegionalMinimumWages[region-1]
+	}
+	return contribution.Add(contribution, new(big.Rat).Mul(capped(unemploymentCap), ratOf(share.Unemployment))), nil
+}
+
+// ratOf returns the exact value of a configured amount or rate
+func ratOf(value float64) *big.Rat {
+	if r, ok := decimalValue(value); ok {
+		return r
+	}
+	return new(big.Rat)
+}
+
+// ValidateTaxTable checks that the brackets of a tax table rise from above 0 and end with an
+// open top bracket, that the rates are fractions and that no amount is negative
+func ValidateTaxTable(req *models.TaxTableRequest) error {
+	for i, bracket := range req.Brackets {
+		if bracket.Rate < 0 || bracket.Rate > 1 {
+			return fmt.Errorf("bracket %d: rate must be between 0 and 1, e.g. 0.05 for 5%%", i+1)
+		}
+		last := i == len(req.Brackets)-1
+		if last != (bracket.UpTo == 0) {
+			return fmt.Errorf("bracket %d: only the last bracket has no up_to", i+1)
+		}
+		if !last && bracket.UpTo < 0 {
+			return fmt.Errorf("bracket %d: up_to must be above 0", i+1)
+		}
+		if i > 0 && !last && bracket.UpTo <= req.Brackets[i-1].UpTo {
+			return fmt.Errorf("bracket %d: up_to must be above the previous bracket", i+1)
+		}
+	}
+
+	insurance := req.Insurance
+	if insurance.BaseSalary < 0 || insurance.CapMultiple < 0 {
+		return fmt.Errorf("insurance: base_salary and cap_multiple must not be negative")
+	}
+	for i, wage := range insurance.RegionalMinimumWages {
+		if wage < 0 {
+			return fmt.Errorf("insurance: minimum wage of region %d must not be negative", i+1)
+		}
+	}
+
+	shares := []models.InsuranceShare{insurance.Employee, insurance.Employer}
+	for _, share := range shares {
+		for _, rate := range []float64{share.Social, share.Health, share.Unemployment} {
+			if rate < 0 || rate > 1 {
+				return fmt.Errorf("insurance rates must be between 0 and 1, e.g. 0.08 for 8%%")
+			}
+		}
+	}
+	return nil
+}
+
+// DefaultTaxTable is the table created for a new database: the PIT brackets of the PIT law,
+// the family deductions of Resolution 954/2020/UBTVQH14, and the insurance rates with the
+// base salary and regional minimum wages in force from July 2024. Newer rates are added as
+// new versions.
+func DefaultTaxTable() models.TaxTable {
+	return models.TaxTable{
+		Version:       "2024-07",
+		EffectiveFrom: time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC),
+		Description:   "Biểu thuế lũy tiến 7 bậc; lương cơ sở 2.340.000; lương tối thiểu vùng theo Nghị định 74/2024/NĐ-CP",
+		Brackets: []models.TaxBracket{
+			{UpTo: 5000000, Rate: 0.05},
+			{UpTo: 10000000, Rate: 0.10},
+			{UpTo: 18000000, Rate: 0.15},
+			{UpTo: 32000000, Rate: 0.20},
+			{UpTo: 52000000, Rate: 0.25},
+			{UpTo: 80000000, Rate: 0.30},
+			{UpTo: 0, Rate: 0.35},
+		},
+		SelfDeduction:      11000000,
+		DependantDeduction: 4400000,
+		Insurance: models.InsuranceRates{
+			BaseSalary:           2340000,
+			RegionalMinimumWages: []float64{4960000, 4410000, 3860000, 3450000},
+			CapMultiple:          20,
+			Employee:             models.InsuranceShare{Social: 0.08, Health: 0.015, Unemployment: 0.01},
+			Employer:             models.InsuranceShare{Social: 0.175, Health: 0.03, Unemployment: 0.01},
+		},
+		CreatedBy: "system",
+	}
+}
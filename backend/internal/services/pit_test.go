@@ -0,0 +1,115 @@
+package services
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"excel-processor/internal/models"
+)
+
+func rat(t *testing.T, value string) *big.Rat {
+	t.Helper()
+	r, ok := new(big.Rat).SetString(value)
+	if !ok {
+		t.Fatalf("invalid number %q", value)
+	}
+	return r
+}
+
+func TestProgressiveTax(t *testing.T) {
+	table := DefaultTaxTable()
+	tests := []struct {
+		name   string
+		income string
+		want   string
+	}{
+		{"no income", "0", "0"},
+		{"negative income", "-1000000", "0"},
+		{"inside the first bracket", "4000000", "200000"},
+		{"top of the first bracket", "5000000", "250000"},
+		{"second bracket", "8000000", "550000"},
+		{"fourth bracket", "20000000", "2350000"},
+		{"fifth bracket", "34054000", "5263500"},
+		{"top of the sixth bracket", "80000000", "18150000"},
+		{"top bracket", "100000000", "25150000"},
+		{"fractional income", "5000000.5", "250000.05"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := progressiveTax(&table, rat(t, tt.income))
+			if got.Cmp(rat(t, tt.want)) != 0 {
+				t.Errorf("progressiveTax(%s) = %s, want %s", tt.income, got.FloatString(2), tt.want)
+			}
+		})
+	}
+}
+
+func TestInsuranceContribution(t *testing.T) {
+	table := DefaultTaxTable()
+	tests := []struct {
+		name  string
+		share models.InsuranceShare
+		args  []string
+		want  string
+	}{
+		{"employee below the caps", table.Insurance.Employee, []string{"20000000"}, "2100000"},
+		{"employee above the base salary cap", table.Insurance.Employee, []string{"50000000"}, "4946000"},
+		{"employee above both caps", table.Insurance.Employee, []string{"120000000"}, "5438000"},
+		{"employee in region 4", table.Insurance.Employee, []string{"80000000", "4"}, "5136000"},
+		{"employer below the caps", table.Insurance.Employer, []string{"20000000"}, "4300000"},
+		{"employer in region 1", table.Insurance.Employer, []string{"80000000", "1"}, "10394000"},
+		{"no salary", table.Insurance.Employee, []string{"0"}, "0"},
+		{"negative salary", table.Insurance.Employee, []string{"-5000000"}, "0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := make([]*big.Rat, len(tt.args))
+			for i, arg := range tt.args {
+				args[i] = rat(t, arg)
+			}
+			got, err := insuranceContribution(&table, tt.share, args)
+			if err != nil {
+				t.Fatalf("insuranceContribution(%v) returned %v", tt.args, err)
+			}
+			if got.Cmp(rat(t, tt.want)) != 0 {
+				t.Errorf("insuranceContribution(%v) = %s, want %s", tt.args, got.FloatString(2), tt.want)
+			}
+		})
+	}
+}
+
+func TestInsuranceContributionRegion(t *testing.T) {
+	table := DefaultTaxTable()
+	for _, region := range []string{"0", "5", "1.5", "-1"} {
+		_, err := insuranceContribution(&table, table.Insurance.Employee, []*big.Rat{rat(t, "10000000"), rat(t, region)})
+		var formulaErr *FormulaError
+		if !errors.As(err, &formulaErr) {
+			t.Errorf("region %s: got %v, want a *FormulaError", region, err)
+		}
+	}
+}
+
+func TestValidateTaxTable(t *testing.T) {
+	tests := []struct {
+		name     string
+		brackets []models.TaxBracket
+		wantErr  bool
+	}{
+		{"default brackets", DefaultTaxTable().Brackets, false},
+		{"single open bracket", []models.TaxBracket{{Rate: 0.1}}, false},
+		{"negative up_to", []models.TaxBracket{{UpTo: -5000000, Rate: 0.05}, {Rate: 0.1}}, true},
+		{"falling up_to", []models.TaxBracket{{UpTo: 10000000, Rate: 0.05}, {UpTo: 5000000, Rate: 0.1}, {Rate: 0.2}}, true},
+		{"closed top bracket", []models.TaxBracket{{UpTo: 5000000, Rate: 0.05}}, true},
+		{"open lower bracket", []models.TaxBracket{{Rate: 0.05}, {Rate: 0.1}}, true},
+		{"rate in percent", []models.TaxBracket{{UpTo: 5000000, Rate: 5}, {Rate: 0.1}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateTaxTable(&models.TaxTableRequest{Brackets: tt.brackets})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateTaxTable() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
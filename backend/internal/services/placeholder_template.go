@@ -0,0 +1,373 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+	"excel-processor/internal/models"
+)
+
+// placeholderPattern matches {{key}} placeholders, e.g. {{province.name}} or {{sum:L}}
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([^{}]+?)\s*\}\}`)
+
+const (
+	rowsBlockStart = "{{#rows}}"
+	rowsBlockEnd   = "{{/rows}}"
+)
+
+// placeholderRow is one source data row available inside a {{#rows}} block
+type placeholderRow struct {
+	Number int      // 1-based row number in the source sheet
+	Cells  []string // Raw cell values, index 0 is column A
+}
+
+// placeholderContext holds everything placeholders can be resolved from
+type placeholderContext struct {
+	province   *models.Province
+	unit       *models.Unit
+	rows       []placeholderRow
+	aggregates map[string]float64
+	now        time.Time
+}
+
+// FillPlaceholderTemplate fills a report template containing placeholders from a source sheet.
+//
+// Supported placeholders:
+//   - {{province.name}}, {{province.code}}, {{unit.name}}, {{unit.code}}
+//   - {{today}} (dd/mm/yyyy), {{month}}, {{year}}
+//   - {{sum:L}}, {{avg:L}}, {{count:L}}, {{min:L}}, {{max:L}} over the source data rows
+//   - inside {{#rows}}...{{/rows}}: {{row.L}}, {{row.index}} (1-based), {{row.number}} (source row)
+//
+// The rows block spans from the row holding {{#rows}} to the row holding {{/rows}} and is
+// repeated once per source row, keeping styles and pushing rows below it down.
+func (s *ExcelService) FillPlaceholderTemplate(sourcePath, templatePath string, req models.PlaceholderReportRequest, province *models.Province, unit *models.Unit) (string, error) {
+	src, err := s.openWorkbook(sourcePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer src.Close()
+
+	sourceRows, err := src.GetRows(req.SourceSheet)
+	if err != nil {
+		return "", fmt.Errorf("failed to read sheet %s: %w", req.SourceSheet, err)
+	}
+
+	// Resolve the 1-based source data range
+	if req.StartRow < 1 || req.StartRow > len(sourceRows) {
+		return "", fmt.Errorf("invalid start row: %d (sheet has %d rows)", req.StartRow, len(sourceRows))
+	}
+	endRow := len(sourceRows)
+	if req.EndRow != nil && *req.EndRow >= req.StartRow && *req.EndRow < endRow {
+		endRow = *req.EndRow
+	}
+
+	ctx := &placeholderContext{
+		province:   province,
+		unit:       unit,
+		aggregates: make(map[string]float64),
+		now:        time.Now(),
+	}
+	for i := req.StartRow; i <= endRow; i++ {
+		ctx.rows = append(ctx.rows, placeholderRow{Number: i, Cells: sourceRows[i-1]})
+	}
+
+	// Open template file
+	f, err := excelize.OpenFile(templatePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open template file: %w", err)
+	}
+	defer f.Close()
+
+	sheets := f.GetSheetList()
+	if req.TemplateSheet != "" {
+		if idx, _ := f.GetSheetIndex(req.TemplateSheet); idx < 0 {
+			return "", fmt.Errorf("sheet %s not found in template", req.TemplateSheet)
+		}
+		sheets = []string{req.TemplateSheet}
+	}
+
+	for _, sheet := range sheets {
+		if err := s.expandRowsBlock(f, sheet, ctx); err != nil {
+			return "", err
+		}
+		if err := s.resolveSheetPlaceholders(f, sheet, ctx); err != nil {
+			return "", err
+		}
+	}
+
+	// Create exports directory if not exists
+	if err := os.MkdirAll("exports", 0755); err != nil {
+		return "", fmt.Errorf("failed to create exports directory: %w", err)
+	}
+
+	outputPath := fmt.Sprintf("exports/report_%d.xlsx", time.Now().Unix())
+	if err := f.SaveAs(outputPath); err != nil {
+		return "", fmt.Errorf("failed to save report file: %w", err)
+	}
+
+	return outputPath, nil
+}
+
+// expandRowsBlock repeats the {{#rows}}...{{/rows}} block once per source row and
+// resolves the row placeholders in every copy
+func (s *ExcelService) expandRowsBlock(f *excelize.File, sheet string, ctx *placeholderContext) error {
+	rows, err := f.GetRows(sheet)
+	if err != nil {
+		return fmt.Errorf("failed to read sheet %s: %w", sheet, err)
+	}
+
+	// Locate and strip the block markers
+	blockStart, blockEnd := 0, 0
+	for i, row := range rows {
+		for j, value := range row {
+			hasStart := strings.Contains(value, rowsBlockStart)
+			hasEnd := strings.Contains(value, rowsBlockEnd)
+			if !hasStart && !hasEnd {
+				continue
+			}
+			if hasStart && blockStart == 0 {
+				blockStart = i + 1
+			}
+			if hasEnd && blockStart != 0 && blockEnd == 0 {
+				blockEnd = i + 1
+			}
+
+			cell, _ := excelize.CoordinatesToCellName(j+1, i+1)
+			stripped := strings.ReplaceAll(strings.ReplaceAll(value, rowsBlockStart, ""), rowsBlockEnd, "")
+			if err := f.SetCellValue(sheet, cell, strings.TrimSpace(stripped)); err != nil {
+				return fmt.Errorf("failed to update cell %s: %w", cell, err)
+			}
+		}
+	}
+
+	if blockStart == 0 {
+		return nil
+	}
+	if blockEnd == 0 {
+		return fmt.Errorf("sheet %s: %s at row %d has no matching %s", sheet, rowsBlockStart, blockStart, rowsBlockEnd)
+	}
+
+	height := blockEnd - blockStart + 1
+	if len(ctx.rows) == 0 {
+		for row := blockEnd; row >= blockStart; row-- {
+			if err := f.RemoveRow(sheet, row); err != nil {
+				return fmt.Errorf("failed to remove row %d: %w", row, err)
+			}
+		}
+		return nil
+	}
+
+	block := templateRowBlock{
+		Sheet:         sheet,
+		PrototypeRow:  blockStart,
+		PrototypeRows: height,
+		LastRow:       blockEnd,
+		KeepValues:    true,
+	}
+	if _, err := s.insertTemplateRows(f, block, blockStart+len(ctx.rows)*height-1); err != nil {
+		return fmt.Errorf("failed to repeat rows block: %w", err)
+	}
+
+	if rows, err = f.GetRows(sheet); err != nil {
+		return fmt.Errorf("failed to read sheet %s: %w", sheet, err)
+	}
+	for i := range ctx.rows {
+		for offset := 0; offset < height; offset++ {
+			rowNumber := blockStart + i*height + offset
+			if rowNumber > len(rows) {
+				continue
+			}
+			for j, value := range rows[rowNumber-1] {
+				if !strings.Contains(value, "{{") {
+					continue
+				}
+				cell, _ := excelize.CoordinatesToCellName(j+1, rowNumber)
+				if err := s.setPlaceholderCell(f, sheet, cell, value, ctx, &ctx.rows[i], i+1); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// resolveSheetPlaceholders replaces the remaining (non-row) placeholders in a sheet
+func (s *ExcelService) resolveSheetPlaceholders(f *excelize.File, sheet string, ctx *placeholderContext) error {
+	rows, err := f.GetRows(sheet)
+	if err != nil {
+		return fmt.Errorf("failed to read sheet %s: %w", sheet, err)
+	}
+
+	for i, row := range rows {
+		for j, value := range row {
+			if !strings.Contains(value, "{{") {
+				continue
+			}
+			cell, _ := excelize.CoordinatesToCellName(j+1, i+1)
+			if err := s.setPlaceholderCell(f, sheet, cell, value, ctx, nil, 0); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// setPlaceholderCell resolves the placeholders in one cell. A cell holding a single
+// placeholder gets the typed value, otherwise the placeholders are substituted as text.
+func (s *ExcelService) setPlaceholderCell(f *excelize.File, sheet, cell, text string, ctx *placeholderContext, row *placeholderRow, index int) error {
+	// Leave formulas alone, their text is not a placeholder
+	if formula, _ := f.GetCellFormula(sheet, cell); formula != "" {
+		return nil
+	}
+
+	var value interface{}
+	if m := placeholderPattern.FindStringSubmatch(text); m != nil && m[0] == strings.TrimSpace(text) {
+		v, err := s.placeholderValue(m[1], ctx, row, index)
+		if err != nil {
+			return fmt.Errorf("sheet %s cell %s: %w", sheet, cell, err)
+		}
+		value = v
+	} else {
+		var resolveErr error
+		value = placeholderPattern.ReplaceAllStringFunc(text, func(match string) string {
+			key := placeholderPattern.FindStringSubmatch(match)[1]
+			v, err := s.placeholderValue(key, ctx, row, index)
+			if err != nil && resolveErr == nil {
+				resolveErr = err
+			}
+			return formatPlaceholderValue(v)
+		})
+		if resolveErr != nil {
+			return fmt.Errorf("sheet %s cell %s: %w", sheet, cell, resolveErr)
+		}
+	}
+
+	if err := f.SetCellValue(sheet, cell, value); err != nil {
+		return fmt.Errorf("failed to set cell %s: %w", cell, err)
+	}
+	return nil
+}
+
+// placeholderValue resolves a single placeholder key
+func (s *ExcelService) placeholderValue(key string, ctx *placeholderContext, row *placeholderRow, index int) (interface{}, error) {
+	switch key {
+	case "today":
+		return ctx.now.Format("02/01/2006"), nil
+	case "month":
+		return int(ctx.now.Month()), nil
+	case "year":
+		return ctx.now.Year(), nil
+	case "province.name", "province.code":
+		if ctx.province == nil {
+			return nil, fmt.Errorf("{{%s}} used but no province is set for this file", key)
+		}
+		if key == "province.name" {
+			return ctx.province.Name, nil
+		}
+		return ctx.province.Code, nil
+	case "unit.name", "unit.code":
+		if ctx.unit == nil {
+			return nil, fmt.Errorf("{{%s}} used but no unit is set for this file", key)
+		}
+		if key == "unit.name" {
+			return ctx.unit.Name, nil
+		}
+		return ctx.unit.Code, nil
+	}
+
+	// Row placeholders: {{row.L}}, {{row.index}}, {{row.number}}
+	if field, ok := strings.CutPrefix(key, "row."); ok {
+		if row == nil {
+			return nil, fmt.Errorf("{{%s}} used outside a %s block", key, rowsBlockStart)
+		}
+		switch field {
+		case "index":
+			return index, nil
+		case "number":
+			return row.Number, nil
+		}
+		colIndex, err := excelize.ColumnNameToNumber(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid column in {{%s}}", key)
+		}
+		if colIndex > len(row.Cells) || row.Cells[colIndex-1] == "" {
+			return "", nil
+		}
+		raw := row.Cells[colIndex-1]
+		if numVal, err := s.parseNumberWithCommas(raw); err == nil {
+			return numVal, nil
+		}
+		return raw, nil
+	}
+
+	// Aggregates: {{sum:L}}, {{avg:L}}, {{count:L}}, {{min:L}}, {{max:L}}
+	if op, column, ok := strings.Cut(key, ":"); ok {
+		return s.placeholderAggregate(ctx, op, column)
+	}
+
+	return nil, fmt.Errorf("unknown placeholder {{%s}}", key)
+}
+
+// placeholderAggregate computes (and caches) an aggregate over a source column
+func (s *ExcelService) placeholderAggregate(ctx *placeholderContext, op, column string) (interface{}, error) {
+	colIndex, err := excelize.ColumnNameToNumber(column)
+	if err != nil {
+		return nil, fmt.Errorf("invalid column in {{%s:%s}}", op, column)
+	}
+
+	cacheKey := op + ":" + column
+	if v, ok := ctx.aggregates[cacheKey]; ok {
+		return v, nil
+	}
+
+	var values []float64
+	for _, row := range ctx.rows {
+		if colIndex > len(row.Cells) || row.Cells[colIndex-1] == "" {
+			continue
+		}
+		if numVal, err := s.parseNumberWithCommas(row.Cells[colIndex-1]); err == nil {
+			values = append(values, numVal)
+		}
+	}
+
+	var result float64
+	switch op {
+	case "sum", "avg", "average":
+		for _, v := range values {
+			result += v
+		}
+		if op != "sum" && len(values) > 0 {
+			result /= float64(len(values))
+		}
+	case "count":
+		result = float64(len(values))
+	case "min", "max":
+		for i, v := range values {
+			if i == 0 || (op == "min" && v < result) || (op == "max" && v > result) {
+				result = v
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported aggregate {{%s:%s}}", op, column)
+	}
+
+	ctx.aggregates[cacheKey] = result
+	return result, nil
+}
+
+// formatPlaceholderValue renders a resolved value inside surrounding text
+func formatPlaceholderValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	default:
+		return fmt.Sprintf("%v", val)
+	}
+}
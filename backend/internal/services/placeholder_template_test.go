@@ -0,0 +1,85 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+
+	"excel-processor/internal/models"
+)
+
+// writePlaceholderFixtures saves a source sheet with three data rows and a report template
+// with header placeholders, a one-row {{#rows}} block and a totals row below it
+func writePlaceholderFixtures(t *testing.T) (sourcePath, templatePath string) {
+	t.Helper()
+	src := excelize.NewFile()
+	src.SetSheetName("Sheet1", "Data")
+	src.SetSheetRow("Data", "A1", &[]interface{}{"Name", "Amount"})
+	src.SetSheetRow("Data", "A2", &[]interface{}{"An", "1,000"})
+	src.SetSheetRow("Data", "A3", &[]interface{}{"Bình", "2,500"})
+	src.SetSheetRow("Data", "A4", &[]interface{}{"Chi", "500"})
+	if err := src.SaveAs("source.xlsx"); err != nil {
+		t.Fatalf("SaveAs returned %v", err)
+	}
+	src.Close()
+
+	tpl := excelize.NewFile()
+	tpl.SetCellValue("Sheet1", "A1", "Báo cáo {{province.name}} - {{unit.code}}")
+	tpl.SetSheetRow("Sheet1", "A3", &[]interface{}{"{{#rows}}{{row.index}}", "{{row.A}}", "{{row.B}}{{/rows}}"})
+	tpl.SetSheetRow("Sheet1", "A4", &[]interface{}{"Tổng", "{{count:B}}", "{{sum:B}}"})
+	if err := tpl.SaveAs("template.xlsx"); err != nil {
+		t.Fatalf("SaveAs returned %v", err)
+	}
+	tpl.Close()
+	return "source.xlsx", "template.xlsx"
+}
+
+func TestFillPlaceholderTemplate(t *testing.T) {
+	t.Chdir(t.TempDir())
+	sourcePath, templatePath := writePlaceholderFixtures(t)
+	province := &models.Province{Name: "Hà Nội", Code: "HN"}
+	unit := &models.Unit{Name: "Ba Đình", Code: "HN-BD"}
+	req := models.PlaceholderReportRequest{SourceSheet: "Data", StartRow: 2}
+
+	outputPath, err := NewExcelService().FillPlaceholderTemplate(sourcePath, templatePath, req, province, unit)
+	if err != nil {
+		t.Fatalf("FillPlaceholderTemplate returned %v", err)
+	}
+	out, err := excelize.OpenFile(outputPath)
+	if err != nil {
+		t.Fatalf("OpenFile returned %v", err)
+	}
+	defer out.Close()
+
+	tests := []struct {
+		cell string
+		want string
+	}{
+		{"A1", "Báo cáo Hà Nội - HN-BD"},
+		{"A3", "1"}, {"B3", "An"}, {"C3", "1000"},
+		{"A5", "3"}, {"B5", "Chi"}, {"C5", "500"},
+		{"A6", "Tổng"}, {"B6", "3"}, {"C6", "4000"},
+	}
+	for _, tt := range tests {
+		if got, _ := out.GetCellValue("Sheet1", tt.cell); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.cell, got, tt.want)
+		}
+	}
+}
+
+func TestFillPlaceholderTemplateErrors(t *testing.T) {
+	t.Chdir(t.TempDir())
+	sourcePath, templatePath := writePlaceholderFixtures(t)
+	province := &models.Province{Name: "Hà Nội", Code: "HN"}
+
+	_, err := NewExcelService().FillPlaceholderTemplate(sourcePath, templatePath, models.PlaceholderReportRequest{SourceSheet: "Data", StartRow: 2}, province, nil)
+	if err == nil || !strings.Contains(err.Error(), "unit.code") {
+		t.Errorf("FillPlaceholderTemplate without a unit returned %v, want an error naming {{unit.code}}", err)
+	}
+
+	_, err = NewExcelService().FillPlaceholderTemplate(sourcePath, templatePath, models.PlaceholderReportRequest{SourceSheet: "Data", StartRow: 9}, province, nil)
+	if err == nil {
+		t.Error("FillPlaceholderTemplate accepted a start row past the data")
+	}
+}
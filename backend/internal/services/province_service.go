@@ -104,3 +104,25 @@ func (s *ProvinceService) GetUnitsByProvince(provinceId uint) []models.Unit {
 	}
 	return []models.Unit{}
 }
+
+// GetProvinceByID returns a province by ID, or nil if it does not exist
+func (s *ProvinceService) GetProvinceByID(provinceId uint) *models.Province {
+	for _, province := range s.GetAllProvinces() {
+		if province.ID == provinceId {
+			return &province
+		}
+	}
+	return nil
+}
+
+// GetUnitByID returns a unit by ID across all provinces, or nil if it does not exist
+func (s *ProvinceService) GetUnitByID(unitId uint) *models.Unit {
+	for _, province := range s.GetAllProvinces() {
+		for _, unit := range s.GetUnitsByProvince(province.ID) {
+			if unit.ID == unitId {
+				return &unit
+			}
+		}
+	}
+	return nil
+}
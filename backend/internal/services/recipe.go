@@ -0,0 +1,463 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+
+	"excel-processor/internal/models"
+)
+
+// recipeRow is one row of the table a recipe works on. Values are keyed by column letter;
+// numbers are float64 and everything else the cell text.
+type recipeRow struct {
+	Number int // Source row number, 1-based (first row of the group after a group_by step)
+	Values map[string]interface{}
+}
+
+// ValidateRecipe checks that every step has what it needs before a recipe is saved, and
+// upper-cases the column letters of the steps
+func ValidateRecipe(steps []models.RecipeStep) error {
+	if len(steps) == 0 {
+		return fmt.Errorf("recipe has no steps")
+	}
+
+	for i := range steps {
+		normalizeRecipeStep(&steps[i])
+	}
+	for i, step := range steps {
+		n := i + 1
+		if err := ValidateRounding(step.Rounding); err != nil {
+			return fmt.Errorf("step %d: %w", n, err)
+		}
+		switch step.Type {
+		case models.RecipeStepRowWise:
+			if len(step.SourceColumns) == 0 || step.TargetColumn == "" {
+				return fmt.Errorf("step %d: source_columns and target_column are required", n)
+			}
+			switch step.Operation {
+			case "add", "subtract", "multiply", "divide", "copy":
+			default:
+				return fmt.Errorf("step %d: unsupported operation: %s", n, step.Operation)
+			}
+			if err := validateColumns(append([]string{step.TargetColumn}, step.SourceColumns...)...); err != nil {
+				return fmt.Errorf("step %d: %w", n, err)
+			}
+		case models.RecipeStepFilter:
+			if err := ValidateRowFilter(recipeFilter(step)); err != nil {
+				return fmt.Errorf("step %d: %w", n, err)
+			}
+		case models.RecipeStepGroupBy:
+			if len(step.GroupBy) == 0 {
+				return fmt.Errorf("step %d: group_by needs at least one column", n)
+			}
+			if err := validateColumns(step.GroupBy...); err != nil {
+				return fmt.Errorf("step %d: %w", n, err)
+			}
+			for _, aggregate := range step.Aggregates {
+				if err := validateColumns(aggregate.Column); err != nil {
+					return fmt.Errorf("step %d: %w", n, err)
+				}
+				if aggregate.TargetColumn != "" {
+					if err := validateColumns(aggregate.TargetColumn); err != nil {
+						return fmt.Errorf("step %d: %w", n, err)
+					}
+				}
+			}
+		case models.RecipeStepTemplate:
+			if n != len(steps) {
+				return fmt.Errorf("step %d: the template step must be the last step", n)
+			}
+			if step.TemplateID == 0 || len(step.Columns) == 0 {
+				return fmt.Errorf("step %d: template_id and columns are required", n)
+			}
+			for from, to := range step.Columns {
+				if err := validateColumns(from, to); err != nil {
+					return fmt.Errorf("step %d: %w", n, err)
+				}
+			}
+		default:
+			return fmt.Errorf("step %d: unsupported step type: %s", n, step.Type)
+		}
+	}
+	return nil
+}
+
+// normalizeRecipeStep upper-cases the column letters of a step
+func normalizeRecipeStep(step *models.RecipeStep) {
+	for i := range step.SourceColumns {
+		step.SourceColumns[i] = strings.ToUpper(strings.TrimSpace(step.SourceColumns[i]))
+	}
+	for i := range step.GroupBy {
+		step.GroupBy[i] = strings.ToUpper(strings.TrimSpace(step.GroupBy[i]))
+	}
+	for i := range step.Aggregates {
+		step.Aggregates[i].Column = strings.ToUpper(strings.TrimSpace(step.Aggregates[i].Column))
+		step.Aggregates[i].TargetColumn = strings.ToUpper(strings.TrimSpace(step.Aggregates[i].TargetColumn))
+	}
+	step.TargetColumn = strings.ToUpper(strings.TrimSpace(step.TargetColumn))
+	step.Column = strings.ToUpper(strings.TrimSpace(step.Column))
+	if len(step.Columns) > 0 {
+		columns := make(map[string]string, len(step.Columns))
+		for from, to := range step.Columns {
+			columns[strings.ToUpper(strings.TrimSpace(from))] = strings.ToUpper(strings.TrimSpace(to))
+		}
+		step.Columns = columns
+	}
+}
+
+// validateColumns checks that each name is a column letter such as L or AB
+func validateColumns(columns ...string) error {
+	for _, column := range columns {
+		if _, err := excelize.ColumnNameToNumber(column); err != nil {
+			return fmt.Errorf("invalid column %q", column)
+		}
+	}
+	return nil
+}
+
+// RecipeTemplateStep returns the template step of a recipe, if it has one
+func RecipeTemplateStep(recipe models.Recipe) *models.RecipeStep {
+	if n := len(recipe.Steps); n > 0 && recipe.Steps[n-1].Type == models.RecipeStepTemplate {
+		return &recipe.Steps[n-1]
+	}
+	return nil
+}
+
+// RunRecipe applies the steps of a recipe to a sheet and returns the path of the output: the
+// filled template (always xlsx) when the recipe ends with a template step, otherwise the
+// resulting table in the given format. Rows settled by a value policy are returned as warnings.
+func (s *ExcelService) RunRecipe(filePath string, recipe models.Recipe, run models.RecipeRunRequest, templatePath, format string) (string, []models.RowWarning, error) {
+	if err := ValidateRecipe(recipe.Steps); err != nil {
+		return "", nil, err
+	}
+
+	sheetName, startRow, endRow := recipe.SheetName, recipe.StartRow, recipe.EndRow
+	if run.SheetName != "" {
+		sheetName = run.SheetName
+	}
+	if run.StartRow > 0 {
+		startRow = run.StartRow
+	}
+	if run.EndRow != nil {
+		endRow = run.EndRow
+	}
+
+	rows, err := s.readRecipeRows(filePath, sheetName, startRow, endRow)
+	if err != nil {
+		return "", nil, err
+	}
+
+	warnings := make([]models.RowWarning, 0)
+	for i, step := range recipe.Steps {
+		switch step.Type {
+		case models.RecipeStepRowWise:
+			var (
+				err          error
+				stepWarnings []models.RowWarning
+			)
+			if rows, stepWarnings, err = s.applyRecipeRowWise(step, rows); err != nil {
+				return "", nil, fmt.Errorf("step %d: %w", i+1, err)
+			}
+			for _, warning := range stepWarnings {
+				warning.Step = i + 1
+				warnings = append(warnings, warning)
+			}
+		case models.RecipeStepFilter:
+			rows = s.applyRecipeFilter(step, rows)
+		case models.RecipeStepGroupBy:
+			rows = s.applyRecipeGroupBy(step, rows)
+		case models.RecipeStepTemplate:
+			outputPath, err := s.writeRecipeTemplate(templatePath, step, rows)
+			if err != nil {
+				return "", nil, fmt.Errorf("step %d: %w", i+1, err)
+			}
+			return outputPath, warnings, nil
+		}
+	}
+
+	outputPath, err := s.WriteExportTable(recipeTable(rows), format, "recipe")
+	if err != nil {
+		return "", nil, err
+	}
+	return outputPath, warnings, nil
+}
+
+// readRecipeRows reads the data rows of a sheet, from startRow (1-based) to endRow. Rows not
+// given default to the detected data table.
+func (s *ExcelService) readRecipeRows(filePath, sheetName string, startRow int, endRow *int) ([]recipeRow, error) {
+	f, err := s.openWorkbook(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	if sheetName == "" {
+		sheetName = f.GetSheetName(0)
+	} else if idx, _ := f.GetSheetIndex(sheetName); idx < 0 {
+		return nil, fmt.Errorf("sheet %s not found", sheetName)
+	}
+
+	sheetRows, err := f.GetRows(sheetName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sheet %s: %w", sheetName, err)
+	}
+
+	// Without a start row the range defaults to the detected data table; an explicit start
+	// row without an end row runs to the end of the sheet
+	region := detectDataRegion(f, sheetName, sheetRows)
+	detected := startRow < 1 && region != nil && region.FirstDataRow > 0
+	if startRow < 1 {
+		startRow = 1
+		if detected {
+			startRow = region.FirstDataRow
+		}
+	}
+	lastRow := len(sheetRows)
+	if endRow != nil && *endRow < lastRow {
+		lastRow = *endRow
+	} else if endRow == nil && detected && region.LastDataRow >= startRow {
+		lastRow = region.LastDataRow
+	}
+	if startRow > lastRow {
+		return nil, fmt.Errorf("invalid start row: %d (sheet has %d rows)", startRow, len(sheetRows))
+	}
+
+	rows := make([]recipeRow, 0, lastRow-startRow+1)
+	for rowNumber := startRow; rowNumber <= lastRow; rowNumber++ {
+		row := recipeRow{Number: rowNumber, Values: make(map[string]interface{})}
+		for colIndex, display := range sheetRows[rowNumber-1] {
+			cell, _ := excelize.CoordinatesToCellName(colIndex+1, rowNumber)
+			text, number, isNumber := typedCellValue(f, sheetName, cell, display)
+			column, _ := excelize.ColumnNumberToName(colIndex + 1)
+			if isNumber {
+				row.Values[column] = number
+			} else if text != "" {
+				row.Values[column] = text
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// applyRecipeRowWise calculates the target column of every row, like CalculateRowWise, and
+// returns the rows left after the skip_row value policy with the warnings of the policies
+func (s *ExcelService) applyRecipeRowWise(step models.RecipeStep, rows []recipeRow) ([]recipeRow, []models.RowWarning, error) {
+	options := rowWiseOptions{
+		OnDivideByZero: step.OnDivideByZero,
+		OnInvalidValue: step.OnInvalidValue,
+		Decimal:        step.Arithmetic == models.ArithmeticDecimal,
+		Rounding:       roundingRule(step.Rounding, step.TargetColumn),
+	}
+	kept := make([]recipeRow, 0, len(rows))
+	var warnings []models.RowWarning
+	for _, row := range rows {
+		if step.Operation == "copy" {
+			row.Values[step.TargetColumn] = row.Values[step.SourceColumns[0]]
+			kept = append(kept, row)
+			continue
+		}
+
+		outcome, err := evaluateRowWise(step.Operation, step.SourceColumns, mapCell(row.Values), row.Number, options)
+		if err != nil {
+			return nil, nil, err
+		}
+		warnings = append(warnings, outcome.warnings...)
+		if outcome.skip {
+			continue
+		}
+		row.Values[step.TargetColumn] = outcome.value
+		kept = append(kept, row)
+	}
+	return kept, warnings, nil
+}
+
+// recipeFilter returns the filter of a filter step: its "filter" clause, or else the single
+// condition given by Column, Operator and Value
+func recipeFilter(step models.RecipeStep) *models.RowFilter {
+	if step.Filter != nil {
+		return step.Filter
+	}
+	return &models.RowFilter{Column: step.Column, Operator: step.Operator, Value: step.Value}
+}
+
+// applyRecipeFilter keeps the rows that match the filter step
+func (s *ExcelService) applyRecipeFilter(step models.RecipeStep, rows []recipeRow) []recipeRow {
+	filter := recipeFilter(step)
+	kept := make([]recipeRow, 0, len(rows))
+	for _, row := range rows {
+		if rowFilterMatches(filter, func(column string) interface{} { return row.Values[column] }) {
+			kept = append(kept, row)
+		}
+	}
+	return kept
+}
+
+// applyRecipeGroupBy keeps one row per distinct combination of the group columns, in order
+// of first appearance, with the aggregates of the group. Other columns are dropped.
+func (s *ExcelService) applyRecipeGroupBy(step models.RecipeStep, rows []recipeRow) []recipeRow {
+	var keys []string
+	groups := make(map[string][]recipeRow)
+	for _, row := range rows {
+		parts := make([]string, len(step.GroupBy))
+		for i, column := range step.GroupBy {
+			parts[i] = cellText(row.Values[column])
+		}
+		key := strings.Join(parts, "\x00")
+		if _, exists := groups[key]; !exists {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], row)
+	}
+
+	grouped := make([]recipeRow, 0, len(keys))
+	for _, key := range keys {
+		members := groups[key]
+		row := recipeRow{Number: members[0].Number, Values: make(map[string]interface{})}
+		for _, column := range step.GroupBy {
+			row.Values[column] = members[0].Values[column]
+		}
+		for _, aggregate := range step.Aggregates {
+			target := aggregate.TargetColumn
+			if target == "" {
+				target = aggregate.Column
+			}
+			row.Values[target] = recipeAggregate(aggregate.Operation, aggregate.Column, members, step.Arithmetic == models.ArithmeticDecimal, roundingRule(step.Rounding, target))
+		}
+		grouped = append(grouped, row)
+	}
+	return grouped
+}
+
+// recipeAggregate aggregates a column over the rows of a group. count counts non-blank
+// cells; the other operations use numeric cells only, summing exactly in decimal mode and
+// rounding by rule when one is given.
+func recipeAggregate(operation, column string, rows []recipeRow, decimal bool, rule *models.RoundingRule) interface{} {
+	total := &numberTotal{decimal: decimal}
+	count := 0
+	minValue, maxValue := math.Inf(1), math.Inf(-1)
+	for _, row := range rows {
+		if operation == "count" {
+			if cellText(row.Values[column]) != "" {
+				count++
+			}
+			continue
+		}
+		value, ok := cellNumber(row.Values[column])
+		if !ok {
+			continue
+		}
+		total.add(value)
+		minValue = math.Min(minValue, value)
+		maxValue = math.Max(maxValue, value)
+	}
+
+	switch operation {
+	case "count":
+		return float64(count)
+	case "sum":
+		return total.sum(rule)
+	}
+	if total.count == 0 {
+		return nil
+	}
+	switch operation {
+	case "avg":
+		return total.average(rule)
+	case "min":
+		return floatResult(minValue, rule)
+	case "max":
+		return floatResult(maxValue, rule)
+	}
+	return nil
+}
+
+// recipeTable lays out recipe rows as an export table with a row_number column followed by
+// the used columns in sheet order
+func recipeTable(rows []recipeRow) ExportTable {
+	used := make(map[string]int)
+	for _, row := range rows {
+		for column := range row.Values {
+			if _, exists := used[column]; !exists {
+				used[column], _ = excelize.ColumnNameToNumber(column)
+			}
+		}
+	}
+	columns := make([]string, 0, len(used))
+	for column := range used {
+		columns = append(columns, column)
+	}
+	sort.Slice(columns, func(i, j int) bool { return used[columns[i]] < used[columns[j]] })
+
+	table := ExportTable{Columns: append([]string{"row_number"}, columns...)}
+	for _, row := range rows {
+		values := []interface{}{row.Number}
+		for _, column := range columns {
+			values = append(values, row.Values[column])
+		}
+		table.Rows = append(table.Rows, values)
+	}
+	return table
+}
+
+// writeRecipeTemplate writes the mapped columns of the rows into a copy of a template,
+// either one after another from StartRow or at each source row plus RowOffset
+func (s *ExcelService) writeRecipeTemplate(templatePath string, step models.RecipeStep, rows []recipeRow) (string, error) {
+	if templatePath == "" {
+		return "", fmt.Errorf("template not specified")
+	}
+
+	f, err := excelize.OpenFile(templatePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open template file %s: %w", templatePath, err)
+	}
+	defer f.Close()
+
+	sheetName := step.TemplateSheet
+	if sheetName == "" {
+		sheetName = f.GetSheetName(0)
+	} else if idx, _ := f.GetSheetIndex(sheetName); idx < 0 {
+		return "", fmt.Errorf("sheet %s not found in template", sheetName)
+	}
+
+	targetRows := make([]int, len(rows))
+	lastTargetRow := 0
+	for i, row := range rows {
+		targetRows[i] = row.Number + step.RowOffset
+		if step.StartRow > 0 {
+			targetRows[i] = step.StartRow + i
+		}
+		if targetRows[i] < 1 {
+			return "", fmt.Errorf("row %d with offset %d is outside the sheet", row.Number, step.RowOffset)
+		}
+		lastTargetRow = max(lastTargetRow, targetRows[i])
+	}
+
+	if _, err := s.prepareTemplateRows(f, sheetName, step.PrototypeRow, step.TemplateEndRow, lastTargetRow); err != nil {
+		return "", fmt.Errorf("failed to extend template rows: %w", err)
+	}
+
+	for i, row := range rows {
+		for from, to := range step.Columns {
+			cell := fmt.Sprintf("%s%d", to, targetRows[i])
+			if err := f.SetCellValue(sheetName, cell, templateCellValue(row.Values[from])); err != nil {
+				return "", fmt.Errorf("failed to write to cell %s: %w", cell, err)
+			}
+		}
+	}
+
+	if err := os.MkdirAll("exports", 0755); err != nil {
+		return "", fmt.Errorf("failed to create exports directory: %w", err)
+	}
+	outputPath := fmt.Sprintf("exports/recipe_%d.xlsx", time.Now().UnixNano())
+	if err := f.SaveAs(outputPath); err != nil {
+		return "", fmt.Errorf("failed to save template file: %w", err)
+	}
+	return outputPath, nil
+}
@@ -0,0 +1,161 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+
+	"excel-processor/internal/models"
+)
+
+func TestValidateRecipe(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []models.RecipeStep
+	}{
+		{"no steps", nil},
+		{"unknown step type", []models.RecipeStep{{Type: "pivot"}}},
+		{"rowwise without a target", []models.RecipeStep{{Type: models.RecipeStepRowWise, SourceColumns: []string{"B"}, Operation: "add"}}},
+		{"unsupported operation", []models.RecipeStep{{Type: models.RecipeStepRowWise, SourceColumns: []string{"B"}, TargetColumn: "C", Operation: "power"}}},
+		{"invalid column", []models.RecipeStep{{Type: models.RecipeStepRowWise, SourceColumns: []string{"1B"}, TargetColumn: "C", Operation: "add"}}},
+		{"unsupported filter operator", []models.RecipeStep{{Type: models.RecipeStepFilter, Column: "B", Operator: "like"}}},
+		{"group_by without columns", []models.RecipeStep{{Type: models.RecipeStepGroupBy}}},
+		{"template step not last", []models.RecipeStep{
+			{Type: models.RecipeStepTemplate, TemplateID: 1, Columns: map[string]string{"A": "A"}},
+			{Type: models.RecipeStepFilter, Column: "A", Operator: "not_blank"},
+		}},
+		{"template step without columns", []models.RecipeStep{{Type: models.RecipeStepTemplate, TemplateID: 1}}},
+	}
+	for _, tt := range tests {
+		if err := ValidateRecipe(tt.steps); err == nil {
+			t.Errorf("%s: ValidateRecipe returned nil, want an error", tt.name)
+		}
+	}
+
+	steps := []models.RecipeStep{
+		{Type: models.RecipeStepRowWise, SourceColumns: []string{" b", "c"}, TargetColumn: "d", Operation: "add"},
+		{Type: models.RecipeStepGroupBy, GroupBy: []string{"a"}, Aggregates: []models.RecipeAggregate{{Column: "d", Operation: "sum"}}},
+		{Type: models.RecipeStepTemplate, TemplateID: 1, Columns: map[string]string{"a": "b"}},
+	}
+	if err := ValidateRecipe(steps); err != nil {
+		t.Fatalf("ValidateRecipe returned %v", err)
+	}
+	if !slices.Equal(steps[0].SourceColumns, []string{"B", "C"}) || steps[0].TargetColumn != "D" || steps[1].GroupBy[0] != "A" || steps[1].Aggregates[0].Column != "D" || steps[2].Columns["A"] != "B" {
+		t.Errorf("columns were not upper-cased: %+v", steps)
+	}
+}
+
+// writeRecipeSource saves a sheet of allowances by department below a header row
+func writeRecipeSource(t *testing.T) string {
+	t.Helper()
+	f := excelize.NewFile()
+	defer f.Close()
+	rows := [][]interface{}{
+		{"Phòng", "Lương", "Phụ cấp"},
+		{"KT", 1000, 200},
+		{"NS", 500, 100},
+		{"KT", 2000, 300},
+		{"TV", 100, 50},
+	}
+	for i, row := range rows {
+		f.SetSheetRow("Sheet1", fmt.Sprintf("A%d", i+1), &row)
+	}
+	if err := f.SaveAs("source.xlsx"); err != nil {
+		t.Fatalf("SaveAs returned %v", err)
+	}
+	return "source.xlsx"
+}
+
+func TestRunRecipe(t *testing.T) {
+	t.Chdir(t.TempDir())
+	source := writeRecipeSource(t)
+	s := NewExcelService()
+	endRow := 4
+	recipe := models.Recipe{
+		Name: "Tổng theo phòng",
+		Steps: []models.RecipeStep{
+			{Type: models.RecipeStepRowWise, SourceColumns: []string{"B", "C"}, TargetColumn: "D", Operation: "add"},
+			{Type: models.RecipeStepFilter, Column: "D", Operator: "gte", Value: 500.0},
+			{Type: models.RecipeStepGroupBy, GroupBy: []string{"A"}, Aggregates: []models.RecipeAggregate{
+				{Column: "D", Operation: "sum"},
+				{Column: "A", Operation: "count", TargetColumn: "E"},
+			}},
+		},
+	}
+
+	tests := []struct {
+		name string
+		run  models.RecipeRunRequest
+		want string
+	}{
+		{"detected rows", models.RecipeRunRequest{FileID: 1}, "\xEF\xBB\xBFrow_number;A;D;E\r\n2;KT;3500;2\r\n3;NS;600;1\r\n"},
+		{"rows of the run", models.RecipeRunRequest{FileID: 1, StartRow: 3, EndRow: &endRow}, "\xEF\xBB\xBFrow_number;A;D;E\r\n3;NS;600;1\r\n4;KT;2300;1\r\n"},
+	}
+	for _, tt := range tests {
+		outputPath, warnings, err := s.RunRecipe(source, recipe, tt.run, "", "csv")
+		if err != nil {
+			t.Fatalf("%s: RunRecipe returned %v", tt.name, err)
+		}
+		if len(warnings) != 0 {
+			t.Errorf("%s: warnings = %+v, want none", tt.name, warnings)
+		}
+		data, err := os.ReadFile(outputPath)
+		if err != nil {
+			t.Fatalf("%s: ReadFile returned %v", tt.name, err)
+		}
+		if string(data) != tt.want {
+			t.Errorf("%s: output = %q, want %q", tt.name, data, tt.want)
+		}
+	}
+
+	if _, _, err := s.RunRecipe(source, recipe, models.RecipeRunRequest{FileID: 1, SheetName: "Missing"}, "", "csv"); err == nil {
+		t.Error("RunRecipe on a missing sheet returned nil, want an error")
+	}
+}
+
+func TestRunRecipeTemplateStep(t *testing.T) {
+	t.Chdir(t.TempDir())
+	source := writeRecipeSource(t)
+	template := excelize.NewFile()
+	template.SetSheetRow("Sheet1", "A1", &[]interface{}{"Phòng", "Tổng thu nhập"})
+	if err := template.SaveAs("template.xlsx"); err != nil {
+		t.Fatalf("SaveAs returned %v", err)
+	}
+	template.Close()
+
+	recipe := models.Recipe{
+		Name: "Mẫu tổng hợp",
+		Steps: []models.RecipeStep{
+			{Type: models.RecipeStepRowWise, SourceColumns: []string{"B", "C"}, TargetColumn: "D", Operation: "add"},
+			{Type: models.RecipeStepGroupBy, GroupBy: []string{"A"}, Aggregates: []models.RecipeAggregate{{Column: "D", Operation: "sum"}}},
+			{Type: models.RecipeStepTemplate, TemplateID: 1, Columns: map[string]string{"a": "a", "d": "b"}, StartRow: 2},
+		},
+	}
+	outputPath, _, err := NewExcelService().RunRecipe(source, recipe, models.RecipeRunRequest{FileID: 1}, "template.xlsx", "csv")
+	if err != nil {
+		t.Fatalf("RunRecipe returned %v", err)
+	}
+	f, err := excelize.OpenFile(outputPath)
+	if err != nil {
+		t.Fatalf("OpenFile returned %v", err)
+	}
+	defer f.Close()
+
+	rows, _ := f.GetRows("Sheet1")
+	want := [][]string{{"Phòng", "Tổng thu nhập"}, {"KT", "3500"}, {"NS", "600"}, {"TV", "150"}}
+	if len(rows) != len(want) {
+		t.Fatalf("template rows = %q, want %q", rows, want)
+	}
+	for i := range want {
+		if !slices.Equal(rows[i], want[i]) {
+			t.Errorf("row %d = %q, want %q", i+1, rows[i], want[i])
+		}
+	}
+
+	if _, _, err := NewExcelService().RunRecipe(source, recipe, models.RecipeRunRequest{FileID: 1}, "", "csv"); err == nil {
+		t.Error("RunRecipe without a template returned nil, want an error")
+	}
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"excel-processor/internal/models"
+)
+
+// ValidateRowFilter checks a filter and its nested conditions, and upper-cases their column
+// letters. A nil filter is valid and matches every row.
+func ValidateRowFilter(filter *models.RowFilter) error {
+	if filter == nil {
+		return nil
+	}
+
+	groups := len(filter.All) + len(filter.Any)
+	if groups > 0 {
+		if filter.Column != "" || filter.Operator != "" || (len(filter.All) > 0 && len(filter.Any) > 0) {
+			return fmt.Errorf("a filter is either one condition, \"all\" or \"any\"")
+		}
+		for i := range filter.All {
+			if err := ValidateRowFilter(&filter.All[i]); err != nil {
+				return err
+			}
+		}
+		for i := range filter.Any {
+			if err := ValidateRowFilter(&filter.Any[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	filter.Column = strings.ToUpper(strings.TrimSpace(filter.Column))
+	if err := validateColumns(filter.Column); err != nil {
+		return fmt.Errorf("filter: %w", err)
+	}
+	switch filter.Operator {
+	case "eq", "ne", "contains", "not_contains", "starts_with", "blank", "not_blank":
+	case "gt", "gte", "lt", "lte":
+		if _, ok := cellNumber(filter.Value); !ok {
+			return fmt.Errorf("filter: operator %s needs a numeric value", filter.Operator)
+		}
+	case "in", "not_in":
+		if len(filter.Values) == 0 {
+			return fmt.Errorf("filter: operator %s needs a list of values", filter.Operator)
+		}
+	default:
+		return fmt.Errorf("filter: unsupported operator: %s", filter.Operator)
+	}
+	return nil
+}
+
+// rowFilterMatches reports whether a row matches a filter; cell returns the value of a
+// column of the row. A nil filter matches every row.
+func rowFilterMatches(filter *models.RowFilter, cell func(column string) interface{}) bool {
+	if filter == nil {
+		return true
+	}
+	if len(filter.All) > 0 {
+		for i := range filter.All {
+			if !rowFilterMatches(&filter.All[i], cell) {
+				return false
+			}
+		}
+		return true
+	}
+	if len(filter.Any) > 0 {
+		for i := range filter.Any {
+			if rowFilterMatches(&filter.Any[i], cell) {
+				return true
+			}
+		}
+		return false
+	}
+	return conditionMatches(filter, cell(filter.Column))
+}
+
+// conditionMatches compares a cell with a single condition. Equality compares numbers when
+// both sides are numeric and text (ignoring case) otherwise; ordering operators only match
+// numeric cells.
+func conditionMatches(filter *models.RowFilter, value interface{}) bool {
+	text := strings.ToLower(cellText(value))
+	switch filter.Operator {
+	case "blank":
+		return text == ""
+	case "not_blank":
+		return text != ""
+	case "contains":
+		return strings.Contains(text, strings.ToLower(cellText(filter.Value)))
+	case "not_contains":
+		return !strings.Contains(text, strings.ToLower(cellText(filter.Value)))
+	case "starts_with":
+		return strings.HasPrefix(text, strings.ToLower(cellText(filter.Value)))
+	case "eq":
+		return cellEquals(value, filter.Value)
+	case "ne":
+		return !cellEquals(value, filter.Value)
+	case "in", "not_in":
+		found := false
+		for _, candidate := range filter.Values {
+			if cellEquals(value, candidate) {
+				found = true
+				break
+			}
+		}
+		return found == (filter.Operator == "in")
+	}
+
+	a, ok := cellNumber(value)
+	if !ok {
+		return false
+	}
+	b, _ := cellNumber(filter.Value)
+	switch filter.Operator {
+	case "gt":
+		return a > b
+	case "gte":
+		return a >= b
+	case "lt":
+		return a < b
+	case "lte":
+		return a <= b
+	}
+	return false
+}
+
+// cellEquals compares two values as numbers when both are numeric, otherwise as text
+// ignoring case
+func cellEquals(a, b interface{}) bool {
+	if x, ok := cellNumber(a); ok {
+		if y, ok := cellNumber(b); ok {
+			return x == y
+		}
+	}
+	return strings.EqualFold(cellText(a), cellText(b))
+}
+
+// cellNumber returns the numeric value of a cell or filter value. Text is parsed the way
+// row-wise calculations parse it, so "1,200" is 1200.
+func cellNumber(v interface{}) (float64, bool) {
+	switch val := v.(type) {
+	case float64:
+		return val, true
+	case int:
+		return float64(val), true
+	case string:
+		if n, err := strconv.ParseFloat(strings.TrimSpace(strings.ReplaceAll(val, ",", "")), 64); err == nil {
+			return n, true
+		}
+	}
+	return 0, false
+}
+
+// cellText returns a value as text for comparisons and group keys
+func cellText(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	default:
+		return strings.TrimSpace(fmt.Sprint(val))
+	}
+}
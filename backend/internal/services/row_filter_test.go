@@ -0,0 +1,82 @@
+package services
+
+import (
+	"testing"
+
+	"excel-processor/internal/models"
+)
+
+func TestValidateRowFilter(t *testing.T) {
+	invalid := map[string]*models.RowFilter{
+		"unknown operator":          {Column: "A", Operator: "like"},
+		"invalid column":            {Column: "1A", Operator: "eq"},
+		"ordering without a number": {Column: "B", Operator: "gt", Value: "nhiều"},
+		"in without values":         {Column: "A", Operator: "in"},
+		"condition and group":       {Column: "A", Operator: "eq", All: []models.RowFilter{{Column: "B", Operator: "blank"}}},
+		"all and any":               {All: []models.RowFilter{{Column: "A", Operator: "blank"}}, Any: []models.RowFilter{{Column: "B", Operator: "blank"}}},
+		"invalid nested condition":  {Any: []models.RowFilter{{Column: "A", Operator: "blank"}, {Column: "B", Operator: "between"}}},
+	}
+	for name, filter := range invalid {
+		if err := ValidateRowFilter(filter); err == nil {
+			t.Errorf("%s: ValidateRowFilter returned nil, want an error", name)
+		}
+	}
+
+	if err := ValidateRowFilter(nil); err != nil {
+		t.Errorf("ValidateRowFilter(nil) returned %v", err)
+	}
+	filter := &models.RowFilter{All: []models.RowFilter{{Column: " b ", Operator: "gte", Value: "1,000"}}}
+	if err := ValidateRowFilter(filter); err != nil {
+		t.Fatalf("ValidateRowFilter returned %v", err)
+	}
+	if filter.All[0].Column != "B" {
+		t.Errorf("nested column = %q, want B", filter.All[0].Column)
+	}
+}
+
+func TestRowFilterMatches(t *testing.T) {
+	row := map[string]interface{}{"A": "Phòng Kế toán", "B": 1500.0, "C": "1,200", "E": "KT"}
+	cell := func(column string) interface{} { return row[column] }
+
+	tests := []struct {
+		name   string
+		filter *models.RowFilter
+		want   bool
+	}{
+		{"nil filter", nil, true},
+		{"eq ignores case", &models.RowFilter{Column: "E", Operator: "eq", Value: "kt"}, true},
+		{"eq compares numbers", &models.RowFilter{Column: "C", Operator: "eq", Value: 1200.0}, true},
+		{"ne", &models.RowFilter{Column: "E", Operator: "ne", Value: "KT"}, false},
+		{"gt", &models.RowFilter{Column: "B", Operator: "gt", Value: 1000.0}, true},
+		{"lte", &models.RowFilter{Column: "B", Operator: "lte", Value: 1000.0}, false},
+		{"gte on number text", &models.RowFilter{Column: "C", Operator: "gte", Value: 1200.0}, true},
+		{"ordering on text", &models.RowFilter{Column: "A", Operator: "lt", Value: 1.0}, false},
+		{"contains", &models.RowFilter{Column: "A", Operator: "contains", Value: "kế toán"}, true},
+		{"not_contains", &models.RowFilter{Column: "A", Operator: "not_contains", Value: "nhân sự"}, true},
+		{"starts_with", &models.RowFilter{Column: "A", Operator: "starts_with", Value: "phòng"}, true},
+		{"blank", &models.RowFilter{Column: "D", Operator: "blank"}, true},
+		{"not_blank", &models.RowFilter{Column: "D", Operator: "not_blank"}, false},
+		{"in", &models.RowFilter{Column: "E", Operator: "in", Values: []interface{}{"NS", "kt"}}, true},
+		{"not_in", &models.RowFilter{Column: "B", Operator: "not_in", Values: []interface{}{1500.0}}, false},
+		{"all", &models.RowFilter{All: []models.RowFilter{
+			{Column: "E", Operator: "eq", Value: "KT"},
+			{Column: "B", Operator: "gt", Value: 2000.0},
+		}}, false},
+		{"any", &models.RowFilter{Any: []models.RowFilter{
+			{Column: "E", Operator: "eq", Value: "NS"},
+			{Column: "B", Operator: "gt", Value: 1000.0},
+		}}, true},
+		{"nested", &models.RowFilter{All: []models.RowFilter{
+			{Column: "A", Operator: "not_blank"},
+			{Any: []models.RowFilter{
+				{Column: "E", Operator: "eq", Value: "NS"},
+				{Column: "E", Operator: "eq", Value: "TV"},
+			}},
+		}}, false},
+	}
+	for _, tt := range tests {
+		if got := rowFilterMatches(tt.filter, cell); got != tt.want {
+			t.Errorf("%s: rowFilterMatches = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/xuri/excelize/v2"
+	"excel-processor/internal/models"
+)
+
+const (
+	exportMinColumnWidth = 8.0
+	exportMaxColumnWidth = 60.0
+)
+
+// exportNumberFormats are the Excel number formats of typed export columns
+var exportNumberFormats = map[string]string{
+	"text":     "@",
+	"number":   "#,##0.00",
+	"integer":  "#,##0",
+	"currency": `#,##0 "₫"`,
+	"percent":  "0.00%",
+	"date":     "dd/mm/yyyy",
+}
+
+// exportDateLayouts are the accepted layouts of date values sent as strings
+var exportDateLayouts = []string{"2006-01-02", time.RFC3339, "2006-01-02 15:04:05", "02/01/2006"}
+
+// ResolveExportColumns returns the requested columns, or one untyped column per data key in
+// sorted order so that repeated exports of the same data have the same layout
+func ResolveExportColumns(columns []models.ExportColumn, data []map[string]interface{}) []models.ExportColumn {
+	if len(columns) > 0 {
+		return columns
+	}
+	keys := DataColumns(data)
+	resolved := make([]models.ExportColumn, len(keys))
+	for i, key := range keys {
+		resolved[i] = models.ExportColumn{Key: key}
+	}
+	return resolved
+}
+
+// ExportTableFromRequest lays out a generic export request as a table with the column headers
+func (s *ExcelService) ExportTableFromRequest(req models.ExportRequest) ExportTable {
+	columns := ResolveExportColumns(req.Columns, req.Data)
+	table := ExportTable{Columns: make([]string, len(columns)), Rows: s.exportRows(columns, req.Data)}
+	for i, column := range columns {
+		table.Columns[i] = exportHeader(column)
+	}
+	return table
+}
+
+// exportRows returns the data rows as values in column order, converted to each column's type
+func (s *ExcelService) exportRows(columns []models.ExportColumn, data []map[string]interface{}) [][]interface{} {
+	rows := make([][]interface{}, len(data))
+	for i, item := range data {
+		row := make([]interface{}, len(columns))
+		for j, column := range columns {
+			row[j] = s.exportValue(column, item[column.Key])
+		}
+		rows[i] = row
+	}
+	return rows
+}
+
+// exportValue converts a value to its column type. Values that cannot be converted are
+// written unchanged rather than dropped.
+func (s *ExcelService) exportValue(column models.ExportColumn, v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+
+	switch column.Type {
+	case "number", "integer", "currency", "percent":
+		switch val := v.(type) {
+		case json.Number:
+			if f, err := val.Float64(); err == nil {
+				return f
+			}
+		case string:
+			if f, err := s.parseNumberWithCommas(val); err == nil {
+				return f
+			}
+		}
+	case "date":
+		if str, ok := v.(string); ok {
+			for _, layout := range exportDateLayouts {
+				if t, err := time.Parse(layout, str); err == nil {
+					return t
+				}
+			}
+		}
+	case "text":
+		return formatPlaceholderValue(v)
+	}
+	return v
+}
+
+// exportHeader returns the header text of a column
+func exportHeader(column models.ExportColumn) string {
+	if column.Header != "" {
+		return column.Header
+	}
+	return column.Key
+}
+
+// writeStyledSheet writes a header row and data rows starting at A1 with a bold header,
+// per-column number formats, fitted column widths and the header row frozen
+func writeStyledSheet(f *excelize.File, sheetName string, columns []models.ExportColumn, rows [][]interface{}, freezeColumns int) error {
+	if len(columns) == 0 {
+		return fmt.Errorf("no columns to export")
+	}
+
+	headerStyle, err := f.NewStyle(&excelize.Style{
+		Font:      &excelize.Font{Bold: true},
+		Fill:      excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"D9E1F2"}},
+		Alignment: &excelize.Alignment{Horizontal: "center", Vertical: "center", WrapText: true},
+		Border: []excelize.Border{
+			{Type: "left", Color: "808080", Style: 1},
+			{Type: "top", Color: "808080", Style: 1},
+			{Type: "right", Color: "808080", Style: 1},
+			{Type: "bottom", Color: "808080", Style: 1},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create header style: %w", err)
+	}
+
+	header := make([]interface{}, len(columns))
+	for i, column := range columns {
+		header[i] = exportHeader(column)
+	}
+	if err := f.SetSheetRow(sheetName, "A1", &header); err != nil {
+		return fmt.Errorf("failed to write header: %w", err)
+	}
+	lastHeader, _ := excelize.CoordinatesToCellName(len(columns), 1)
+	if err := f.SetCellStyle(sheetName, "A1", lastHeader, headerStyle); err != nil {
+		return fmt.Errorf("failed to style header: %w", err)
+	}
+
+	for i, row := range rows {
+		cell, _ := excelize.CoordinatesToCellName(1, i+2)
+		values := row
+		if err := f.SetSheetRow(sheetName, cell, &values); err != nil {
+			return fmt.Errorf("failed to write row %d: %w", i+2, err)
+		}
+	}
+
+	for i, column := range columns {
+		colName, _ := excelize.ColumnNumberToName(i + 1)
+
+		numFmt := column.NumberFormat
+		if numFmt == "" {
+			numFmt = exportNumberFormats[column.Type]
+		}
+		if numFmt != "" && len(rows) > 0 {
+			style, err := f.NewStyle(&excelize.Style{CustomNumFmt: &numFmt})
+			if err != nil {
+				return fmt.Errorf("invalid number format for column %s: %w", column.Key, err)
+			}
+			if err := f.SetCellStyle(sheetName, colName+"2", fmt.Sprintf("%s%d", colName, len(rows)+1), style); err != nil {
+				return fmt.Errorf("failed to style column %s: %w", column.Key, err)
+			}
+		}
+
+		width := column.Width
+		if width <= 0 {
+			width = fitColumnWidth(column, rows, i)
+		}
+		if err := f.SetColWidth(sheetName, colName, colName, width); err != nil {
+			return fmt.Errorf("failed to set width of column %s: %w", column.Key, err)
+		}
+	}
+
+	// Keep the header row, and optionally the leading columns, visible while scrolling
+	if freezeColumns < 0 || freezeColumns >= len(columns) {
+		freezeColumns = 0
+	}
+	topLeft, _ := excelize.CoordinatesToCellName(freezeColumns+1, 2)
+	activePane := "bottomLeft"
+	if freezeColumns > 0 {
+		activePane = "bottomRight"
+	}
+	if err := f.SetPanes(sheetName, &excelize.Panes{
+		Freeze:      true,
+		XSplit:      freezeColumns,
+		YSplit:      1,
+		TopLeftCell: topLeft,
+		ActivePane:  activePane,
+	}); err != nil {
+		return fmt.Errorf("failed to freeze header: %w", err)
+	}
+
+	return nil
+}
+
+// fitColumnWidth estimates a column width in characters from its header and values
+func fitColumnWidth(column models.ExportColumn, rows [][]interface{}, index int) float64 {
+	// Bold header text is slightly wider than body text
+	width := float64(utf8.RuneCountInString(exportHeader(column))) * 1.1
+	for _, row := range rows {
+		var length int
+		switch val := row[index].(type) {
+		case nil:
+			continue
+		case time.Time:
+			length = len("dd/mm/yyyy")
+		case float64, int, int64:
+			// Room for thousand separators and the currency suffix
+			digits := len(formatCSVValue(val))
+			length = digits + digits/3 + 2
+		default:
+			length = utf8.RuneCountInString(formatPlaceholderValue(val))
+		}
+		if float64(length) > width {
+			width = float64(length)
+		}
+	}
+
+	width += 2
+	if width < exportMinColumnWidth {
+		return exportMinColumnWidth
+	}
+	if width > exportMaxColumnWidth {
+		return exportMaxColumnWidth
+	}
+	return width
+}
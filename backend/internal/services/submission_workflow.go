@@ -0,0 +1,26 @@
+package services
+
+import (
+	"excel-processor/internal/models"
+)
+
+// submissionTransitions lists the statuses a submission may move to from each status.
+// Approved submissions are final; rejected ones are reopened by uploading a new file,
+// which moves them back to submitted.
+var submissionTransitions = map[string][]string{
+	models.SubmissionPending:   {models.SubmissionSubmitted},
+	models.SubmissionSubmitted: {models.SubmissionSubmitted, models.SubmissionValidated, models.SubmissionRejected, models.SubmissionApproved},
+	models.SubmissionValidated: {models.SubmissionSubmitted, models.SubmissionRejected, models.SubmissionApproved},
+	models.SubmissionRejected:  {models.SubmissionSubmitted},
+	models.SubmissionApproved:  {},
+}
+
+// CanTransition reports whether a submission may move from one status to another
+func CanTransition(from, to string) bool {
+	for _, allowed := range submissionTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,34 @@
+package services
+
+import (
+	"testing"
+
+	"excel-processor/internal/models"
+)
+
+func TestCanTransition(t *testing.T) {
+	statuses := []string{models.SubmissionPending, models.SubmissionSubmitted, models.SubmissionValidated, models.SubmissionRejected, models.SubmissionApproved}
+	allowed := map[string][]string{
+		models.SubmissionPending:   {models.SubmissionSubmitted},
+		models.SubmissionSubmitted: {models.SubmissionSubmitted, models.SubmissionValidated, models.SubmissionRejected, models.SubmissionApproved},
+		models.SubmissionValidated: {models.SubmissionSubmitted, models.SubmissionRejected, models.SubmissionApproved},
+		// A rejected submission is reopened by a new upload only
+		models.SubmissionRejected: {models.SubmissionSubmitted},
+		// Approved is final
+		models.SubmissionApproved: nil,
+	}
+	for _, from := range statuses {
+		for _, to := range statuses {
+			want := false
+			for _, status := range allowed[from] {
+				want = want || status == to
+			}
+			if got := CanTransition(from, to); got != want {
+				t.Errorf("CanTransition(%s, %s) = %v, want %v", from, to, got, want)
+			}
+		}
+	}
+	if CanTransition("unknown", models.SubmissionSubmitted) {
+		t.Error("CanTransition allowed a move from an unknown status")
+	}
+}
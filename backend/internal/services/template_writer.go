@@ -0,0 +1,301 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// rangeRefPattern matches A1-style ranges such as L11:L20 or $L$11:$L$20
+var rangeRefPattern = regexp.MustCompile(`(\$?[A-Z]{1,3}\$?)(\d+):(\$?[A-Z]{1,3}\$?)(\d+)`)
+
+// templateRowBlock describes the pre-formatted data rows of a template sheet.
+// Rows needed beyond LastRow are cloned from PrototypeRow and inserted after LastRow,
+// pushing totals/signature rows down.
+type templateRowBlock struct {
+	Sheet         string
+	PrototypeRow  int
+	PrototypeRows int  // Rows cloned together starting at PrototypeRow (0 means 1)
+	LastRow       int
+	KeepValues    bool // Keep the prototype's constant values in clones instead of blanking them
+}
+
+// height returns the number of rows cloned as one unit
+func (b templateRowBlock) height() int {
+	if b.PrototypeRows < 1 {
+		return 1
+	}
+	return b.PrototypeRows
+}
+
+// prepareTemplateRows makes sure the template sheet has formatted rows up to neededLastRow.
+// It is a no-op when no prototype row is given or the template already has enough rows.
+func (s *ExcelService) prepareTemplateRows(f *excelize.File, sheet string, prototypeRow, lastRow, neededLastRow int) (int, error) {
+	if prototypeRow <= 0 {
+		return 0, nil
+	}
+
+	block, err := s.detectTemplateBlock(f, sheet, prototypeRow)
+	if err != nil {
+		return 0, err
+	}
+	if lastRow > 0 {
+		if lastRow < prototypeRow {
+			return 0, fmt.Errorf("template end row %d is above prototype row %d", lastRow, prototypeRow)
+		}
+		block.LastRow = lastRow
+	}
+
+	return s.insertTemplateRows(f, block, neededLastRow)
+}
+
+// detectTemplateBlock finds the last pre-formatted data row below the prototype row.
+// The block ends where a totals row starts aggregating it (e.g. =SUM(L11:L20) ends it at 20);
+// without a totals row the block runs to the last used row of the sheet.
+func (s *ExcelService) detectTemplateBlock(f *excelize.File, sheet string, prototypeRow int) (templateRowBlock, error) {
+	block := templateRowBlock{Sheet: sheet, PrototypeRow: prototypeRow, LastRow: prototypeRow}
+
+	rows, err := f.GetRows(sheet)
+	if err != nil {
+		return block, fmt.Errorf("failed to read sheet %s: %w", sheet, err)
+	}
+	maxCol := len(s.getAllExcelColumns(f, sheet))
+
+	for row := prototypeRow + 1; row <= len(rows); row++ {
+		for col := 1; col <= maxCol; col++ {
+			cell, _ := excelize.CoordinatesToCellName(col, row)
+			formula, _ := f.GetCellFormula(sheet, cell)
+			if formula == "" {
+				continue
+			}
+			for _, m := range rangeRefPattern.FindAllStringSubmatch(formula, -1) {
+				start, _ := strconv.Atoi(m[2])
+				end, _ := strconv.Atoi(m[4])
+				if start <= prototypeRow && end >= prototypeRow && end < row {
+					block.LastRow = end
+					return block, nil
+				}
+			}
+		}
+	}
+
+	if len(rows) > block.LastRow {
+		block.LastRow = len(rows)
+	}
+	return block, nil
+}
+
+// insertTemplateRows clones the prototype row(s) (style, height, formulas, data validation,
+// conditional formats, single-row merges) until the block reaches neededLastRow, then
+// extends ranges that ended at the old last row. It returns the number of inserted rows.
+func (s *ExcelService) insertTemplateRows(f *excelize.File, block templateRowBlock, neededLastRow int) (int, error) {
+	count := neededLastRow - block.LastRow
+	if count <= 0 {
+		return 0, nil
+	}
+	if block.PrototypeRow+block.height()-1 > block.LastRow {
+		return 0, fmt.Errorf("prototype row %d is below the template block ending at row %d", block.PrototypeRow, block.LastRow)
+	}
+
+	sheet := block.Sheet
+	maxCol := len(s.getAllExcelColumns(f, sheet))
+
+	// Remember which prototype cells hold constants so the clones start out blank
+	constantCols := make([][]int, block.height())
+	for offset := range constantCols {
+		if block.KeepValues {
+			break
+		}
+		for col := 1; col <= maxCol; col++ {
+			cell, _ := excelize.CoordinatesToCellName(col, block.PrototypeRow+offset)
+			if formula, _ := f.GetCellFormula(sheet, cell); formula != "" {
+				continue
+			}
+			if value, _ := f.GetCellValue(sheet, cell); value != "" {
+				constantCols[offset] = append(constantCols[offset], col)
+			}
+		}
+	}
+
+	for i := 1; i <= count; i++ {
+		row := block.LastRow + i
+		offset := (i - 1) % block.height()
+		if err := f.DuplicateRowTo(sheet, block.PrototypeRow+offset, row); err != nil {
+			return 0, fmt.Errorf("failed to insert row %d: %w", row, err)
+		}
+		for _, col := range constantCols[offset] {
+			cell, _ := excelize.CoordinatesToCellName(col, row)
+			if err := f.SetCellValue(sheet, cell, nil); err != nil {
+				return 0, fmt.Errorf("failed to clear cell %s: %w", cell, err)
+			}
+		}
+	}
+
+	newLastRow := block.LastRow + count
+	if err := s.extendTemplateFormulas(f, block, newLastRow, maxCol); err != nil {
+		return 0, err
+	}
+	if err := s.extendTemplateMerges(f, block, newLastRow); err != nil {
+		return 0, err
+	}
+	if err := s.extendTemplateValidations(f, block, newLastRow); err != nil {
+		return 0, err
+	}
+	if err := s.extendTemplateConditionalFormats(f, block, newLastRow); err != nil {
+		return 0, err
+	}
+
+	// Cached totals are stale now, let Excel recalculate on open
+	fullCalc := true
+	if err := f.SetCalcProps(&excelize.CalcPropsOptions{FullCalcOnLoad: &fullCalc}); err != nil {
+		return 0, fmt.Errorf("failed to set calculation properties: %w", err)
+	}
+
+	return count, nil
+}
+
+// extendTemplateFormulas rewrites ranges ending at the old last row (e.g. SUM(L11:L20))
+// in cells outside the data block so they cover the inserted rows
+func (s *ExcelService) extendTemplateFormulas(f *excelize.File, block templateRowBlock, newLastRow, maxCol int) error {
+	rows, err := f.GetRows(block.Sheet)
+	if err != nil {
+		return fmt.Errorf("failed to read sheet %s: %w", block.Sheet, err)
+	}
+
+	for row := 1; row <= len(rows); row++ {
+		if row >= block.PrototypeRow && row <= newLastRow {
+			continue
+		}
+		for col := 1; col <= maxCol; col++ {
+			cell, _ := excelize.CoordinatesToCellName(col, row)
+			formula, _ := f.GetCellFormula(block.Sheet, cell)
+			if formula == "" {
+				continue
+			}
+			extended := extendRangeRefs(formula, block.LastRow, newLastRow)
+			if extended == formula {
+				continue
+			}
+			if err := f.SetCellFormula(block.Sheet, cell, extended); err != nil {
+				return fmt.Errorf("failed to update formula in %s: %w", cell, err)
+			}
+		}
+	}
+	return nil
+}
+
+// extendTemplateMerges stretches vertical merged ranges that ended at the old last row
+func (s *ExcelService) extendTemplateMerges(f *excelize.File, block templateRowBlock, newLastRow int) error {
+	merges, err := f.GetMergeCells(block.Sheet)
+	if err != nil {
+		return fmt.Errorf("failed to read merged cells: %w", err)
+	}
+
+	for _, merge := range merges {
+		startCol, startRow, err := excelize.CellNameToCoordinates(merge.GetStartAxis())
+		if err != nil {
+			continue
+		}
+		endCol, endRow, err := excelize.CellNameToCoordinates(merge.GetEndAxis())
+		if err != nil || endRow != block.LastRow || startRow == endRow {
+			continue
+		}
+
+		newEnd, _ := excelize.CoordinatesToCellName(endCol, newLastRow)
+		if err := f.UnmergeCell(block.Sheet, merge.GetStartAxis(), merge.GetEndAxis()); err != nil {
+			return fmt.Errorf("failed to unmerge %s: %w", merge[0], err)
+		}
+		start, _ := excelize.CoordinatesToCellName(startCol, startRow)
+		if err := f.MergeCell(block.Sheet, start, newEnd); err != nil {
+			return fmt.Errorf("failed to merge %s:%s: %w", start, newEnd, err)
+		}
+	}
+	return nil
+}
+
+// extendTemplateValidations copies multi-row data validations ending at the old last row
+// onto the inserted rows
+func (s *ExcelService) extendTemplateValidations(f *excelize.File, block templateRowBlock, newLastRow int) error {
+	validations, err := f.GetDataValidations(block.Sheet)
+	if err != nil {
+		return fmt.Errorf("failed to read data validations: %w", err)
+	}
+
+	for _, dv := range validations {
+		var refs []string
+		for _, ref := range strings.Fields(dv.Sqref) {
+			if ext, ok := rangeExtension(ref, block.LastRow, newLastRow); ok {
+				refs = append(refs, ext)
+			}
+		}
+		if len(refs) == 0 {
+			continue
+		}
+
+		extended := *dv
+		extended.Sqref = strings.Join(refs, " ")
+		if err := f.AddDataValidation(block.Sheet, &extended); err != nil {
+			return fmt.Errorf("failed to extend data validation %s: %w", dv.Sqref, err)
+		}
+	}
+	return nil
+}
+
+// extendTemplateConditionalFormats stretches conditional format ranges ending at the old last row
+func (s *ExcelService) extendTemplateConditionalFormats(f *excelize.File, block templateRowBlock, newLastRow int) error {
+	formats, err := f.GetConditionalFormats(block.Sheet)
+	if err != nil {
+		return fmt.Errorf("failed to read conditional formats: %w", err)
+	}
+
+	for sqref, opts := range formats {
+		extended := extendRangeRefs(sqref, block.LastRow, newLastRow)
+		if extended == sqref || len(opts) == 0 {
+			continue
+		}
+		if err := f.UnsetConditionalFormat(block.Sheet, sqref); err != nil {
+			return fmt.Errorf("failed to remove conditional format %s: %w", sqref, err)
+		}
+		if err := f.SetConditionalFormat(block.Sheet, extended, opts); err != nil {
+			return fmt.Errorf("failed to extend conditional format %s: %w", sqref, err)
+		}
+	}
+	return nil
+}
+
+// extendRangeRefs moves the end of every range ending at lastRow down to newLastRow
+func extendRangeRefs(formula string, lastRow, newLastRow int) string {
+	return rangeRefPattern.ReplaceAllStringFunc(formula, func(ref string) string {
+		m := rangeRefPattern.FindStringSubmatch(ref)
+		start, _ := strconv.Atoi(m[2])
+		end, _ := strconv.Atoi(m[4])
+		if end != lastRow || start > lastRow {
+			return ref
+		}
+		return fmt.Sprintf("%s%d:%s%d", m[1], start, m[3], newLastRow)
+	})
+}
+
+// rangeExtension returns the part of a newly grown multi-row range below lastRow,
+// e.g. L11:L20 grown to row 30 gives L21:L30
+func rangeExtension(ref string, lastRow, newLastRow int) (string, bool) {
+	parts := strings.Split(strings.ReplaceAll(ref, "$", ""), ":")
+	if len(parts) != 2 {
+		return "", false
+	}
+	startCol, startRow, err := excelize.CellNameToCoordinates(parts[0])
+	if err != nil {
+		return "", false
+	}
+	endCol, endRow, err := excelize.CellNameToCoordinates(parts[1])
+	if err != nil || endRow != lastRow || startRow == endRow {
+		return "", false
+	}
+
+	from, _ := excelize.CoordinatesToCellName(startCol, lastRow+1)
+	to, _ := excelize.CoordinatesToCellName(endCol, newLastRow)
+	return from + ":" + to, true
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestPrepareTemplateRowsExtendsFooter(t *testing.T) {
+	f := excelize.NewFile()
+	defer f.Close()
+	sheet := "Sheet1"
+	// Header, three pre-formatted rows with a row formula, a SUM footer and a cell above
+	// the block that refers to the footer
+	f.SetSheetRow(sheet, "A1", &[]interface{}{"Qty", "Price", "Amount"})
+	for row := 2; row <= 4; row++ {
+		amount, _ := excelize.CoordinatesToCellName(3, row)
+		f.SetCellFormula(sheet, amount, fmt.Sprintf("A%d*B%d", row, row))
+	}
+	f.SetCellValue(sheet, "A5", "Total")
+	f.SetCellFormula(sheet, "C5", "SUM(C2:C4)")
+	f.SetCellFormula(sheet, "D5", "SUM($C$2:$C$4)/COUNT(C2:C4)")
+	f.SetCellFormula(sheet, "E1", "C5")
+
+	inserted, err := NewExcelService().prepareTemplateRows(f, sheet, 2, 0, 7)
+	if err != nil {
+		t.Fatalf("prepareTemplateRows returned %v", err)
+	}
+	if inserted != 3 {
+		t.Errorf("inserted %d rows, want 3", inserted)
+	}
+
+	tests := []struct {
+		cell string
+		want string
+	}{
+		{"C2", "A2*B2"},
+		{"C4", "A4*B4"},
+		{"C5", "A5*B5"},
+		{"C6", "A6*B6"},
+		{"C7", "A7*B7"},
+		{"C8", "SUM(C2:C7)"},
+		{"D8", "SUM($C$2:$C$7)/COUNT(C2:C7)"},
+		{"E1", "C8"},
+	}
+	for _, tt := range tests {
+		got, err := f.GetCellFormula(sheet, tt.cell)
+		if err != nil {
+			t.Fatalf("GetCellFormula(%s) returned %v", tt.cell, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.cell, got, tt.want)
+		}
+	}
+	if got, _ := f.GetCellValue(sheet, "A8"); got != "Total" {
+		t.Errorf("A8 = %q, want the footer label", got)
+	}
+	if got, _ := f.GetCellValue(sheet, "A5"); got != "" {
+		t.Errorf("A5 = %q, want an empty cloned row", got)
+	}
+}
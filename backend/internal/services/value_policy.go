@@ -0,0 +1,141 @@
+package services
+
+import (
+	"fmt"
+	"math/big"
+
+	"excel-processor/internal/models"
+)
+
+// RowValueError is returned when the "error" value policy meets a division by zero or a
+// source cell that is not a number
+type RowValueError struct {
+	Warning models.RowWarning
+}
+
+func (e *RowValueError) Error() string {
+	if e.Warning.Issue == models.IssueDivideByZero {
+		return fmt.Sprintf("row %d: division by zero", e.Warning.RowNumber)
+	}
+	return fmt.Sprintf("row %d: column %s is not a number: %q", e.Warning.RowNumber, e.Warning.Column, e.Warning.Value)
+}
+
+// rowWiseOutcome is the result of a numeric row-wise operation on one row
+type rowWiseOutcome struct {
+	values   []float64   // Source values; blank and invalid cells are 0
+	value    interface{} // Calculated value, nil when the policy leaves it blank
+	skip     bool        // The policy leaves the row out
+	warnings []models.RowWarning
+}
+
+// rowWiseOptions are the settings of a numeric row-wise operation
+type rowWiseOptions struct {
+	OnDivideByZero string
+	OnInvalidValue string
+	Decimal        bool                 // Calculate exactly
+	Rounding       *models.RoundingRule // Rounding of the result, nil to keep it as is
+	Formula        *Formula             // Formula of the formula operation, over the row's columns
+	TaxTable       *models.TaxTable     // Rates for the PIT functions of the formula
+}
+
+// valuePolicy returns the policy to apply, or the default when none is given. Divisions by
+// zero default to skip_divisor, which is how they were calculated before the policies existed.
+func valuePolicy(policy, defaultPolicy string) string {
+	if policy == "" {
+		return defaultPolicy
+	}
+	return policy
+}
+
+// evaluateRowWise applies a numeric row-wise operation or formula to one row. Blank cells count as 0;
+// cells that are not numbers (text, "#N/A" and other error values) and divisions by zero
+// are settled by the given policies, and each one is reported as a warning. A policy of
+// "error" returns a *RowValueError.
+func evaluateRowWise(operation string, columns []string, cell func(string) interface{}, rowNumber int, opts rowWiseOptions) (rowWiseOutcome, error) {
+	out := rowWiseOutcome{values: make([]float64, len(columns))}
+	exact := make([]*big.Rat, len(columns))
+	blank := false
+
+	// apply records a warning and carries out its policy
+	apply := func(warning models.RowWarning) error {
+		out.warnings = append(out.warnings, warning)
+		switch warning.Action {
+		case models.ValuePolicyError:
+			return &RowValueError{Warning: warning}
+		case models.ValuePolicySkipRow:
+			out.skip = true
+		case models.ValuePolicyBlank:
+			blank = true
+		}
+		return nil
+	}
+
+	for i, column := range columns {
+		exact[i] = new(big.Rat)
+		v := cell(column)
+		text := cellText(v)
+		if text == "" {
+			continue
+		}
+		if n, ok := cellNumber(v); ok {
+			out.values[i] = n
+			if r, ok := decimalValue(v); ok && (opts.Decimal || opts.Formula != nil) {
+				exact[i] = r
+			}
+			continue
+		}
+		if err := apply(models.RowWarning{
+			RowNumber: rowNumber,
+			Column:    column,
+			Value:     text,
+			Issue:     models.IssueInvalidValue,
+			Action:    valuePolicy(opts.OnInvalidValue, models.ValuePolicyZero),
+		}); err != nil {
+			return out, err
+		}
+	}
+	if out.skip {
+		return out, nil
+	}
+
+	var (
+		value float64
+		ok    bool
+	)
+	switch {
+	case opts.Formula != nil:
+		values := make(map[string]*big.Rat, len(columns))
+		for i, column := range columns {
+			values[column] = exact[i]
+		}
+		result, divided, err := opts.Formula.evaluate(values, opts.TaxTable)
+		if err != nil {
+			return out, fmt.Errorf("row %d: %w", rowNumber, err)
+		}
+		value, ok = decimalResult(result, opts.Rounding), divided
+	case opts.Decimal:
+		var result *big.Rat
+		result, ok = decimalRowWise(operation, exact)
+		value = decimalResult(result, opts.Rounding)
+	default:
+		value, ok = rowWiseValue(operation, out.values)
+		value = floatResult(value, opts.Rounding)
+	}
+	if !ok {
+		if err := apply(models.RowWarning{
+			RowNumber: rowNumber,
+			Issue:     models.IssueDivideByZero,
+			Action:    valuePolicy(opts.OnDivideByZero, models.ValuePolicySkipDivisor),
+		}); err != nil {
+			return out, err
+		}
+		// skip_divisor keeps the quotient of the other divisors
+		if out.warnings[len(out.warnings)-1].Action != models.ValuePolicySkipDivisor {
+			value = 0
+		}
+	}
+	if !blank {
+		out.value = value
+	}
+	return out, nil
+}
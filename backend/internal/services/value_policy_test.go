@@ -0,0 +1,86 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"excel-processor/internal/models"
+)
+
+func TestEvaluateRowWise(t *testing.T) {
+	tests := []struct {
+		name       string
+		operation  string
+		cells      map[string]interface{}
+		opts       rowWiseOptions
+		want       interface{}
+		wantSkip   bool
+		wantAction string // Action of the single warning, "" for none
+	}{
+		{"blank cells count as zero", "add", map[string]interface{}{"A": 10.0}, rowWiseOptions{}, 10.0, false, ""},
+		{"number text", "add", map[string]interface{}{"A": 10.0, "B": "1,200"}, rowWiseOptions{}, 1210.0, false, ""},
+		{"zero divisor skipped by default", "divide", map[string]interface{}{"A": 10.0, "B": 0.0}, rowWiseOptions{}, 10.0, false, models.ValuePolicySkipDivisor},
+		{"other divisors kept", "divide", map[string]interface{}{"A": 10.0, "B": 0.0, "C": 2.0}, rowWiseOptions{OnDivideByZero: models.ValuePolicySkipDivisor}, 5.0, false, models.ValuePolicySkipDivisor},
+		{"zero quotient", "divide", map[string]interface{}{"A": 10.0, "B": 0.0}, rowWiseOptions{OnDivideByZero: models.ValuePolicyZero}, 0.0, false, models.ValuePolicyZero},
+		{"blank quotient", "divide", map[string]interface{}{"A": 10.0, "B": 0.0}, rowWiseOptions{OnDivideByZero: models.ValuePolicyBlank}, nil, false, models.ValuePolicyBlank},
+		{"row skipped on division by zero", "divide", map[string]interface{}{"A": 10.0}, rowWiseOptions{OnDivideByZero: models.ValuePolicySkipRow}, nil, true, models.ValuePolicySkipRow},
+		{"invalid value is zero by default", "add", map[string]interface{}{"A": 10.0, "B": "#N/A"}, rowWiseOptions{}, 10.0, false, models.ValuePolicyZero},
+		{"blank result on invalid value", "add", map[string]interface{}{"A": 10.0, "B": "không"}, rowWiseOptions{OnInvalidValue: models.ValuePolicyBlank}, nil, false, models.ValuePolicyBlank},
+		{"row skipped on invalid value", "multiply", map[string]interface{}{"A": "#REF!", "B": 2.0}, rowWiseOptions{OnInvalidValue: models.ValuePolicySkipRow}, nil, true, models.ValuePolicySkipRow},
+		{"exact decimal sum", "add", map[string]interface{}{"A": 0.1, "B": 0.2}, rowWiseOptions{Decimal: true}, 0.3, false, ""},
+	}
+	for _, tt := range tests {
+		outcome, err := evaluateRowWise(tt.operation, []string{"A", "B", "C"}, mapCell(tt.cells), 7, tt.opts)
+		if err != nil {
+			t.Errorf("%s: evaluateRowWise returned %v", tt.name, err)
+			continue
+		}
+		if outcome.skip != tt.wantSkip {
+			t.Errorf("%s: skip = %v, want %v", tt.name, outcome.skip, tt.wantSkip)
+		}
+		if !tt.wantSkip && outcome.value != tt.want {
+			t.Errorf("%s: value = %v, want %v", tt.name, outcome.value, tt.want)
+		}
+		switch {
+		case tt.wantAction == "" && len(outcome.warnings) != 0:
+			t.Errorf("%s: warnings = %+v, want none", tt.name, outcome.warnings)
+		case tt.wantAction != "" && (len(outcome.warnings) != 1 || outcome.warnings[0].Action != tt.wantAction || outcome.warnings[0].RowNumber != 7):
+			t.Errorf("%s: warnings = %+v, want one %s warning on row 7", tt.name, outcome.warnings, tt.wantAction)
+		}
+	}
+}
+
+func TestEvaluateRowWiseErrorPolicy(t *testing.T) {
+	tests := []struct {
+		name      string
+		operation string
+		cells     map[string]interface{}
+		opts      rowWiseOptions
+		want      models.RowWarning
+		wantText  string
+	}{
+		{
+			"division by zero", "divide", map[string]interface{}{"A": 10.0, "B": 0.0},
+			rowWiseOptions{OnDivideByZero: models.ValuePolicyError},
+			models.RowWarning{RowNumber: 3, Issue: models.IssueDivideByZero, Action: models.ValuePolicyError},
+			"row 3: division by zero",
+		},
+		{
+			"invalid value", "add", map[string]interface{}{"A": 10.0, "B": "#N/A"},
+			rowWiseOptions{OnInvalidValue: models.ValuePolicyError},
+			models.RowWarning{RowNumber: 3, Column: "B", Value: "#N/A", Issue: models.IssueInvalidValue, Action: models.ValuePolicyError},
+			`row 3: column B is not a number: "#N/A"`,
+		},
+	}
+	for _, tt := range tests {
+		_, err := evaluateRowWise(tt.operation, []string{"A", "B"}, mapCell(tt.cells), 3, tt.opts)
+		var valueErr *RowValueError
+		if !errors.As(err, &valueErr) {
+			t.Errorf("%s: error = %v, want a *RowValueError", tt.name, err)
+			continue
+		}
+		if valueErr.Warning != tt.want || err.Error() != tt.wantText {
+			t.Errorf("%s: error = %q with %+v, want %q with %+v", tt.name, err, valueErr.Warning, tt.wantText, tt.want)
+		}
+	}
+}
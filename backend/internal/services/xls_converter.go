@@ -0,0 +1,94 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/extrame/xls"
+	"github.com/xuri/excelize/v2"
+)
+
+// ConvertXLSToXLSX reads the cell values of a legacy Excel 97-2003 workbook and writes them
+// into a new .xlsx file with the same sheet names. Formatting is not carried over.
+func (s *ExcelService) ConvertXLSToXLSX(xlsPath string) (outputPath string, err error) {
+	// The BIFF reader panics on some malformed files, report those as unreadable
+	defer func() {
+		if r := recover(); r != nil {
+			outputPath, err = "", fmt.Errorf("failed to read .xls file: %v", r)
+		}
+	}()
+
+	wb, err := xls.Open(xlsPath, "utf-8")
+	if err != nil {
+		return "", fmt.Errorf("failed to open .xls file: %w", err)
+	}
+	if wb == nil || wb.NumSheets() == 0 {
+		return "", fmt.Errorf("not an Excel 97-2003 workbook")
+	}
+
+	f := excelize.NewFile()
+	defer f.Close()
+
+	for i := 0; i < wb.NumSheets(); i++ {
+		sheet := wb.GetSheet(i)
+		if sheet == nil {
+			continue
+		}
+
+		sheetName := sheet.Name
+		if sheetName == "" {
+			sheetName = fmt.Sprintf("Sheet%d", i+1)
+		}
+		if i == 0 {
+			if err := f.SetSheetName("Sheet1", sheetName); err != nil {
+				return "", fmt.Errorf("invalid sheet name %s: %w", sheetName, err)
+			}
+		} else if _, err := f.NewSheet(sheetName); err != nil {
+			return "", fmt.Errorf("invalid sheet name %s: %w", sheetName, err)
+		}
+
+		for rowIndex := 0; rowIndex <= int(sheet.MaxRow); rowIndex++ {
+			row := xlsRow(sheet, rowIndex)
+			if row == nil {
+				continue
+			}
+			for colIndex := row.FirstCol(); colIndex <= row.LastCol(); colIndex++ {
+				value := row.Col(colIndex)
+				if value == "" {
+					continue
+				}
+
+				cell, _ := excelize.CoordinatesToCellName(colIndex+1, rowIndex+1)
+				if numVal, err := strconv.ParseFloat(value, 64); err == nil {
+					err = f.SetCellValue(sheetName, cell, numVal)
+				} else {
+					err = f.SetCellStr(sheetName, cell, value)
+				}
+				if err != nil {
+					return "", fmt.Errorf("failed to write cell %s: %w", cell, err)
+				}
+			}
+		}
+	}
+
+	outputPath = strings.TrimSuffix(xlsPath, filepath.Ext(xlsPath)) + ".xlsx"
+	if err := f.SaveAs(outputPath); err != nil {
+		os.Remove(outputPath)
+		return "", fmt.Errorf("failed to save converted file: %w", err)
+	}
+	return outputPath, nil
+}
+
+// xlsRow returns a row of a BIFF sheet, or nil when the row has no cells
+func xlsRow(sheet *xls.WorkSheet, index int) (row *xls.Row) {
+	// WorkSheet.Row dereferences missing rows
+	defer func() {
+		if recover() != nil {
+			row = nil
+		}
+	}()
+	return sheet.Row(index)
+}
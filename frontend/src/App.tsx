@@ -1,12 +1,16 @@
+import { useState } from 'react';
 import { ConfigProvider } from 'antd';
 import { QueryClient, QueryClientProvider } from '@tanstack/react-query';
 import { BrowserRouter as Router, Routes, Route } from 'react-router-dom';
-import { Layout, HomePage } from './components';
+import { Layout, HomePage, LoginPage } from './components';
+import { getToken } from './services/api';
 import './App.css';
 
 const queryClient = new QueryClient();
 
 function App() {
+  const [signedIn, setSignedIn] = useState(() => getToken() !== null);
+
   return (
     <QueryClientProvider client={queryClient}>
       <ConfigProvider
@@ -16,13 +20,17 @@ function App() {
           },
         }}
       >
-        <Router>
-          <Layout>
-            <Routes>
-              <Route path="/" element={<HomePage />} />
-            </Routes>
-          </Layout>
-        </Router>
+        {signedIn ? (
+          <Router>
+            <Layout>
+              <Routes>
+                <Route path="/" element={<HomePage />} />
+              </Routes>
+            </Layout>
+          </Router>
+        ) : (
+          <LoginPage onLogin={() => setSignedIn(true)} />
+        )}
       </ConfigProvider>
     </QueryClientProvider>
   );
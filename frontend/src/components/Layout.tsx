@@ -1,6 +1,7 @@
 import React from 'react';
-import { Layout as AntLayout, Menu, Typography, Space } from 'antd';
-import { FileExcelOutlined, UploadOutlined, CalculatorOutlined } from '@ant-design/icons';
+import { Layout as AntLayout, Menu, Typography, Space, Button } from 'antd';
+import { FileExcelOutlined, UploadOutlined, CalculatorOutlined, LogoutOutlined } from '@ant-design/icons';
+import { authApi } from '../services/api';
 
 const { Header, Content, Footer } = AntLayout;
 const { Title } = Typography;
@@ -37,6 +38,16 @@ const Layout: React.FC<LayoutProps> = ({ children }) => {
               },
             ]}
           />
+
+          <Button
+            icon={<LogoutOutlined />}
+            onClick={() => {
+              authApi.logout();
+              window.location.reload();
+            }}
+          >
+            Đăng xuất
+          </Button>
         </div>
       </Header>
       
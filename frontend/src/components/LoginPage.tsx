@@ -0,0 +1,49 @@
+import React, { useState } from 'react';
+import { Button, Card, Form, Input, Typography, message } from 'antd';
+import { LockOutlined, UserOutlined } from '@ant-design/icons';
+import { authApi } from '../services/api';
+
+const { Title } = Typography;
+
+interface LoginPageProps {
+  onLogin: () => void;
+}
+
+const LoginPage: React.FC<LoginPageProps> = ({ onLogin }) => {
+  const [loading, setLoading] = useState(false);
+
+  const handleFinish = async (values: { username: string; password: string }) => {
+    setLoading(true);
+    try {
+      await authApi.login(values.username, values.password);
+      onLogin();
+    } catch (error: any) {
+      message.error(error.response?.data?.error || 'Đăng nhập thất bại');
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  return (
+    <div style={{ minHeight: '100vh', display: 'flex', alignItems: 'center', justifyContent: 'center', background: '#f5f5f5' }}>
+      <Card style={{ width: 360 }}>
+        <Title level={3} style={{ textAlign: 'center', color: '#1890ff' }}>
+          Excel Processor
+        </Title>
+        <Form layout="vertical" onFinish={handleFinish}>
+          <Form.Item name="username" rules={[{ required: true, message: 'Nhập tên đăng nhập' }]}>
+            <Input prefix={<UserOutlined />} placeholder="Tên đăng nhập" autoFocus />
+          </Form.Item>
+          <Form.Item name="password" rules={[{ required: true, message: 'Nhập mật khẩu' }]}>
+            <Input.Password prefix={<LockOutlined />} placeholder="Mật khẩu" />
+          </Form.Item>
+          <Button type="primary" htmlType="submit" loading={loading} block>
+            Đăng nhập
+          </Button>
+        </Form>
+      </Card>
+    </div>
+  );
+};
+
+export default LoginPage;
@@ -22,6 +22,7 @@ import {
   DeleteOutlined
 } from '@ant-design/icons';
 import { excelApi } from '../services/api';
+import { useGetSheets } from '../hooks/useExcelApi';
 import type { RowCalculationRequest, RowCalculationResult } from '../types';
 
 const { Title, Text } = Typography;
@@ -51,13 +52,34 @@ const MultiColumnCalculator: React.FC<MultiColumnCalculatorProps> = ({
   onCalculationComplete
 }) => {
   const [calculationColumns, setCalculationColumns] = useState<CalculationColumn[]>([]);
-  const [startRow, setStartRow] = useState<number>(11);
+  const [startRow, setStartRow] = useState<number | undefined>(undefined);
   const [endRow, setEndRow] = useState<number | undefined>(undefined);
+  // Rows typed by the user; others are left to the backend, which uses the detected data table
+  const [startRowSet, setStartRowSet] = useState(false);
+  const [endRowSet, setEndRowSet] = useState(false);
   const [isCalculating, setIsCalculating] = useState(false);
   const [results, setResults] = useState<RowCalculationResult[]>([]);
+  const { data: sheets } = useGetSheets(fileId);
+  const region = sheets?.find(s => s.name === sheetName)?.region;
+
+  // Header names detected on the sheet, e.g. "Thu nhập / Lương" for a multi-row header
+  const columnHeaders = sheets?.find(s => s.name === sheetName)?.headers || {};
+  const columnLabel = (column: string) =>
+    columnHeaders[column] ? `${column} — ${columnHeaders[column]}` : column;
+
+  // Show the data table detected by the backend as the default range
+  useEffect(() => {
+    if (region && region.first_data_row > 0) {
+      if (!startRowSet) setStartRow(region.first_data_row);
+      if (!endRowSet) setEndRow(region.last_data_row);
+    }
+  }, [region]);
 
   // Auto-detect end row from sheet data
   useEffect(() => {
+    if ((region && region.first_data_row > 0) || endRowSet) {
+      return;
+    }
     if (sheetData && sheetData.length > 0) {
       let lastRowWithData = 0;
       for (let i = sheetData.length - 1; i >= 0; i--) {
@@ -113,7 +135,7 @@ const MultiColumnCalculator: React.FC<MultiColumnCalculatorProps> = ({
       }
     }
 
-    if (startRow < 1) {
+    if (startRow !== undefined && startRow < 1) {
       message.error('Hàng bắt đầu phải lớn hơn 0');
       return;
     }
@@ -130,8 +152,8 @@ const MultiColumnCalculator: React.FC<MultiColumnCalculatorProps> = ({
           source_columns: col.sourceColumns,
           target_column: col.targetColumn,
           operation: col.operation,
-          start_row: startRow - 1,
-          end_row: endRow ? endRow - 1 : undefined
+          start_row: startRowSet && startRow ? startRow - 1 : undefined,
+          end_row: endRowSet && endRow ? endRow - 1 : undefined
         };
 
         const result = await excelApi.calculateRowWise(request);
@@ -210,10 +232,12 @@ const MultiColumnCalculator: React.FC<MultiColumnCalculatorProps> = ({
             placeholder="Chọn các cột để tính toán"
             style={{ width: '100%', marginTop: 8 }}
             value={col.sourceColumns}
+            showSearch
+            optionFilterProp="children"
             onChange={(value) => updateCalculationColumn(col.id, 'sourceColumns', value)}
           >
             {availableColumns.map(column => (
-              <Option key={column} value={column}>{column}</Option>
+              <Option key={column} value={column}>{columnLabel(column)}</Option>
             ))}
           </Select>
         </Col>
@@ -239,10 +263,12 @@ const MultiColumnCalculator: React.FC<MultiColumnCalculatorProps> = ({
             placeholder="Chọn cột để ghi kết quả"
             style={{ width: '100%', marginTop: 8 }}
             value={col.targetColumn}
+            showSearch
+            optionFilterProp="children"
             onChange={(value) => updateCalculationColumn(col.id, 'targetColumn', value)}
           >
             {availableColumns.map(column => (
-              <Option key={column} value={column}>{column}</Option>
+              <Option key={column} value={column}>{columnLabel(column)}</Option>
             ))}
           </Select>
         </Col>
@@ -287,16 +313,23 @@ const MultiColumnCalculator: React.FC<MultiColumnCalculatorProps> = ({
               <InputNumber
                 min={1}
                 value={startRow}
-                onChange={(value) => setStartRow(value || 1)}
+                onChange={(value) => {
+                  setStartRow(value || undefined);
+                  setStartRowSet(!!value);
+                }}
                 style={{ width: '100%', marginTop: 8 }}
+                placeholder="Tự động phát hiện"
               />
             </Col>
             <Col span={8}>
               <Text strong>Hàng kết thúc:</Text>
               <InputNumber
-                min={startRow}
+                min={startRow || 1}
                 value={endRow}
-                onChange={(value) => setEndRow(value || undefined)}
+                onChange={(value) => {
+                  setEndRow(value || undefined);
+                  setEndRowSet(!!value);
+                }}
                 style={{ width: '100%', marginTop: 8 }}
                 placeholder="Tự động phát hiện"
               />
@@ -305,7 +338,7 @@ const MultiColumnCalculator: React.FC<MultiColumnCalculatorProps> = ({
               <Text strong>Số hàng sẽ xử lý:</Text>
               <div style={{ marginTop: 8 }}>
                 <Tag color="blue">
-                  {endRow ? endRow - startRow + 1 : 'Tự động'}
+                  {startRow && endRow ? endRow - startRow + 1 : 'Tự động'}
                 </Tag>
               </div>
             </Col>
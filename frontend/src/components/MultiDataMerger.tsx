@@ -161,6 +161,7 @@ const MultiDataMerger: React.FC<MultiDataMergerProps> = ({
       
       const columnData = {
         resultIndex,
+        calculationId: result.calculation_id,  // Lets the server check the source file is approved
         templateId,
         templateSheet,
         targetColumn,
@@ -20,11 +20,14 @@ const FileUpload: React.FC<{ onUploadSuccess: (fileId: number) => void }> = ({ o
       // Validate file type
       const allowedTypes = [
         'application/vnd.openxmlformats-officedocument.spreadsheetml.sheet', // .xlsx
-        'application/vnd.ms-excel' // .xls
+        'application/vnd.ms-excel', // .xls
+        'application/vnd.oasis.opendocument.spreadsheet', // .ods
+        'text/csv', // .csv
+        'text/tab-separated-values' // .tsv
       ];
       
-      if (!allowedTypes.includes(file.type) && !file.name.match(/\.(xlsx|xls)$/i)) {
-        message.error('Chỉ hỗ trợ file Excel (.xlsx, .xls)');
+      if (!allowedTypes.includes(file.type) && !file.name.match(/\.(xlsx|xls|ods|csv|tsv)$/i)) {
+        message.error('Chỉ hỗ trợ file Excel (.xlsx, .xls), OpenDocument (.ods) hoặc CSV (.csv, .tsv)');
         return;
       }
 
@@ -45,7 +48,10 @@ const FileUpload: React.FC<{ onUploadSuccess: (fileId: number) => void }> = ({ o
     onDrop,
     accept: {
       'application/vnd.openxmlformats-officedocument.spreadsheetml.sheet': ['.xlsx'],
-      'application/vnd.ms-excel': ['.xls']
+      'application/vnd.ms-excel': ['.xls'],
+      'application/vnd.oasis.opendocument.spreadsheet': ['.ods'],
+      'text/csv': ['.csv'],
+      'text/tab-separated-values': ['.tsv']
     },
     multiple: false,
     maxSize: 10 * 1024 * 1024 // 10MB
@@ -99,7 +105,7 @@ const FileUpload: React.FC<{ onUploadSuccess: (fileId: number) => void }> = ({ o
                 Kéo và thả file Excel vào đây hoặc click để chọn file
               </p>
               <p style={{ fontSize: '12px', color: '#666', margin: 0 }}>
-                Hỗ trợ: .xlsx, .xls (tối đa 10MB)
+                Hỗ trợ: .xlsx, .xls, .ods, .csv, .tsv (tối đa 10MB)
               </p>
             </div>
           )}
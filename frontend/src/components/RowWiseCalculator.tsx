@@ -23,7 +23,7 @@ import {
   CloseOutlined
 } from '@ant-design/icons';
 import { excelApi } from '../services/api';
-import type { RowCalculationRequest, RowCalculationResult } from '../types';
+import type { DataRegion, RowCalculationRequest, RowCalculationResult } from '../types';
 import { formatNumberWithCommas } from '../utils/numberUtils';
 
 const { Title, Text } = Typography;
@@ -34,13 +34,15 @@ interface RowWiseCalculatorProps {
   sheetName: string;
   availableColumns: string[];
   sheetData?: Record<string, any>[];
+  region?: DataRegion;  // Detected data table, used as the default row range
 }
 
 const RowWiseCalculator: React.FC<RowWiseCalculatorProps> = ({
   fileId,
   sheetName,
   availableColumns,
-  sheetData
+  sheetData,
+  region
 }) => {
   const [sourceColumns, setSourceColumns] = useState<string[]>([]);
   const [targetColumn, setTargetColumn] = useState<string>('');
@@ -50,8 +52,19 @@ const RowWiseCalculator: React.FC<RowWiseCalculatorProps> = ({
   const [isCalculating, setIsCalculating] = useState(false);
   const [result, setResult] = useState<RowCalculationResult | null>(null);
 
+  // Start from the data table detected by the backend
+  useEffect(() => {
+    if (region && region.first_data_row > 0) {
+      setStartRow(region.first_data_row);
+      setEndRow(region.last_data_row);
+    }
+  }, [region]);
+
   // Auto-detect end row from sheet data
   useEffect(() => {
+    if (region && region.first_data_row > 0) {
+      return;
+    }
     if (sheetData && sheetData.length > 0) {
       // Find the last row with actual data
       let lastRowWithData = 0;
@@ -115,8 +128,9 @@ const RowWiseCalculator: React.FC<RowWiseCalculatorProps> = ({
     }
 
     try {
+      // Refer to the stored calculation rather than posting the whole result back
       const exportRequest = {
-        calculation_result: result,
+        ...(result.calculation_id ? { calculation_id: result.calculation_id } : { calculation_result: result, file_id: fileId }),
         template_path: 'templates/FileMauImportThuNhap.xlsx'
       };
 
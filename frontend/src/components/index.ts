@@ -1,5 +1,6 @@
 export { default as Layout } from './Layout';
 export { default as HomePage } from './HomePage';
+export { default as LoginPage } from './LoginPage';
 export { default as SmartDataAnalyzer } from './SmartDataAnalyzer';
 export { default as RowWiseCalculator } from './RowWiseCalculator';
 export { default as TemplateManager } from './TemplateManager';
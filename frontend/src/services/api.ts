@@ -7,10 +7,14 @@ import type {
   CalculationResult,
   ExportRequest,
   UploadResponse,
+  LoginResponse,
+  User,
 } from '../types';
 
 const API_BASE_URL = 'http://localhost:8080/api';
 
+const TOKEN_KEY = 'authToken';
+
 const api = axios.create({
   baseURL: API_BASE_URL,
   headers: {
@@ -18,6 +22,46 @@ const api = axios.create({
   },
 });
 
+export const getToken = (): string | null => localStorage.getItem(TOKEN_KEY);
+
+// Send the access token with every request
+api.interceptors.request.use((config) => {
+  const token = getToken();
+  if (token) {
+    config.headers.Authorization = `Bearer ${token}`;
+  }
+  return config;
+});
+
+// Drop an expired or revoked token and go back to the login form
+api.interceptors.response.use(
+  (response) => response,
+  (error) => {
+    if (error.response?.status === 401 && getToken()) {
+      localStorage.removeItem(TOKEN_KEY);
+      window.location.reload();
+    }
+    return Promise.reject(error);
+  }
+);
+
+export const authApi = {
+  login: async (username: string, password: string): Promise<LoginResponse> => {
+    const response = await api.post('/auth/login', { username, password });
+    localStorage.setItem(TOKEN_KEY, response.data.token);
+    return response.data;
+  },
+
+  logout: () => {
+    localStorage.removeItem(TOKEN_KEY);
+  },
+
+  me: async (): Promise<User> => {
+    const response = await api.get('/auth/me');
+    return response.data.user;
+  },
+};
+
 export const excelApi = {
   // File upload
   uploadFile: async (file: File): Promise<UploadResponse> => {
@@ -30,6 +30,26 @@ export interface SheetInfo {
   name: string;
   columns: string[];
   row_count: number;
+  region?: DataRegion;  // Detected data table
+  headers?: Record<string, string>;  // Column letter -> header name, e.g. "Thu nhập / Lương"
+  merged_regions?: MergedRegion[];
+}
+
+// Merged cell range of a sheet, e.g. { range: "H6:Y6", value: "Thu nhập" }
+export interface MergedRegion {
+  range: string;
+  value: string;
+}
+
+// Data table detected on a sheet; row numbers are 1-based, 0 when not found
+export interface DataRegion {
+  header_row: number;
+  header_end_row: number;
+  first_data_row: number;
+  last_data_row: number;
+  total_rows?: number[];
+  blank_rows?: number[];
+  footer_row: number;
 }
 
 export interface CalculationRequest {
@@ -38,23 +58,101 @@ export interface CalculationRequest {
   main_column: string;
   target_columns: string[];
   operation: 'sum' | 'average' | 'count' | 'max' | 'min' | 'custom';
+  filter?: RowFilter;
+  arithmetic?: Arithmetic;
+  rounding?: Record<string, RoundingRule>;  // Column -> rounding of its results
+}
+
+// Row filter: one condition on a column, or conditions combined with all (AND) / any (OR)
+export interface RowFilter {
+  column?: string;
+  operator?: 'eq' | 'ne' | 'gt' | 'gte' | 'lt' | 'lte' | 'contains' | 'not_contains' | 'starts_with' | 'blank' | 'not_blank' | 'in' | 'not_in';
+  value?: string | number;
+  values?: Array<string | number>;  // For in / not_in
+  all?: RowFilter[];
+  any?: RowFilter[];
 }
 
 export interface CalculationResult {
   main_column: string;
   results: Record<string, any>[];
   summary: Record<string, number>;
+  calculation_id?: number;
 }
 
 // New types for row-wise calculations
 export interface RowCalculationRequest {
   file_id: number;
   sheet_name: string;
-  source_columns: string[];  // Columns to calculate from (e.g., ["I", "K", "M"])
+  source_columns?: string[]; // Columns to calculate from (e.g., ["I", "K", "M"]); read from the formula for 'formula'
   target_column: string;     // Column to write result to (e.g., "L")
-  operation: 'add' | 'subtract' | 'multiply' | 'divide' | 'copy';
-  start_row: number;         // Row to start calculation from (e.g., 11)
-  end_row?: number;          // Row to end calculation (optional, auto-detect if undefined)
+  operation: 'add' | 'subtract' | 'multiply' | 'divide' | 'copy' | 'formula';
+  formula?: string;          // For 'formula', e.g. "PIT(AI - FAMILY_DEDUCTION(AK) - INSURANCE(AE))"
+  tax_table?: string;        // Tax table version for PIT and insurance functions, default the one in effect
+  start_row?: number;        // 0-based row to start from (first row of the detected data table if undefined)
+  end_row?: number;          // 0-based row to end at (if undefined: end of the detected data table, or of the sheet when start_row is set)
+  filter?: RowFilter;        // Only rows matching the filter are calculated
+  on_divide_by_zero?: ValuePolicy;  // Default 'skip_divisor'
+  on_invalid_value?: ValuePolicy;   // Source cells that are not numbers, default 'zero'
+  arithmetic?: Arithmetic;
+  rounding?: Record<string, RoundingRule>;  // Target column -> rounding of its results
+}
+
+// Versioned PIT brackets, family deductions and insurance rates used by formula functions
+export interface TaxTable {
+  id: number;
+  version: string;
+  effective_from: string;
+  description: string;
+  brackets: TaxBracket[];
+  self_deduction: number;
+  dependant_deduction: number;
+  insurance: InsuranceRates;
+  created_by: string;
+  created_at: string;
+  updated_at: string;
+}
+
+// Rate on the part of monthly taxable income up to up_to; the last bracket has up_to 0
+export interface TaxBracket {
+  up_to: number;
+  rate: number;  // 0.05 = 5%
+}
+
+export interface InsuranceRates {
+  base_salary: number;               // Caps social and health insurance
+  regional_minimum_wages: number[];  // Regions 1-4, cap unemployment insurance
+  cap_multiple: number;              // Salaries above cap_multiple times the cap are not charged
+  employee: InsuranceShare;
+  employer: InsuranceShare;
+}
+
+export interface InsuranceShare {
+  social: number;
+  health: number;
+  unemployment: number;
+}
+
+// 'decimal' calculates exactly, avoiding float noise such as 12345678.999999998
+export type Arithmetic = 'float' | 'decimal';
+
+// Rounds a column's results; negative precision rounds to tens, hundreds, ... (-3 = 1,000 VND)
+export interface RoundingRule {
+  mode: 'half_up' | 'half_even' | 'down' | 'up' | 'floor' | 'ceiling';
+  precision: number;
+}
+
+// How a division by zero or a cell that is not a number is handled
+export type ValuePolicy = 'error' | 'skip_row' | 'blank' | 'zero' | 'skip_divisor';  // skip_divisor: divisions only
+
+// Row whose result was decided by a value policy
+export interface RowWarning {
+  step?: number;  // Recipe step, 1-based, for recipe runs
+  row_number: number;
+  column?: string;
+  value?: string;
+  issue: 'divide_by_zero' | 'invalid_value';
+  action: ValuePolicy;
 }
 
 export interface RowCalculationResult {
@@ -70,6 +168,9 @@ export interface RowCalculationResult {
   }>;
   total_rows: number;
   formula: string;  // e.g., "I + K + M"
+  calculation_id?: number;  // ID of the stored result
+  filtered_rows?: number;   // Rows left out by the filter
+  warnings?: RowWarning[];  // Rows affected by a division by zero or an invalid value
 }
 
 export interface ExportRequest {
@@ -77,6 +178,33 @@ export interface ExportRequest {
   sheet_name: string;
   data: Record<string, any>[];
   template_name?: string;
+  columns?: ExportColumn[];
+  freeze_columns?: number;
+}
+
+export interface ExportColumn {
+  key: string;
+  header?: string;
+  type?: 'text' | 'number' | 'integer' | 'currency' | 'percent' | 'date';
+  number_format?: string;
+  width?: number;
+}
+
+export interface User {
+  id: number;
+  username: string;
+  full_name: string;
+  role: 'admin' | 'province_officer' | 'unit_officer' | 'viewer' | 'api_key';  // api_key: signed in with a write API key
+  province_id?: number;
+  unit_id?: number;
+  active: boolean;
+  last_login_at?: string;
+}
+
+export interface LoginResponse {
+  token: string;
+  expires_at: string;
+  user: User;
 }
 
 export interface UploadResponse {